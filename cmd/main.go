@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,12 +17,19 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/blob"
 	"siger-api-gateway/internal/discovery"
+	"siger-api-gateway/internal/events"
 	"siger-api-gateway/internal/handlers"
 	"siger-api-gateway/internal/messaging"
+	"siger-api-gateway/internal/metering"
 	"siger-api-gateway/internal/middleware"
 	"siger-api-gateway/internal/proxy"
+	"siger-api-gateway/internal/quota"
+	"siger-api-gateway/internal/secrets"
 	"siger-api-gateway/internal/storage"
+	"siger-api-gateway/internal/webhooks"
+	"siger-api-gateway/internal/workers"
 )
 
 func main() {
@@ -124,6 +133,68 @@ func main() {
 		logger.Warn("Consul address not configured, service discovery will be disabled")
 	}
 
+	// Initialize job store
+	// Created before NATS so status updates have somewhere to land
+	jobStore := storage.NewJobStore(10000) // Store up to 10,000 jobs in memory
+
+	// Quota manager tracks GPU consumption from job store changes
+	// Registered before any job is added so nothing is missed
+	quotaManager := quota.NewManager(config.Quotas)
+	jobStore.OnChange(quotaManager.Observe)
+	if config.Quotas.Enabled {
+		logger.Info("GPU quotas enabled")
+	}
+
+	// Usage meter books GPU time of every finished job attempt for cost reports
+	meter := metering.NewMeter(config.Metering, metering.DefaultMaxRecords)
+	jobStore.OnChange(meter.Observe)
+
+	// Blob backend for job artifact downloads
+	artifactBackend, err := blob.NewBackend(config.Artifacts)
+	if err != nil {
+		logger.Fatalf("Failed to initialize artifacts backend: %v", err)
+	}
+
+	// Blob store for dataset uploads, it must accept writes as well as reads
+	var datasetUploader blob.Uploader
+	datasetBackend, err := blob.NewBackend(config.Datasets)
+	if err != nil {
+		logger.Fatalf("Failed to initialize datasets backend: %v", err)
+	}
+	if datasetBackend != nil {
+		uploader, ok := datasetBackend.(blob.Uploader)
+		if !ok {
+			logger.Fatalf("Datasets backend %q does not support uploads", config.Datasets.Backend)
+		}
+		datasetUploader = uploader
+	}
+	datasetStore := storage.NewDatasetStore()
+	jobStore.OnChange(datasetStore.Observe)
+
+	// User secrets, encrypted at rest and handed to workers with one-time tokens
+	secretsKey, err := base64.StdEncoding.DecodeString(config.Secrets.EncryptionKey)
+	if err != nil {
+		logger.Fatalf("Invalid secrets encryption key: %v", err)
+	}
+	if len(secretsKey) == 0 {
+		logger.Warn("No secrets encryption key configured, using a random key - secrets won't survive a restart")
+		secretsKey = make([]byte, secrets.KeySize)
+		if _, err := rand.Read(secretsKey); err != nil {
+			logger.Fatalf("Failed to generate secrets encryption key: %v", err)
+		}
+	}
+	secretStore, err := secrets.NewStore(secretsKey)
+	if err != nil {
+		logger.Fatalf("Failed to initialize secret store: %v", err)
+	}
+	jobStore.OnChange(secretStore.Observe)
+
+	// Reusable job templates, kept with every version jobs were created from
+	templateStore := storage.NewTemplateStore()
+
+	// GPU workers known from their heartbeats
+	workerRegistry := workers.NewRegistry()
+
 	// Initialize NATS client
 	// Using NATS with JetStream for durable, persistent messaging
 	// Much more lightweight than Kafka and easier to set up - virjilakrum
@@ -143,6 +214,9 @@ func main() {
 		} else {
 			logger.Info("NATS client initialized")
 
+			// Set job store in NATS client for status updates
+			natsClient.SetJobStore(jobStore)
+
 			// Ensure job stream exists
 			// Using wildcard subjects for job types to allow easy filtering
 			// Makes it easy to add new job types without changing consumers - virjilakrum
@@ -153,6 +227,33 @@ func main() {
 				logger.Info("Jobs stream created")
 			}
 
+			// Job logs get their own stream on jobs.logs.> so they can be
+			// tailed by sequence without wading through job messages
+			err = natsClient.EnsureLogStream()
+			if err != nil {
+				logger.Warnf("Failed to ensure job logs stream: %v", err)
+			} else {
+				logger.Info("Job logs stream created")
+			}
+
+			// The priority dispatcher hands jobs out on a work queue
+			if config.Dispatch.Enabled {
+				err = natsClient.EnsureDispatchStream()
+				if err != nil {
+					logger.Warnf("Failed to ensure dispatch stream: %v", err)
+				} else {
+					logger.Info("Dispatch stream created")
+				}
+			}
+
+			// Worker heartbeats feed the registry of available GPUs
+			err = natsClient.SubscribeToHeartbeats(workerRegistry.Heartbeat)
+			if err != nil {
+				logger.Warnf("Failed to subscribe to worker heartbeats: %v", err)
+			} else {
+				logger.Info("Subscribed to worker heartbeats")
+			}
+
 			// Initialize job status subscription
 			err = natsClient.SubscribeToStatusUpdates()
 			if err != nil {
@@ -168,16 +269,67 @@ func main() {
 		logger.Warn("NATS address not configured, asynchronous messaging will be disabled")
 	}
 
-	// Initialize job store
-	jobStore := storage.NewJobStore(10000) // Store up to 10,000 jobs in memory
-
-	// Set job store in NATS client for status updates
+	// Initialize handlers
+	eventBroker := events.NewBroker(events.DefaultHistoryPerJob, events.DefaultMaxJobs)
+	jobEventsHandler := handlers.NewJobEventsHandler(eventBroker, jobStore)
+	jobSubmissionHandler := handlers.NewJobSubmissionHandler(natsClient, jobStore)
+	jobSubmissionHandler.SetQuotaManager(quotaManager)
+	jobSubmissionHandler.SetMeter(meter)
+	jobSubmissionHandler.SetTemplateStore(templateStore)
+	jobSubmissionHandler.SetDatasetStore(datasetStore)
+	jobSubmissionHandler.SetSecretStore(secretStore)
+	jobSubmissionHandler.SetWorkerRegistry(workerRegistry)
+	jobSubmissionHandler.SetCapacityConfig(config.Capacity)
+	jobSubmissionHandler.SetDispatchConfig(config.Dispatch)
+	jobSubmissionHandler.SetPreemptionConfig(config.Preemption)
+	templateHandler := handlers.NewTemplateHandler(templateStore)
+	datasetHandler := handlers.NewDatasetHandler(datasetStore, datasetUploader)
+	secretHandler := handlers.NewSecretHandler(secretStore, jobStore)
+	artifactHandler := handlers.NewArtifactHandler(jobStore, artifactBackend)
+
+	// Watchdog expires jobs past their deadline and cancels runaway ones
+	watchdogCtx, stopWatchdog := context.WithCancel(context.Background())
+	defer stopWatchdog()
+	go jobSubmissionHandler.RunWatchdog(watchdogCtx, handlers.DefaultWatchdogInterval)
+
+	// Job messages are stored with their jobs and published by the outbox
+	// relay, which keeps retrying while NATS is unreachable
+	outboxCtx, stopOutbox := context.WithCancel(context.Background())
+	defer stopOutbox()
 	if natsClient != nil {
-		natsClient.SetJobStore(jobStore)
+		outboxRelay := messaging.NewOutboxRelay(natsClient, jobStore)
+		jobSubmissionHandler.SetOutboxRelay(outboxRelay)
+		go outboxRelay.Run(outboxCtx, messaging.DefaultOutboxInterval)
 	}
 
-	// Initialize handlers
-	jobSubmissionHandler := handlers.NewJobSubmissionHandler(natsClient, jobStore)
+	// Webhooks are delivered in the background, retried with backoff
+	webhookStore := webhooks.NewStore()
+	webhookTargets, err := webhooks.NewTargetPolicy(config.Webhooks.AllowedHosts)
+	if err != nil {
+		logger.Fatalf("Invalid webhook config: %v", err)
+	}
+	webhookDispatcher := webhooks.NewDispatcher(webhookStore, webhookTargets)
+	webhookCtx, stopWebhooks := context.WithCancel(context.Background())
+	defer stopWebhooks()
+	go webhookDispatcher.Run(webhookCtx, webhooks.DefaultWorkers)
+	webhookHandler := handlers.NewWebhookHandler(webhookStore, webhookDispatcher, jobStore)
+
+	// Registry sweep marks workers dead after missed heartbeats
+	registryCtx, stopRegistry := context.WithCancel(context.Background())
+	defer stopRegistry()
+	go workerRegistry.Run(registryCtx, workers.DefaultHeartbeatInterval)
+	if config.Capacity.HoldWhenSaturated || config.Dispatch.Enabled {
+		// Held jobs are published from here once their GPUs free up,
+		// in dispatch order when the priority dispatcher is on
+		go jobSubmissionHandler.RunAdmission(registryCtx, handlers.DefaultReleaseInterval)
+	} else if config.Preemption.Enabled {
+		logger.Warn("Preemption needs capacity.holdWhenSaturated or dispatch, no jobs will be preempted")
+	}
+	capacityHandler := handlers.NewCapacityHandler(workerRegistry)
+
+	quotaHandler := handlers.NewQuotaHandler(quotaManager)
+	usageHandler := handlers.NewUsageHandler(meter)
+	jobLogsHandler := handlers.NewJobLogsHandler(natsClient, eventBroker, jobStore, config.JWTSecret)
 	authHandler := handlers.NewAuthHandler(&config)
 
 	// Initialize proxy handler if service registry is available
@@ -193,14 +345,17 @@ func main() {
 
 	// Global middlewares (applied to all routes)
 	// Order matters here! Recovery should be first to catch panics in other middleware - virjilakrum
-	router.Use(middleware.Recoverer())                  // Recover from panics
-	router.Use(middleware.RequestLogger())              // Log requests using our structured logger
-	router.Use(middleware.Metrics())                    // Collect Prometheus metrics
-	router.Use(middleware.CORS(nil))                    // CORS support with default options
-	router.Use(chiMiddleware.RequestID)                 // Add a request ID to each request
-	router.Use(chiMiddleware.RealIP)                    // Use the real IP from X-Forwarded-For or X-Real-IP
-	router.Use(chiMiddleware.URLFormat)                 // Parse URL format from URL query parameters
-	router.Use(chiMiddleware.Timeout(60 * time.Second)) // Set a 60-second timeout for all requests
+	router.Use(middleware.Recoverer())     // Recover from panics
+	router.Use(middleware.RequestLogger()) // Log requests using our structured logger
+	router.Use(middleware.Metrics())       // Collect Prometheus metrics
+	router.Use(middleware.CORS(nil))       // CORS support with default options
+	router.Use(chiMiddleware.RequestID)    // Add a request ID to each request
+	router.Use(chiMiddleware.RealIP)       // Use the real IP from X-Forwarded-For or X-Real-IP
+	router.Use(chiMiddleware.URLFormat)    // Parse URL format from URL query parameters
+
+	// 60-second timeout for regular requests, applied per route group
+	// Streams and large transfers are registered outside it
+	requestTimeout := middleware.Timeout(60 * time.Second)
 
 	// Add rate limiting - 100 requests per second with burst of 200
 	// Token bucket algorithm works well here - tested vs. leaky bucket
@@ -216,7 +371,7 @@ func main() {
 	// Health endpoint (not rate limited)
 	// Used by Consul and other health checkers - must be fast and reliable
 	// Don't add auth or complex logic here - keep it simple - virjilakrum
-	router.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+	router.With(requestTimeout).Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
@@ -224,10 +379,11 @@ func main() {
 	// Metrics endpoint
 	// Exposing Prometheus metrics for monitoring
 	// Separate from /health because metrics might be large - virjilakrum
-	router.Handle("/metrics", promhttp.Handler())
+	router.With(requestTimeout).Handle("/metrics", promhttp.Handler())
 
 	// Auth routes - public
 	router.Route("/auth", func(r chi.Router) {
+		r.Use(requestTimeout)
 		authHandler.RegisterRoutes(r)
 	})
 
@@ -235,33 +391,88 @@ func main() {
 	// Using versioned APIs from the start makes future upgrades easier
 	// Learned this the hard way from previous projects - virjilakrum
 	router.Route("/api/v1", func(r chi.Router) {
-		// Protected routes - require authentication
+		// Regular requests
 		r.Group(func(r chi.Router) {
-			// Apply JWT authentication middleware to all routes in this group
-			r.Use(middleware.JWTAuth(config.JWTSecret))
+			r.Use(requestTimeout)
 
-			// Job submission routes
-			jobSubmissionHandler.RegisterRoutes(r)
-
-			// Admin-only routes
-			// Using nested route groups with role middleware for authorization
-			// This pattern scales well as we add more auth rules - virjilakrum
+			// Protected routes - require authentication
 			r.Group(func(r chi.Router) {
-				r.Use(middleware.RequireRole("admin"))
-				// Admin-specific endpoints would go here
-				r.Get("/admin-stats", func(w http.ResponseWriter, r *http.Request) {
-					w.Header().Set("Content-Type", "application/json")
-					w.WriteHeader(http.StatusOK)
-					w.Write([]byte(`{"admin":"true","message":"Admin access granted"}`))
+				// Apply JWT authentication middleware to all routes in this group
+				r.Use(middleware.JWTAuth(config.JWTSecret))
+
+				// Job submission routes
+				jobSubmissionHandler.RegisterRoutes(r)
+
+				// Quota limits and usage for the caller
+				quotaHandler.RegisterRoutes(r)
+
+				// GPU usage and cost for the caller
+				usageHandler.RegisterRoutes(r)
+
+				// Job templates, private or shared within a project
+				templateHandler.RegisterRoutes(r)
+
+				// Job outputs, listed from what workers reported
+				artifactHandler.RegisterRoutes(r)
+
+				// Training data, uploaded in resumable chunks
+				datasetHandler.RegisterRoutes(r)
+
+				// Secrets jobs reference by name, values are write-only
+				secretHandler.RegisterRoutes(r)
+
+				// Callbacks on job status changes
+				webhookHandler.RegisterRoutes(r)
+
+				// GPUs live workers report
+				capacityHandler.RegisterRoutes(r)
+
+				// Admin-only routes
+				// Using nested route groups with role middleware for authorization
+				// This pattern scales well as we add more auth rules - virjilakrum
+				r.Group(func(r chi.Router) {
+					r.Use(middleware.RequireRole("admin"))
+					// Admin-specific endpoints would go here
+					r.Get("/admin-stats", func(w http.ResponseWriter, r *http.Request) {
+						w.Header().Set("Content-Type", "application/json")
+						w.WriteHeader(http.StatusOK)
+						w.Write([]byte(`{"admin":"true","message":"Admin access granted"}`))
+					})
 				})
 			})
+
+			// Workers fetch job secrets with the one-time token from the job message
+			secretHandler.RegisterWorkerRoutes(r)
+
+			// Public routes - no authentication required
+			r.Get("/status", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"status":"operational","version":"1.0.0"}`))
+			})
 		})
 
-		// Public routes - no authentication required
-		r.Get("/status", func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(`{"status":"operational","version":"1.0.0"}`))
+		// Long-lived streams and large transfers - no request timeout
+		r.Group(func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.JWTAuth(config.JWTSecret))
+
+				// Job event streams (Server-Sent Events)
+				jobEventsHandler.RegisterRoutes(r)
+
+				// Job outputs, streamed from blob storage
+				artifactHandler.RegisterDownloadRoutes(r)
+
+				// Dataset chunks
+				datasetHandler.RegisterUploadRoutes(r)
+			})
+
+			// Job log WebSocket - authenticates the JWT itself since browsers
+			// can't set an Authorization header on WebSocket connections
+			jobLogsHandler.RegisterRoutes(r)
+
+			// Workers download job datasets with the token from the job message
+			datasetHandler.RegisterWorkerRoutes(r)
 		})
 	})
 
@@ -270,6 +481,7 @@ func main() {
 	// Route pattern makes proxying fully transparent to clients - virjilakrum
 	if proxyHandler != nil {
 		router.Route("/services", func(r chi.Router) {
+			r.Use(requestTimeout)
 			// Proxy requests to backend services
 			// The path will be /services/{service-name}/*
 			r.HandleFunc("/{serviceName}/*", func(w http.ResponseWriter, r *http.Request) {
@@ -292,6 +504,7 @@ func main() {
 	// Admin routes
 	router.Route("/admin", func(r chi.Router) {
 		// These routes require authentication and admin role
+		r.Use(requestTimeout)
 		r.Use(middleware.JWTAuth(config.JWTSecret))
 		r.Use(middleware.RequireRole("admin"))
 
@@ -300,6 +513,15 @@ func main() {
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte(`{"message":"Admin dashboard"}`))
 		})
+
+		// Cluster-wide GPU usage and cost reports
+		usageHandler.RegisterAdminRoutes(r)
+
+		// Workers and their last heartbeats
+		capacityHandler.RegisterAdminRoutes(r)
+
+		// Jobs waiting for the priority dispatcher
+		jobSubmissionHandler.RegisterAdminRoutes(r)
 	})
 
 	// Create server
@@ -326,6 +548,10 @@ func main() {
 	<-quit
 
 	logger.Info("Shutting down server...")
+	stopWatchdog()
+	stopOutbox()
+	stopWebhooks()
+	stopRegistry()
 
 	// Create a deadline for server shutdown
 	// 10s should be enough for all in-flight requests to complete
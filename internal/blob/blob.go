@@ -0,0 +1,68 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"siger-api-gateway/internal"
+)
+
+// Supported backends
+const (
+	BackendLocal = "local"
+)
+
+// Common errors
+var (
+	ErrNotFound       = errors.New("blob not found")
+	ErrUnsupportedURI = errors.New("unsupported blob URI")
+	ErrOffsetMismatch = errors.New("upload offset does not match the stored size")
+)
+
+// Object is an open blob ready to be streamed
+// Body is an io.ReadSeeker when the backend supports range requests
+type Object struct {
+	Body    io.ReadCloser
+	Size    int64
+	ModTime time.Time
+}
+
+// Backend reads job artifacts from wherever workers uploaded them
+// Artifacts are addressed by the storage URI the worker reported, so the
+// gateway never needs to know the bucket layout
+type Backend interface {
+	// Open opens the blob at uri, returning ErrNotFound or ErrUnsupportedURI
+	Open(ctx context.Context, uri string) (*Object, error)
+}
+
+// Uploader is a backend that also accepts uploads, written in chunks
+// Chunks are all or nothing: a failed Append leaves the blob as it was,
+// so the client simply retries the chunk from the same offset
+type Uploader interface {
+	Backend
+
+	// Append writes r at offset, which must be the blob's current size
+	Append(ctx context.Context, key string, offset int64, r io.Reader) (int64, error)
+
+	// URI returns the URI Open accepts for the blob at key
+	URI(key string) string
+
+	// Delete removes the blob at key; deleting a missing blob is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBackend creates the backend selected in the config
+// An empty backend disables artifact downloads and returns nil
+func NewBackend(config internal.BlobConfig) (Backend, error) {
+	switch config.Backend {
+	case "":
+		return nil, nil
+	case BackendLocal:
+		return NewLocalBackend(config.LocalRoot)
+	default:
+		return nil, fmt.Errorf("unknown blob backend %q", config.Backend)
+	}
+}
@@ -0,0 +1,189 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalBackend serves file:// URIs from a directory on the gateway host
+// Meant for single-node setups and shared filesystems like NFS; only files
+// under the root are served so a job can't point its artifacts at /etc
+type LocalBackend struct {
+	root string
+}
+
+// NewLocalBackend creates a local filesystem backend rooted at root
+func NewLocalBackend(root string) (*LocalBackend, error) {
+	if root == "" {
+		return nil, errors.New("local artifacts backend needs a root directory")
+	}
+
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("resolving artifacts root: %w", err)
+	}
+	// Resolved once here, paths are compared against the real directory
+	if err := os.MkdirAll(abs, 0755); err != nil {
+		return nil, fmt.Errorf("creating artifacts root: %w", err)
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, fmt.Errorf("resolving artifacts root: %w", err)
+	}
+	return &LocalBackend{root: real}, nil
+}
+
+// Open opens a file URI, either file:///absolute/path or file:path/relative/to/root
+func (b *LocalBackend) Open(ctx context.Context, uri string) (*Object, error) {
+	path, err := b.resolve(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, ErrNotFound
+	}
+
+	return &Object{
+		Body:    file,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}, nil
+}
+
+// resolve maps a file:// URI to a path under the root
+func (b *LocalBackend) resolve(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" || (u.Host != "" && u.Host != "localhost") {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedURI, uri)
+	}
+
+	// file:relative/path parses as opaque, file:///abs/path as a path
+	p := u.Path
+	if p == "" {
+		p = u.Opaque
+	}
+	path := filepath.FromSlash(p)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(b.root, path)
+	}
+	path = filepath.Clean(path)
+
+	// Symlinks are followed before the check, a link under the root may point anywhere
+	real, err := evalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrUnsupportedURI, uri, err)
+	}
+	rel, err := filepath.Rel(b.root, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s is outside the artifacts root", ErrUnsupportedURI, uri)
+	}
+	return real, nil
+}
+
+// evalSymlinks resolves the symlinks in path, which may not exist yet
+// The missing part is kept as is under its nearest existing parent; a
+// dangling symlink is an error, creating the file would follow it
+func evalSymlinks(path string) (string, error) {
+	real, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return real, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return "", errors.New("dangling symlink")
+	}
+
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path, nil
+	}
+	realParent, err := evalSymlinks(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(realParent, filepath.Base(path)), nil
+}
+
+// Append writes a chunk at offset, rolling the file back if the write fails
+func (b *LocalBackend) Append(ctx context.Context, key string, offset int64, r io.Reader) (int64, error) {
+	path, err := b.resolve(b.URI(key))
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	// A larger file is a chunk that died half way, which was never acknowledged
+	if info.Size() < offset {
+		return 0, ErrOffsetMismatch
+	}
+	if info.Size() > offset {
+		if err := file.Truncate(offset); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(file, r)
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		file.Truncate(offset)
+		return 0, err
+	}
+	return written, nil
+}
+
+// URI returns the root-relative file URI for key
+func (b *LocalBackend) URI(key string) string {
+	return "file:" + filepath.ToSlash(filepath.Clean(key))
+}
+
+// Delete removes the file for key
+func (b *LocalBackend) Delete(ctx context.Context, key string) error {
+	path, err := b.resolve(b.URI(key))
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
@@ -25,6 +26,98 @@ type Config struct {
 		Methods []string `yaml:"methods"`
 		Headers []string `yaml:"headers"`
 	} `yaml:"corsAllowed,omitempty"`
+	Quotas     QuotaConfig      `yaml:"quotas,omitempty"`
+	Metering   MeteringConfig   `yaml:"metering,omitempty"`
+	Artifacts  BlobConfig       `yaml:"artifacts,omitempty"`
+	Datasets   BlobConfig       `yaml:"datasets,omitempty"`
+	Secrets    SecretsConfig    `yaml:"secrets,omitempty"`
+	Capacity   CapacityConfig   `yaml:"capacity,omitempty"`
+	Dispatch   DispatchConfig   `yaml:"dispatch,omitempty"`
+	Preemption PreemptionConfig `yaml:"preemption,omitempty"`
+	Webhooks   WebhookConfig    `yaml:"webhooks,omitempty"`
+}
+
+// QuotaLimits holds the GPU quota limits for a user, role or project
+// A zero value means "no limit" for that dimension
+// maxConcurrentGPUs is keyed by GPU type, "*" limits all types combined
+type QuotaLimits struct {
+	MaxConcurrentGPUs map[string]int `yaml:"maxConcurrentGPUs,omitempty" json:"max_concurrent_gpus,omitempty"`
+	MaxQueuedJobs     int            `yaml:"maxQueuedJobs,omitempty" json:"max_queued_jobs,omitempty"`
+	GPUHours          float64        `yaml:"gpuHours,omitempty" json:"gpu_hours,omitempty"`
+	Period            string         `yaml:"period,omitempty" json:"period,omitempty"` // Rolling window for gpuHours, e.g. "720h"
+}
+
+// QuotaConfig configures GPU quotas enforced at job submission
+// Users get the first match of users > roles > defaults, project limits
+// apply on top of that to jobs submitted for the project
+type QuotaConfig struct {
+	Enabled  bool                   `yaml:"enabled"`
+	Defaults QuotaLimits            `yaml:"defaults,omitempty"`
+	Roles    map[string]QuotaLimits `yaml:"roles,omitempty"`
+	Users    map[string]QuotaLimits `yaml:"users,omitempty"`
+	Projects map[string]QuotaLimits `yaml:"projects,omitempty"`
+}
+
+// MeteringConfig holds the GPU price table used for cost accounting
+// Prices are per GPU-hour keyed by GPU type, defaultPrice covers anything unlisted
+type MeteringConfig struct {
+	Currency     string             `yaml:"currency,omitempty"`
+	DefaultPrice float64            `yaml:"defaultPrice,omitempty"`
+	Prices       map[string]float64 `yaml:"pricesPerGPUHour,omitempty"`
+}
+
+// BlobConfig selects a blob backend, used for job artifacts and datasets
+// Only "local" exists for now, S3-compatible storage is next
+type BlobConfig struct {
+	Backend   string `yaml:"backend,omitempty"`   // Empty disables the feature
+	LocalRoot string `yaml:"localRoot,omitempty"` // Directory used by the local backend
+}
+
+// SecretsConfig holds the key user secrets are encrypted with
+// Base64 encoded 32 byte AES-256 key, keep it out of the file with ${ENV_VAR}
+type SecretsConfig struct {
+	EncryptionKey string `yaml:"encryptionKey,omitempty"`
+}
+
+// CapacityConfig controls capacity-aware admission of submitted jobs
+// Admission is "reject" (default), "warn" or "off" for requests no node can
+// run. Static describes the cluster until workers send heartbeats
+type CapacityConfig struct {
+	Admission         string                       `yaml:"admission,omitempty"`
+	HoldWhenSaturated bool                         `yaml:"holdWhenSaturated,omitempty"`
+	Static            map[string]StaticGPUCapacity `yaml:"static,omitempty"` // Keyed by GPU type
+}
+
+// StaticGPUCapacity describes the nodes with one GPU type
+type StaticGPUCapacity struct {
+	Nodes       int `yaml:"nodes"`
+	GPUsPerNode int `yaml:"gpusPerNode"`
+}
+
+// DispatchConfig turns on the gateway's priority dispatcher
+// Jobs wait in the gateway and go out on a work-queue stream by priority,
+// aged so old jobs can't starve and penalized by their owner's share of the
+// GPUs in use
+type DispatchConfig struct {
+	Enabled         bool    `yaml:"enabled"`
+	AgingInterval   string  `yaml:"agingInterval,omitempty"`   // Waiting this long is worth one priority point, default 10m
+	FairShareWeight float64 `yaml:"fairShareWeight,omitempty"` // Points taken from a user or project holding every GPU in use
+	MaxInFlight     int     `yaml:"maxInFlight,omitempty"`     // Dispatched jobs not yet started, used without heartbeats
+}
+
+// PreemptionConfig lets held high priority jobs displace preemptible ones
+// Only held jobs can preempt, so it needs holdWhenSaturated or the
+// dispatcher, and heartbeats to know which GPUs are taken
+type PreemptionConfig struct {
+	Enabled        bool `yaml:"enabled"`
+	MinPriorityGap int  `yaml:"minPriorityGap,omitempty"` // Victims are at least this much lower priority, default 1
+}
+
+// WebhookConfig lists internal webhook targets admins allow
+// Entries are hostnames, IPs or CIDRs; everything else on loopback, private
+// or link-local ranges is refused
+type WebhookConfig struct {
+	AllowedHosts []string `yaml:"allowedHosts,omitempty"`
 }
 
 // DefaultConfig provides default configuration values
@@ -101,6 +194,27 @@ func LoadConfig(configPath string) (Config, error) {
 		config.LogLevel = "info" // Default to info if invalid
 	}
 
+	// Anything but warn or off rejects, the safe choice for a typo
+	switch strings.ToLower(config.Capacity.Admission) {
+	case "warn", "off":
+		config.Capacity.Admission = strings.ToLower(config.Capacity.Admission)
+	default:
+		config.Capacity.Admission = "reject"
+	}
+
+	if config.Dispatch.AgingInterval == "" {
+		config.Dispatch.AgingInterval = "10m"
+	}
+	if _, err := time.ParseDuration(config.Dispatch.AgingInterval); err != nil {
+		return config, fmt.Errorf("invalid dispatch aging interval: %w", err)
+	}
+	if config.Dispatch.MaxInFlight <= 0 {
+		config.Dispatch.MaxInFlight = 16
+	}
+	if config.Preemption.MinPriorityGap <= 0 {
+		config.Preemption.MinPriorityGap = 1
+	}
+
 	return config, nil
 }
 
@@ -141,6 +255,63 @@ consulAddress: localhost:8500   # Consul address for service discovery
 # Messaging configuration
 natsAddress: nats://localhost:4222  # NATS address for async messaging
 
+# GPU quotas enforced at job submission (0 or missing = unlimited)
+quotas:
+  enabled: false
+  defaults:
+    maxConcurrentGPUs:
+      "*": 8         # All GPU types combined
+      H100: 4
+    maxQueuedJobs: 20
+    gpuHours: 500    # GPU-hours per rolling period
+    period: 720h     # 30 days
+  roles:
+    admin: {}        # Admins are unlimited
+
+# GPU usage metering - price per GPU-hour used for cost reports
+metering:
+  currency: USD
+  defaultPrice: 1.00
+  pricesPerGPUHour:
+    A100: 2.50
+    H100: 4.00
+
+# Where job artifacts reported by workers are downloaded from
+artifacts:
+  backend: local               # Leave empty to disable downloads
+  localRoot: /var/lib/siger/artifacts
+
+# Where uploaded datasets are stored
+datasets:
+  backend: local               # Leave empty to disable dataset uploads
+  localRoot: /var/lib/siger/datasets
+
+# Key user secrets are encrypted with (base64, 32 bytes: openssl rand -base64 32)
+# Without it a random key is generated and secrets are lost on restart
+secrets:
+  encryptionKey: ${SIGER_SECRETS_KEY}
+
+# Capacity-aware admission, checked against worker heartbeats
+capacity:
+  admission: reject          # reject, warn or off for jobs no node can run
+  holdWhenSaturated: false   # Hold jobs in the gateway while no GPUs are free
+  static:                    # Used until workers send heartbeats
+    H100:
+      nodes: 2
+      gpusPerNode: 8
+
+# Priority dispatch with fair share, jobs go out on dispatch.<type>
+dispatch:
+  enabled: false
+  agingInterval: 10m         # Waiting this long is worth one priority point
+  fairShareWeight: 10        # Priority points a user holding every busy GPU loses
+  maxInFlight: 16            # Dispatched but unstarted jobs while heartbeats are missing
+
+# Held high priority jobs may stop preemptible jobs to take their GPUs
+preemption:
+  enabled: false
+  minPriorityGap: 1          # Victims are at least this much lower priority
+
 # CORS configuration
 corsAllowed:
   origins:
@@ -0,0 +1,215 @@
+package events
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// JobEvent is a single job state change pushed to streaming clients
+// IDs are global and strictly increasing so clients can resume with Last-Event-ID
+// Same shape for SSE and any other streaming transport we add later
+type JobEvent struct {
+	ID        uint64    `json:"id"`
+	JobID     string    `json:"job_id"`
+	UserID    string    `json:"-"`
+	Project   string    `json:"-"`
+	Status    string    `json:"status"`
+	Message   string    `json:"message,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Progress  float64   `json:"progress,omitempty"`
+	Attempt   int       `json:"attempt,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Broker defaults
+// 100 events per job covers even chatty progress reporting for a reconnect
+// 64 buffered events per subscriber before we consider it too slow
+const (
+	DefaultHistoryPerJob    = 100
+	DefaultMaxJobs          = 10000
+	subscriberBufferSize    = 64
+	historyCleanupBatchSize = 100
+)
+
+// jobHistory holds the recent events for a single job
+type jobHistory struct {
+	userID     string
+	events     []JobEvent
+	lastUpdate time.Time
+}
+
+// subscriber is a single streaming client
+type subscriber struct {
+	ch     chan JobEvent
+	filter func(JobEvent) bool
+}
+
+// Broker fans job events out to streaming clients
+// Keeps a bounded per-job history so reconnecting clients don't miss updates
+// Slow subscribers are dropped instead of blocking the status update path
+type Broker struct {
+	mutex         sync.RWMutex
+	nextID        uint64
+	histories     map[string]*jobHistory
+	subscribers   map[*subscriber]struct{}
+	historyPerJob int
+	maxJobs       int
+}
+
+// NewBroker creates a new event broker
+// historyPerJob bounds events kept per job, maxJobs bounds the number of jobs tracked
+func NewBroker(historyPerJob, maxJobs int) *Broker {
+	if historyPerJob <= 0 {
+		historyPerJob = DefaultHistoryPerJob
+	}
+	if maxJobs <= 0 {
+		maxJobs = DefaultMaxJobs
+	}
+
+	return &Broker{
+		histories:     make(map[string]*jobHistory),
+		subscribers:   make(map[*subscriber]struct{}),
+		historyPerJob: historyPerJob,
+		maxJobs:       maxJobs,
+	}
+}
+
+// Publish assigns an ID to the event, records it and delivers it to subscribers
+// Returns the event with its ID set
+func (b *Broker) Publish(event JobEvent) JobEvent {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.nextID++
+	event.ID = b.nextID
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	history, ok := b.histories[event.JobID]
+	if !ok {
+		if len(b.histories) >= b.maxJobs {
+			b.evictOldestLocked()
+		}
+		history = &jobHistory{userID: event.UserID}
+		b.histories[event.JobID] = history
+	}
+	if history.userID == "" {
+		history.userID = event.UserID
+	}
+	if event.UserID == "" {
+		event.UserID = history.userID
+	}
+
+	history.events = append(history.events, event)
+	if len(history.events) > b.historyPerJob {
+		// Copy instead of reslicing so the backing array doesn't grow forever
+		trimmed := make([]JobEvent, b.historyPerJob)
+		copy(trimmed, history.events[len(history.events)-b.historyPerJob:])
+		history.events = trimmed
+	}
+	history.lastUpdate = event.Timestamp
+
+	// Non-blocking fan out - a full buffer means the client fell behind
+	// Closing its channel makes it reconnect and resume from Last-Event-ID
+	for sub := range b.subscribers {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			delete(b.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+
+	return event
+}
+
+// Subscribe registers a subscriber that receives events matching the filter
+// A nil filter receives everything. The returned cancel func must be called
+// when the client goes away - the channel is closed by the broker
+func (b *Broker) Subscribe(filter func(JobEvent) bool) (<-chan JobEvent, func()) {
+	sub := &subscriber{
+		ch:     make(chan JobEvent, subscriberBufferSize),
+		filter: filter,
+	}
+
+	b.mutex.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mutex.Unlock()
+
+	cancel := func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		if _, ok := b.subscribers[sub]; ok {
+			delete(b.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+
+	return sub.ch, cancel
+}
+
+// JobHistory returns the recorded events for a job with an ID greater than afterID
+func (b *Broker) JobHistory(jobID string, afterID uint64) []JobEvent {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	history, ok := b.histories[jobID]
+	if !ok {
+		return nil
+	}
+	return eventsAfter(history.events, afterID)
+}
+
+// History returns the recorded events matching the filter with an ID greater
+// than afterID, ordered by ID
+func (b *Broker) History(filter func(JobEvent) bool, afterID uint64) []JobEvent {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	var result []JobEvent
+	for _, history := range b.histories {
+		for _, event := range eventsAfter(history.events, afterID) {
+			if filter == nil || filter(event) {
+				result = append(result, event)
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// eventsAfter returns a copy of the events with an ID greater than afterID
+func eventsAfter(events []JobEvent, afterID uint64) []JobEvent {
+	idx := sort.Search(len(events), func(i int) bool { return events[i].ID > afterID })
+	if idx == len(events) {
+		return nil
+	}
+	result := make([]JobEvent, len(events)-idx)
+	copy(result, events[idx:])
+	return result
+}
+
+// evictOldestLocked drops the least recently updated job histories
+// Evicting in batches so we don't scan the whole map on every new job
+func (b *Broker) evictOldestLocked() {
+	type jobAge struct {
+		jobID      string
+		lastUpdate time.Time
+	}
+
+	ages := make([]jobAge, 0, len(b.histories))
+	for jobID, history := range b.histories {
+		ages = append(ages, jobAge{jobID: jobID, lastUpdate: history.lastUpdate})
+	}
+	sort.Slice(ages, func(i, j int) bool { return ages[i].lastUpdate.Before(ages[j].lastUpdate) })
+
+	for i := 0; i < len(ages) && i < historyCleanupBatchSize; i++ {
+		delete(b.histories, ages[i].jobID)
+	}
+}
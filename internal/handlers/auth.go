@@ -27,10 +27,11 @@ type AuthHandler struct {
 // Simplified model - production would have more fields
 // Like email, verification status, MFA, etc. - virjilakrum
 type User struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
-	Password string `json:"password,omitempty"` // Never return this in API responses
-	Role     string `json:"role"`
+	ID       string   `json:"id"`
+	Username string   `json:"username"`
+	Password string   `json:"password,omitempty"` // Never return this in API responses
+	Role     string   `json:"role"`
+	Projects []string `json:"projects,omitempty"` // Projects the user can submit jobs for
 }
 
 // LoginRequest represents a login request
@@ -61,12 +62,14 @@ func NewAuthHandler(config *internal.Config) *AuthHandler {
 			Username: "admin",
 			Password: "admin123", // In a real app, this would be hashed
 			Role:     "admin",
+			Projects: []string{"research", "production"},
 		},
 		"user": {
 			ID:       "2",
 			Username: "user",
 			Password: "user123", // In a real app, this would be hashed
 			Role:     "user",
+			Projects: []string{"research"},
 		},
 	}
 
@@ -116,6 +119,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		user.ID,
 		user.Username,
 		user.Role,
+		user.Projects,
 		h.config.JWTSecret,
 		h.config.JWTExpiration,
 	)
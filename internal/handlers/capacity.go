@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/workers"
+)
+
+// WorkerListResponse is the response for the admin worker listing
+type WorkerListResponse struct {
+	Workers []workers.Worker `json:"workers"`
+}
+
+// CapacityHandler reports the GPU hardware workers actually have
+// Clients check it before picking a gpu_type, admins get the per-worker view
+type CapacityHandler struct {
+	registry *workers.Registry
+	logger   internal.LoggerInterface
+}
+
+// NewCapacityHandler creates a new capacity handler
+func NewCapacityHandler(registry *workers.Registry) *CapacityHandler {
+	return &CapacityHandler{
+		registry: registry,
+		logger:   internal.Logger,
+	}
+}
+
+// RegisterRoutes registers the capacity route
+func (h *CapacityHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/capacity", h.GetCapacity)
+}
+
+// RegisterAdminRoutes registers the worker listings
+// Mount behind RequireRole("admin")
+func (h *CapacityHandler) RegisterAdminRoutes(r chi.Router) {
+	r.Get("/workers", h.ListWorkers)
+	r.Get("/workers/{workerID}", h.GetWorker)
+}
+
+// GetCapacity returns total and free GPUs by type across live workers
+func (h *CapacityHandler) GetCapacity(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.registry.Capacity())
+}
+
+// ListWorkers returns every known worker, dead ones included
+func (h *CapacityHandler) ListWorkers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WorkerListResponse{Workers: h.registry.Workers()})
+}
+
+// GetWorker returns a single worker
+func (h *CapacityHandler) GetWorker(w http.ResponseWriter, r *http.Request) {
+	worker, err := h.registry.Worker(chi.URLParam(r, "workerID"))
+	if err != nil {
+		http.Error(w, "Worker not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(worker)
+}
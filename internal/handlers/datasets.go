@@ -0,0 +1,525 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/blob"
+	"siger-api-gateway/internal/middleware"
+	"siger-api-gateway/internal/storage"
+)
+
+// datasetTokenHeader carries the token workers download a job's dataset with
+const datasetTokenHeader = "X-Dataset-Token"
+
+// MaxDatasetSize caps the declared size of a dataset upload (1 TiB)
+const MaxDatasetSize = 1 << 40
+
+// Resumable upload protocol, modelled on tus 1.0
+// Chunks are sent with PATCH as application/offset+octet-stream and the
+// Upload-Offset header; HEAD tells a client where to resume
+const (
+	tusVersion               = "1.0.0"
+	uploadOffsetContentType  = "application/offset+octet-stream"
+	uploadOffsetHeader       = "Upload-Offset"
+	uploadLengthHeader       = "Upload-Length"
+	tusResumableHeader       = "Tus-Resumable"
+	checksumAlgorithmSHA256  = "sha256"
+	datasetUploadPathPattern = "/api/v1/datasets/%s/upload"
+)
+
+// DatasetRequest is the body of POST /datasets
+type DatasetRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Project     string `json:"project,omitempty"` // Share with the project's members
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type,omitempty"`
+	Checksum    string `json:"checksum,omitempty"` // Optional "sha256:<hex>", verified when the upload completes
+}
+
+// DatasetListResponse is the response for GET /datasets
+type DatasetListResponse struct {
+	Datasets []storage.Dataset `json:"datasets"`
+}
+
+// DatasetHandler manages dataset uploads
+// Replaces staging training data elsewhere and pasting URLs into params
+type DatasetHandler struct {
+	datasets *storage.DatasetStore
+	backend  blob.Uploader
+	uploads  sync.Map // Dataset ID -> *sync.Mutex, one chunk at a time per dataset
+	logger   internal.LoggerInterface
+}
+
+// NewDatasetHandler creates a new dataset handler
+func NewDatasetHandler(datasets *storage.DatasetStore, backend blob.Uploader) *DatasetHandler {
+	return &DatasetHandler{
+		datasets: datasets,
+		backend:  backend,
+		logger:   internal.Logger,
+	}
+}
+
+// RegisterRoutes registers the dataset routes
+func (h *DatasetHandler) RegisterRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(h.requireBackend)
+		r.Post("/datasets", h.CreateDataset)
+		r.Get("/datasets", h.ListDatasets)
+		r.Get("/datasets/{datasetID}", h.GetDataset)
+		r.Delete("/datasets/{datasetID}", h.DeleteDataset)
+		r.Head("/datasets/{datasetID}/upload", h.GetUploadOffset)
+	})
+}
+
+// RegisterUploadRoutes registers the chunk upload route
+// Kept apart so it can go outside the request timeout, chunks can be large
+func (h *DatasetHandler) RegisterUploadRoutes(r chi.Router) {
+	r.With(h.requireBackend).Patch("/datasets/{datasetID}/upload", h.UploadChunk)
+}
+
+// RegisterWorkerRoutes registers the route workers download job datasets from
+// Must be registered outside the JWT middleware group, the token is the credential
+func (h *DatasetHandler) RegisterWorkerRoutes(r chi.Router) {
+	r.Get("/jobs/{jobID}/dataset", h.DownloadJobDataset)
+}
+
+// requireBackend rejects dataset requests when no blob store is configured
+func (h *DatasetHandler) requireBackend(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.backend == nil {
+			http.Error(w, "Dataset uploads are not configured", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CreateDataset registers a dataset and returns where to upload its content
+func (h *DatasetHandler) CreateDataset(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok || userID == "" {
+		http.Error(w, "User ID not found in request context", http.StatusUnauthorized)
+		return
+	}
+
+	var req DatasetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, "Dataset name is required", http.StatusBadRequest)
+		return
+	}
+	if req.Size < 1 || req.Size > MaxDatasetSize {
+		http.Error(w, fmt.Sprintf("Dataset size must be between 1 and %d bytes", int64(MaxDatasetSize)), http.StatusBadRequest)
+		return
+	}
+	if req.Project != "" && !middleware.IsProjectMember(r.Context(), req.Project) {
+		http.Error(w, "Forbidden: not a member of project "+req.Project, http.StatusForbidden)
+		return
+	}
+	expected, err := parseChecksum(req.Checksum)
+	if err != nil {
+		http.Error(w, "Invalid checksum: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hashState, err := marshalHash(sha256.New())
+	if err != nil {
+		http.Error(w, "Failed to create dataset: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	datasetID := uuid.New().String()
+	dataset := h.datasets.CreateDataset(storage.Dataset{
+		DatasetID:        datasetID,
+		Name:             req.Name,
+		Description:      req.Description,
+		OwnerID:          userID,
+		Project:          req.Project,
+		Size:             req.Size,
+		ContentType:      req.ContentType,
+		ExpectedChecksum: expected,
+		URI:              h.backend.URI(datasetID),
+		HashState:        hashState,
+	})
+	h.logger.Infof("Dataset created: id=%s name=%s size=%d owner=%s", datasetID, dataset.Name, dataset.Size, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf(datasetUploadPathPattern, datasetID))
+	w.Header().Set(tusResumableHeader, tusVersion)
+	w.Header().Set(uploadOffsetHeader, "0")
+	w.Header().Set(uploadLengthHeader, strconv.FormatInt(dataset.Size, 10))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dataset)
+}
+
+// ListDatasets returns the caller's datasets and those shared with their projects
+func (h *DatasetHandler) ListDatasets(w http.ResponseWriter, r *http.Request) {
+	project := r.URL.Query().Get("project")
+	datasets := h.datasets.ListDatasets(func(d storage.Dataset) bool {
+		if project != "" && d.Project != project {
+			return false
+		}
+		return canReadDataset(r.Context(), d)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DatasetListResponse{Datasets: datasets})
+}
+
+// GetDataset returns a dataset's metadata and upload progress
+func (h *DatasetHandler) GetDataset(w http.ResponseWriter, r *http.Request) {
+	dataset, err := h.datasets.GetDataset(chi.URLParam(r, "datasetID"))
+	if err != nil || !canReadDataset(r.Context(), dataset) {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dataset)
+}
+
+// DeleteDataset removes a dataset and its content
+// Jobs already submitted keep the URI, so delete only when they're done with it
+func (h *DatasetHandler) DeleteDataset(w http.ResponseWriter, r *http.Request) {
+	dataset, ok := h.loadWritableDataset(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.backend.Delete(r.Context(), dataset.DatasetID); err != nil {
+		h.logger.Errorf("Failed to delete dataset content: id=%s error=%v", dataset.DatasetID, err)
+		http.Error(w, "Failed to delete dataset: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.datasets.DeleteDataset(dataset.DatasetID); err != nil {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+	h.uploads.Delete(dataset.DatasetID)
+	h.logger.Infof("Dataset deleted: id=%s", dataset.DatasetID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetUploadOffset tells a client how much of the upload the gateway has
+func (h *DatasetHandler) GetUploadOffset(w http.ResponseWriter, r *http.Request) {
+	dataset, ok := h.loadWritableDataset(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set(tusResumableHeader, tusVersion)
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(dataset.Offset, 10))
+	w.Header().Set(uploadLengthHeader, strconv.FormatInt(dataset.Size, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+// UploadChunk appends a chunk at the offset the client says it's at
+// The offset must match what the gateway has, so a chunk that's resent after
+// a lost response is rejected instead of being written twice
+func (h *DatasetHandler) UploadChunk(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(tusResumableHeader, tusVersion)
+
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), uploadOffsetContentType) {
+		http.Error(w, "Content-Type must be "+uploadOffsetContentType, http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get(uploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "Invalid Upload-Offset header", http.StatusBadRequest)
+		return
+	}
+
+	dataset, ok := h.loadWritableDataset(w, r)
+	if !ok {
+		return
+	}
+
+	lock, _ := h.uploads.LoadOrStore(dataset.DatasetID, &sync.Mutex{})
+	if !lock.(*sync.Mutex).TryLock() {
+		http.Error(w, "Another chunk of this dataset is being uploaded", http.StatusConflict)
+		return
+	}
+	defer lock.(*sync.Mutex).Unlock()
+
+	// Reload under the upload lock, the previous chunk may have just finished
+	dataset, err = h.datasets.GetDataset(dataset.DatasetID)
+	if err != nil {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+	if dataset.Status != storage.DatasetStatusUploading {
+		http.Error(w, "Dataset upload is already complete", http.StatusConflict)
+		return
+	}
+	if offset != dataset.Offset {
+		w.Header().Set(uploadOffsetHeader, strconv.FormatInt(dataset.Offset, 10))
+		http.Error(w, fmt.Sprintf("Upload-Offset %d does not match the uploaded size %d", offset, dataset.Offset), http.StatusConflict)
+		return
+	}
+
+	digest, err := unmarshalHash(dataset.HashState)
+	if err != nil {
+		h.logger.Errorf("Failed to restore dataset checksum state: id=%s error=%v", dataset.DatasetID, err)
+		http.Error(w, "Failed to upload chunk: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Chunks can't run past the declared size
+	body := http.MaxBytesReader(w, r.Body, dataset.Size-dataset.Offset)
+	written, err := h.backend.Append(r.Context(), dataset.DatasetID, offset, io.TeeReader(body, digest))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			http.Error(w, "Chunk runs past the declared dataset size", http.StatusRequestEntityTooLarge)
+		case errors.Is(err, blob.ErrOffsetMismatch):
+			http.Error(w, "Stored upload is out of sync, restart the upload", http.StatusConflict)
+		default:
+			h.logger.Warnf("Dataset chunk upload failed: id=%s offset=%d error=%v", dataset.DatasetID, offset, err)
+			http.Error(w, "Failed to upload chunk: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	hashState, err := marshalHash(digest)
+	if err != nil {
+		http.Error(w, "Failed to upload chunk: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	checksumMismatch := false
+	dataset, err = h.datasets.UpdateDataset(dataset.DatasetID, func(d *storage.Dataset) error {
+		d.Offset = offset + written
+		d.HashState = hashState
+		if d.Offset < d.Size {
+			return nil
+		}
+
+		checksum := checksumAlgorithmSHA256 + ":" + hex.EncodeToString(digest.Sum(nil))
+		if d.ExpectedChecksum != "" && d.ExpectedChecksum != checksum {
+			// Start over, the content on disk is not what the client meant to send
+			fresh, err := marshalHash(sha256.New())
+			if err != nil {
+				return err
+			}
+			d.Offset = 0
+			d.HashState = fresh
+			checksumMismatch = true
+			return nil
+		}
+
+		d.Checksum = checksum
+		d.Status = storage.DatasetStatusReady
+		d.CompletedAt = time.Now().UTC()
+		d.HashState = nil
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to upload chunk: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if checksumMismatch {
+		h.backend.Delete(r.Context(), dataset.DatasetID)
+		h.logger.Warnf("Dataset checksum mismatch, upload discarded: id=%s", dataset.DatasetID)
+		w.Header().Set(uploadOffsetHeader, "0")
+		http.Error(w, "Checksum mismatch: upload discarded, start again from offset 0", http.StatusUnprocessableEntity)
+		return
+	}
+	if dataset.Status == storage.DatasetStatusReady {
+		h.logger.Infof("Dataset upload complete: id=%s size=%d checksum=%s", dataset.DatasetID, dataset.Size, dataset.Checksum)
+	}
+
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(dataset.Offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DownloadJobDataset streams a job's dataset to its worker
+// The token from the job message works while that attempt is active, and
+// range requests let a worker resume an interrupted download
+func (h *DatasetHandler) DownloadJobDataset(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	jobID := chi.URLParam(r, "jobID")
+	token := strings.TrimSpace(r.Header.Get(datasetTokenHeader))
+	if token == "" {
+		http.Error(w, "Missing "+datasetTokenHeader+" header", http.StatusUnauthorized)
+		return
+	}
+
+	grant, err := h.datasets.LookupToken(jobID, token)
+	if err != nil {
+		h.logger.Warnf("Rejected dataset token: job=%s remote=%s", jobID, r.RemoteAddr)
+		http.Error(w, "Invalid dataset token", http.StatusUnauthorized)
+		return
+	}
+	if h.backend == nil {
+		http.Error(w, "Dataset uploads are not configured", http.StatusServiceUnavailable)
+		return
+	}
+	dataset, err := h.datasets.GetDataset(grant.DatasetID)
+	if err != nil {
+		http.Error(w, "Dataset no longer exists", http.StatusGone)
+		return
+	}
+
+	object, err := h.backend.Open(r.Context(), dataset.URI)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			http.Error(w, "Dataset no longer exists", http.StatusGone)
+			return
+		}
+		h.logger.Errorf("Failed to open dataset: id=%s job=%s error=%v", dataset.DatasetID, jobID, err)
+		http.Error(w, "Failed to open dataset: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer object.Body.Close()
+	h.logger.Infof("Dataset download: id=%s job=%s attempt=%d range=%q", dataset.DatasetID, jobID, grant.Attempt, r.Header.Get("Range"))
+
+	contentType := dataset.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", fmt.Sprintf("%q", dataset.Checksum))
+
+	if seeker, ok := object.Body.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, dataset.Name, object.ModTime, seeker)
+		return
+	}
+
+	w.Header().Set("Content-Length", fmt.Sprint(object.Size))
+	if _, err := io.Copy(w, object.Body); err != nil {
+		h.logger.Warnf("Dataset download interrupted: id=%s job=%s error=%v", dataset.DatasetID, jobID, err)
+	}
+}
+
+// loadWritableDataset loads a dataset the caller may upload to or delete
+func (h *DatasetHandler) loadWritableDataset(w http.ResponseWriter, r *http.Request) (storage.Dataset, bool) {
+	dataset, err := h.datasets.GetDataset(chi.URLParam(r, "datasetID"))
+	if err != nil || !canReadDataset(r.Context(), dataset) {
+		http.Error(w, "Dataset not found", http.StatusNotFound)
+		return storage.Dataset{}, false
+	}
+
+	userID, _ := r.Context().Value(middleware.UserIDContextKey).(string)
+	if dataset.OwnerID != userID && !middleware.HasPermission(r.Context(), middleware.PermissionDatasetsAny) {
+		http.Error(w, "Forbidden: only the dataset owner can modify it", http.StatusForbidden)
+		return storage.Dataset{}, false
+	}
+	return dataset, true
+}
+
+// canReadDataset reports whether the caller may see a dataset and use it in jobs
+func canReadDataset(ctx context.Context, dataset storage.Dataset) bool {
+	userID, _ := ctx.Value(middleware.UserIDContextKey).(string)
+	if userID != "" && dataset.OwnerID == userID {
+		return true
+	}
+	if dataset.Project != "" && middleware.IsProjectMember(ctx, dataset.Project) {
+		return true
+	}
+	return middleware.HasPermission(ctx, middleware.PermissionDatasetsAny)
+}
+
+// parseChecksum normalizes a client checksum to "sha256:<hex>"
+// A bare hex digest is taken as SHA-256
+func parseChecksum(checksum string) (string, error) {
+	if checksum == "" {
+		return "", nil
+	}
+
+	digest := strings.ToLower(checksum)
+	if algorithm, value, ok := strings.Cut(digest, ":"); ok {
+		if algorithm != checksumAlgorithmSHA256 {
+			return "", fmt.Errorf("unsupported algorithm %q, only sha256 is supported", algorithm)
+		}
+		digest = value
+	}
+	if decoded, err := hex.DecodeString(digest); err != nil || len(decoded) != sha256.Size {
+		return "", errors.New("must be a hex encoded SHA-256 digest")
+	}
+	return checksumAlgorithmSHA256 + ":" + digest, nil
+}
+
+// marshalHash saves a running hash so it can continue with the next chunk
+func marshalHash(h hash.Hash) ([]byte, error) {
+	return h.(encoding.BinaryMarshaler).MarshalBinary()
+}
+
+// unmarshalHash restores a running SHA-256 saved by marshalHash
+func unmarshalHash(state []byte) (hash.Hash, error) {
+	h := sha256.New()
+	if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// SetDatasetStore sets the store used to check dataset_id on submissions
+// Optional - without it submissions referencing a dataset are rejected
+func (h *JobSubmissionHandler) SetDatasetStore(datasets *storage.DatasetStore) {
+	h.datasets = datasets
+}
+
+// attachDatasetToken gives a job message a fresh token for its dataset
+// Called on every publish like attachSecretsToken, each attempt gets its own
+func (h *JobSubmissionHandler) attachDatasetToken(jobMsg *JobMessage) error {
+	if jobMsg.DatasetID == "" || h.datasets == nil {
+		return nil
+	}
+
+	token, err := h.datasets.IssueToken(jobMsg.DatasetID, jobMsg.JobID, jobMsg.Attempt)
+	if err != nil {
+		return err
+	}
+	jobMsg.DatasetToken = token
+	return nil
+}
+
+// jobDatasetURL returns the path a job's worker downloads its dataset from
+func jobDatasetURL(jobID, datasetID string) string {
+	if datasetID == "" {
+		return ""
+	}
+	return "/api/v1/jobs/" + jobID + "/dataset"
+}
+
+// resolveDataset checks a job's dataset exists, is fully uploaded and the caller may use it
+func (h *JobSubmissionHandler) resolveDataset(ctx context.Context, datasetID string) (storage.Dataset, error) {
+	if h.datasets == nil {
+		return storage.Dataset{}, &requestError{status: http.StatusBadRequest, message: "Datasets are not available"}
+	}
+
+	dataset, err := h.datasets.GetDataset(datasetID)
+	if err != nil || !canReadDataset(ctx, dataset) {
+		return storage.Dataset{}, &requestError{status: http.StatusNotFound, message: "Dataset not found"}
+	}
+	if dataset.Status != storage.DatasetStatusReady {
+		return storage.Dataset{}, &requestError{status: http.StatusConflict, message: "Dataset upload is not complete"}
+	}
+	return dataset, nil
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"context"
+
+	"siger-api-gateway/internal/middleware"
+	"siger-api-gateway/internal/storage"
+)
+
+// canAccessJob reports whether the authenticated user may see and act on a job
+// Owners, members of the job's project and roles holding jobs:*:any qualify
+// Callers answer 404 otherwise so job IDs can't be probed
+func canAccessJob(ctx context.Context, job storage.JobInfo) bool {
+	return canAccessOwner(ctx, job.UserID, job.Project)
+}
+
+// canAccessOwner applies the canAccessJob rule to a job's owner and project
+// Job events only carry those, not the whole job
+func canAccessOwner(ctx context.Context, ownerID, project string) bool {
+	userID, _ := ctx.Value(middleware.UserIDContextKey).(string)
+	if userID != "" && ownerID == userID {
+		return true
+	}
+	if project != "" && middleware.IsProjectMember(ctx, project) {
+		return true
+	}
+	return middleware.HasPermission(ctx, middleware.PermissionJobsAny)
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/storage"
+	"siger-api-gateway/internal/workers"
+)
+
+// Admission modes for jobs no node can run
+const (
+	AdmissionReject = "reject"
+	AdmissionWarn   = "warn"
+	AdmissionOff    = "off"
+)
+
+// DefaultReleaseInterval is how often held jobs are checked against free GPUs
+// Half the heartbeat interval, so freed GPUs are picked up within one beat
+const DefaultReleaseInterval = workers.DefaultHeartbeatInterval / 2
+
+// Job messages for the gateway-side hold
+const (
+	heldJobMessage     = "Held by the gateway until enough GPUs are free"
+	releasedJobMessage = "Released by the gateway, waiting for a worker"
+)
+
+// SetWorkerRegistry sets the registry submissions are checked against
+// Optional - without it only the static capacity config is used
+func (h *JobSubmissionHandler) SetWorkerRegistry(registry *workers.Registry) {
+	h.workers = registry
+}
+
+// SetCapacityConfig sets how submissions are checked against the cluster's capacity
+func (h *JobSubmissionHandler) SetCapacityConfig(config internal.CapacityConfig) {
+	h.capacity = config
+}
+
+// checkCapacity rejects or warns about jobs no node can run
+// Returns the warnings to send back when the admission mode is "warn"
+func (h *JobSubmissionHandler) checkCapacity(gpuType GPUType, gpuCount int) ([]string, error) {
+	if h.capacity.Admission == AdmissionOff || gpuType == "" {
+		return nil, nil
+	}
+	largest, known := h.largestNode(string(gpuType))
+	if !known {
+		return nil, nil
+	}
+
+	var problem string
+	switch {
+	case largest == 0:
+		problem = fmt.Sprintf("No workers with %s GPUs are registered", gpuType)
+	case gpuCount > largest:
+		problem = fmt.Sprintf("%d %s GPUs requested but the largest node has %d", gpuCount, gpuType, largest)
+	default:
+		return nil, nil
+	}
+
+	if h.capacity.Admission == AdmissionWarn {
+		return []string{problem + ", the job may never start"}, nil
+	}
+	return nil, &requestError{status: http.StatusBadRequest, message: problem + ", see GET /api/v1/capacity"}
+}
+
+// largestNode returns the most GPUs of a type one node offers
+// Heartbeats win over the static config once the registry has heard from
+// the cluster; known is false when neither says anything
+func (h *JobSubmissionHandler) largestNode(gpuType string) (largest int, known bool) {
+	if h.workers != nil && h.workers.Ready() {
+		return h.workers.LargestNode(gpuType), true
+	}
+	if len(h.capacity.Static) == 0 {
+		return 0, false
+	}
+
+	for t, static := range h.capacity.Static {
+		if static.Nodes > 0 && static.GPUsPerNode > largest && workers.MatchesGPUType(t, gpuType) {
+			largest = static.GPUsPerNode
+		}
+	}
+	return largest, true
+}
+
+// holding reports whether saturated jobs are held in the gateway right now
+// Only heartbeats say how many GPUs are free, so nothing is held without
+// them unless the dispatcher holds every job anyway
+func (h *JobSubmissionHandler) holding() bool {
+	return h.dispatching() || (h.capacity.HoldWhenSaturated && h.workers != nil && h.workers.Ready())
+}
+
+// holdJob holds a requeued job so it goes through admission again
+// Retries, resumes and requeued preempted jobs wait their turn like new jobs,
+// instead of skipping the dispatcher or overbooking a saturated cluster
+func (h *JobSubmissionHandler) holdJob(job storage.JobInfo) {
+	message := heldJobMessage
+	if h.dispatching() {
+		message = dispatchQueuedMessage
+	}
+	if _, err := h.jobStore.HoldJob(job.JobID, job.Attempt, message); err != nil {
+		h.logger.Infof("Skipping hold of job that moved on: id=%s attempt=%d error=%v", job.JobID, job.Attempt, err)
+		return
+	}
+	h.logger.Infof("Job held for admission: id=%s attempt=%d", job.JobID, job.Attempt)
+	h.wakeDispatcher()
+}
+
+// holdSaturatedJobs marks the jobs that don't fit in the free GPUs as held
+// Jobs already held come first, so new jobs can't grab GPUs they're waiting
+// for. Jobs in a batch take GPUs in order, a batch may be partly held.
+// The dispatcher holds them all, it decides who goes first
+func (h *JobSubmissionHandler) holdSaturatedJobs(jobs []pendingJob) {
+	if !h.holding() {
+		return
+	}
+	if h.dispatching() {
+		for i := range jobs {
+			jobs[i].info.Held = true
+			jobs[i].info.Message = dispatchQueuedMessage
+		}
+		return
+	}
+
+	available := h.availableGPUs(true)
+	for i := range jobs {
+		if takeGPUs(available, jobs[i].info.GPUType, jobs[i].info.GPUCount, false) {
+			continue
+		}
+		jobs[i].info.Held = true
+		jobs[i].info.Message = heldJobMessage
+	}
+}
+
+// availableGPUs returns free GPUs by type, less what published jobs that
+// haven't started yet will take - heartbeats don't know about those.
+// With includeHeld the held jobs' GPUs are taken as well
+func (h *JobSubmissionHandler) availableGPUs(includeHeld bool) map[string]int {
+	available := make(map[string]int)
+	for gpuType, gpu := range h.workers.Capacity().GPUTypes {
+		available[gpuType] = gpu.Free
+	}
+
+	for _, job := range h.jobStore.JobsByStatus(storage.JobStatusQueued, storage.JobStatusScheduled) {
+		if !job.Held || includeHeld {
+			takeGPUs(available, job.GPUType, job.GPUCount, true)
+		}
+	}
+	return available
+}
+
+// takeGPUs takes count GPUs of a type from available if they're there
+// "any" takes from the type with the most free GPUs. With force the GPUs
+// are taken even if that leaves a negative count, for demand already out
+func takeGPUs(available map[string]int, gpuType string, count int, force bool) bool {
+	best, bestFree := "", 0
+	for t, free := range available {
+		if workers.MatchesGPUType(t, gpuType) && (best == "" || free > bestFree) {
+			best, bestFree = t, free
+		}
+	}
+	if best == "" || (bestFree < count && !force) {
+		return false
+	}
+	available[best] -= count
+	return true
+}
+
+// RunAdmission releases held jobs as GPUs free up until ctx is done
+// With the dispatcher on, new submissions trigger a round right away
+func (h *JobSubmissionHandler) RunAdmission(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReleaseInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.requeuePreemptedJobs()
+			h.releaseHeldJobs()
+		case <-h.dispatchWake:
+			h.releaseHeldJobs()
+		}
+	}
+}
+
+// releaseHeldJobs publishes held jobs that fit in the free GPUs
+// Highest priority goes first; smaller jobs behind a job that doesn't fit
+// still go, so a big job doesn't block the queue - unless it preempted
+// jobs, then their GPUs are kept for it. If the registry lost all workers
+// everything is released to wait in JetStream instead
+func (h *JobSubmissionHandler) releaseHeldJobs() {
+	if h.dispatching() {
+		h.dispatchHeldJobs()
+		return
+	}
+
+	held := h.jobStore.HeldJobs()
+	if len(held) == 0 {
+		return
+	}
+
+	var available map[string]int
+	if h.holding() {
+		available = h.availableGPUs(false)
+	}
+	reserved := make(map[string]bool)
+	for _, job := range held {
+		if reserved[job.GPUType] {
+			continue
+		}
+		if available != nil && !takeGPUs(available, job.GPUType, job.GPUCount, false) {
+			if h.preemptFor(job, available) {
+				reserved[job.GPUType] = true
+			}
+			continue
+		}
+		h.releaseJob(job.JobID)
+	}
+}
+
+// releaseJob clears a job's hold and publishes it
+// Reports whether the job went out
+func (h *JobSubmissionHandler) releaseJob(jobID string) bool {
+	released, err := h.jobStore.ReleaseHeldJob(jobID, releasedJobMessage)
+	if err != nil {
+		return false // Cancelled, expired or paused while held
+	}
+
+	var jobMsg JobMessage
+	if err := json.Unmarshal(released.Payload, &jobMsg); err != nil {
+		h.logger.Errorf("Failed to decode stored job message for release: id=%s error=%v", jobID, err)
+		return false
+	}
+	jobMsg.Attempt = released.Attempt
+	jobMsg.Checkpoint = released.Checkpoint // Held retries and resumes start from it
+	jobMsg.Timestamp = time.Now().UTC()
+
+	if err := h.publishJob(jobMsg); err != nil {
+		h.jobStore.TransitionJob(jobID, storage.JobStatusFailed, "Job could not be published: "+err.Error(), "")
+		return false
+	}
+
+	h.logger.Infof("Held job released: id=%s gpu=%s count=%d waited=%s",
+		jobID, released.GPUType, released.GPUCount, time.Since(released.SubmittedAt).Round(time.Second))
+	return true
+}
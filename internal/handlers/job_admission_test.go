@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTakeGPUs(t *testing.T) {
+	tests := []struct {
+		name      string
+		available map[string]int
+		gpuType   string
+		count     int
+		force     bool
+		want      bool
+		left      map[string]int
+	}{
+		{
+			name:      "fits",
+			available: map[string]int{"A100": 4, "T4": 2},
+			gpuType:   "A100",
+			count:     3,
+			want:      true,
+			left:      map[string]int{"A100": 1, "T4": 2},
+		},
+		{
+			name:      "type is case insensitive",
+			available: map[string]int{"A100": 4},
+			gpuType:   "a100",
+			count:     4,
+			want:      true,
+			left:      map[string]int{"A100": 0},
+		},
+		{
+			name:      "not enough free",
+			available: map[string]int{"A100": 2},
+			gpuType:   "A100",
+			count:     4,
+			want:      false,
+			left:      map[string]int{"A100": 2},
+		},
+		{
+			name:      "force takes more than is free",
+			available: map[string]int{"A100": 2},
+			gpuType:   "A100",
+			count:     4,
+			force:     true,
+			want:      true,
+			left:      map[string]int{"A100": -2},
+		},
+		{
+			name:      "unknown type",
+			available: map[string]int{"A100": 4},
+			gpuType:   "H100",
+			count:     1,
+			force:     true,
+			want:      false,
+			left:      map[string]int{"A100": 4},
+		},
+		{
+			name:      "any takes from the type with the most free",
+			available: map[string]int{"A100": 1, "T4": 3},
+			gpuType:   "any",
+			count:     2,
+			want:      true,
+			left:      map[string]int{"A100": 1, "T4": 1},
+		},
+		{
+			name:      "any doesn't split across types",
+			available: map[string]int{"A100": 2, "T4": 2},
+			gpuType:   "any",
+			count:     3,
+			want:      false,
+			left:      map[string]int{"A100": 2, "T4": 2},
+		},
+		{
+			name:      "forced any goes negative on the type with the most free",
+			available: map[string]int{"A100": 1, "T4": 2},
+			gpuType:   "any",
+			count:     3,
+			force:     true,
+			want:      true,
+			left:      map[string]int{"A100": 1, "T4": -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := takeGPUs(tt.available, tt.gpuType, tt.count, tt.force); got != tt.want {
+				t.Errorf("takeGPUs() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.available, tt.left) {
+				t.Errorf("available = %v, want %v", tt.available, tt.left)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/go-chi/chi/v5"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/blob"
+	"siger-api-gateway/internal/storage"
+)
+
+// ArtifactResponse is an artifact descriptor with the gateway URL to download it from
+type ArtifactResponse struct {
+	storage.JobArtifact
+	DownloadURL string `json:"download_url"`
+}
+
+// JobArtifactsResponse is the response for GET /jobs/{jobID}/artifacts
+type JobArtifactsResponse struct {
+	JobID     string             `json:"job_id"`
+	Status    string             `json:"status"`
+	Artifacts []ArtifactResponse `json:"artifacts"`
+}
+
+// ArtifactHandler lists job outputs and streams them from blob storage
+// Users get their model weights through the gateway with the same auth as
+// the rest of the API instead of needing bucket credentials
+type ArtifactHandler struct {
+	jobStore *storage.JobStore
+	backend  blob.Backend
+	logger   internal.LoggerInterface
+}
+
+// NewArtifactHandler creates a new artifact handler
+// backend may be nil, then artifacts can be listed but not downloaded
+func NewArtifactHandler(jobStore *storage.JobStore, backend blob.Backend) *ArtifactHandler {
+	return &ArtifactHandler{
+		jobStore: jobStore,
+		backend:  backend,
+		logger:   internal.Logger,
+	}
+}
+
+// RegisterRoutes registers the artifact listing route
+func (h *ArtifactHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/jobs/{jobID}/artifacts", h.ListArtifacts)
+}
+
+// RegisterDownloadRoutes registers the artifact download route
+// Names may contain slashes, e.g. "checkpoints/final.pt". Kept apart so it
+// can go outside the request timeout, weights take a while to send
+func (h *ArtifactHandler) RegisterDownloadRoutes(r chi.Router) {
+	r.Get("/jobs/{jobID}/artifacts/*", h.DownloadArtifact)
+}
+
+// ListArtifacts returns the artifacts reported for a job
+func (h *ArtifactHandler) ListArtifacts(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.loadJob(w, r)
+	if !ok {
+		return
+	}
+
+	resp := JobArtifactsResponse{
+		JobID:     job.JobID,
+		Status:    string(job.Status),
+		Artifacts: make([]ArtifactResponse, 0, len(job.Artifacts)),
+	}
+	for _, artifact := range job.Artifacts {
+		resp.Artifacts = append(resp.Artifacts, ArtifactResponse{
+			JobArtifact: artifact,
+			DownloadURL: artifactDownloadURL(job.JobID, artifact.Name),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DownloadArtifact streams an artifact from the blob backend
+// Range requests are supported when the backend can seek, so interrupted
+// downloads of large weights can be resumed
+func (h *ArtifactHandler) DownloadArtifact(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.loadJob(w, r)
+	if !ok {
+		return
+	}
+
+	name := chi.URLParam(r, "*")
+	artifact, ok := job.Artifact(name)
+	if !ok {
+		http.Error(w, "Artifact not found", http.StatusNotFound)
+		return
+	}
+
+	if h.backend == nil {
+		http.Error(w, "Artifact downloads are not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	object, err := h.backend.Open(r.Context(), artifact.URI)
+	if err != nil {
+		switch {
+		case errors.Is(err, blob.ErrNotFound):
+			http.Error(w, "Artifact content not found in storage", http.StatusNotFound)
+		case errors.Is(err, blob.ErrUnsupportedURI):
+			h.logger.Warnf("Artifact URI not served by the backend: job=%s name=%s error=%v", job.JobID, name, err)
+			http.Error(w, "Artifact storage location is not supported", http.StatusNotImplemented)
+		default:
+			h.logger.Errorf("Failed to open artifact: job=%s name=%s error=%v", job.JobID, name, err)
+			http.Error(w, "Failed to open artifact: "+err.Error(), http.StatusBadGateway)
+		}
+		return
+	}
+	defer object.Body.Close()
+
+	contentType := artifact.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(name)}))
+	if artifact.Checksum != "" {
+		w.Header().Set("ETag", fmt.Sprintf("%q", artifact.Checksum))
+	}
+
+	if seeker, ok := object.Body.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, path.Base(name), object.ModTime, seeker)
+		return
+	}
+
+	w.Header().Set("Content-Length", fmt.Sprint(object.Size))
+	if _, err := io.Copy(w, object.Body); err != nil {
+		h.logger.Warnf("Artifact download interrupted: job=%s name=%s error=%v", job.JobID, name, err)
+	}
+}
+
+// loadJob looks up the job from the URL and checks the caller may access it
+func (h *ArtifactHandler) loadJob(w http.ResponseWriter, r *http.Request) (storage.JobInfo, bool) {
+	jobID := chi.URLParam(r, "jobID")
+	if jobID == "" {
+		http.Error(w, "Job ID is required", http.StatusBadRequest)
+		return storage.JobInfo{}, false
+	}
+
+	job, err := h.jobStore.GetJob(jobID)
+	if err != nil || !canAccessJob(r.Context(), job) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return storage.JobInfo{}, false
+	}
+	return job, true
+}
+
+// artifactDownloadURL returns the gateway path an artifact is downloaded from
+func artifactDownloadURL(jobID, name string) string {
+	return "/api/v1/jobs/" + url.PathEscape(jobID) + "/artifacts/" + (&url.URL{Path: name}).EscapedPath()
+}
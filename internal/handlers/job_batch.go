@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"siger-api-gateway/internal/storage"
+)
+
+// MaxBatchSize caps how many jobs one batch or array submission may create
+// A 1000-point sweep is already a lot of GPU time to queue in one call
+const MaxBatchSize = 1000
+
+// BatchJobRequest is the body of POST /jobs/batch
+// Either a list of independent jobs or a job array, not both
+type BatchJobRequest struct {
+	Jobs  []JobRequest     `json:"jobs,omitempty"`
+	Array *JobArrayRequest `json:"array,omitempty"`
+}
+
+// JobArrayRequest is a job template plus a parameter grid
+// Every combination of grid values is merged into the template's params
+// and submitted as a child job sharing the array ID
+type JobArrayRequest struct {
+	JobRequest
+	Grid map[string][]any `json:"grid"`
+}
+
+// BatchJobResponse is the response for POST /jobs/batch
+type BatchJobResponse struct {
+	ArrayID string        `json:"array_id,omitempty"`
+	Jobs    []JobResponse `json:"jobs"`
+}
+
+// JobArrayResponse is the aggregated status of a job array
+type JobArrayResponse struct {
+	ArrayID  string         `json:"array_id"`
+	Status   string         `json:"status"`
+	Total    int            `json:"total"`
+	Counts   map[string]int `json:"counts"`   // Children per status
+	Progress float64        `json:"progress"` // Average over children, finished ones count as 100
+	Jobs     any            `json:"jobs"`
+}
+
+// SubmitBatch submits many jobs at once, atomically
+// All jobs are validated and admitted against quotas before any is stored,
+// so a bad entry or a quota miss rejects the whole batch
+func (h *JobSubmissionHandler) SubmitBatch(w http.ResponseWriter, r *http.Request) {
+	var batchReq BatchJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&batchReq); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var requests []JobRequest
+	var arrayID string
+	switch {
+	case len(batchReq.Jobs) > 0 && batchReq.Array != nil:
+		http.Error(w, "Specify either jobs or array, not both", http.StatusBadRequest)
+		return
+	case len(batchReq.Jobs) > 0:
+		requests = batchReq.Jobs
+	case batchReq.Array != nil:
+		// Resolve the template once so every child uses the same version
+		base, err := h.applyTemplate(r.Context(), batchReq.Array.JobRequest)
+		if err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		batchReq.Array.JobRequest = base
+
+		expanded, err := expandJobArray(*batchReq.Array)
+		if err != nil {
+			http.Error(w, "Invalid array: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests = expanded
+		arrayID = uuid.New().String()
+	default:
+		http.Error(w, "At least one job is required", http.StatusBadRequest)
+		return
+	}
+
+	if len(requests) > MaxBatchSize {
+		http.Error(w, fmt.Sprintf("Batch too large: %d jobs, at most %d allowed", len(requests), MaxBatchSize),
+			http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	jobs := make([]pendingJob, 0, len(requests))
+	for i, jobReq := range requests {
+		job, err := h.newPendingJob(r.Context(), jobReq, now)
+		if err != nil {
+			if reqErr, ok := err.(*requestError); ok {
+				http.Error(w, fmt.Sprintf("Job %d: %s", i, reqErr.message), reqErr.status)
+				return
+			}
+			writeRequestError(w, err)
+			return
+		}
+		if arrayID != "" {
+			job.info.ArrayID, job.msg.ArrayID = arrayID, arrayID
+			job.info.ArrayIndex, job.msg.ArrayIndex = i, i
+		}
+		jobs = append(jobs, job)
+	}
+
+	publishErrs, ok := h.enqueueJobs(w, r, jobs)
+	if !ok {
+		return
+	}
+
+	resp := BatchJobResponse{
+		ArrayID: arrayID,
+		Jobs:    make([]JobResponse, 0, len(jobs)),
+	}
+	for i, job := range jobs {
+		jobResp := job.response(now, "Job submitted successfully")
+		if publishErrs[i] != nil {
+			jobResp.Status = string(storage.JobStatusFailed)
+			jobResp.Message = "Job could not be published: " + publishErrs[i].Error()
+		}
+		resp.Jobs = append(resp.Jobs, jobResp)
+	}
+
+	h.logger.Infof("Batch submitted: jobs=%d array=%s", len(jobs), arrayID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetJobArray returns the aggregated status of a job array and its children
+func (h *JobSubmissionHandler) GetJobArray(w http.ResponseWriter, r *http.Request) {
+	view, ok := jobView(r, JobViewCompact)
+	if !ok {
+		http.Error(w, "Invalid view: must be compact or full", http.StatusBadRequest)
+		return
+	}
+
+	arrayID, jobs, ok := h.loadJobArray(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newJobArrayResponse(arrayID, jobs, view))
+}
+
+// CancelJobArray cancels every child of a job array that hasn't finished yet
+func (h *JobSubmissionHandler) CancelJobArray(w http.ResponseWriter, r *http.Request) {
+	arrayID, jobs, ok := h.loadJobArray(w, r)
+	if !ok {
+		return
+	}
+
+	cancelled := 0
+	for _, job := range jobs {
+		if job.Status.IsTerminal() {
+			continue
+		}
+		if _, err := h.cancelJob(job.JobID, "Job cancellation requested", ""); err != nil {
+			if err != storage.ErrJobFinished {
+				h.logger.Warnf("Failed to cancel array job: array=%s id=%s error=%v", arrayID, job.JobID, err)
+			}
+			continue
+		}
+		cancelled++
+	}
+	h.logger.Infof("Job array cancellation requested: array=%s cancelled=%d", arrayID, cancelled)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newJobArrayResponse(arrayID, h.jobStore.ArrayJobs(arrayID), JobViewCompact))
+}
+
+// loadJobArray looks up the array from the URL and checks the caller may access it
+// Children share owner and project, so checking the first one is enough
+func (h *JobSubmissionHandler) loadJobArray(w http.ResponseWriter, r *http.Request) (string, []storage.JobInfo, bool) {
+	arrayID := chi.URLParam(r, "arrayID")
+	if arrayID == "" {
+		http.Error(w, "Array ID is required", http.StatusBadRequest)
+		return "", nil, false
+	}
+
+	jobs := h.jobStore.ArrayJobs(arrayID)
+	if len(jobs) == 0 || !canAccessJob(r.Context(), jobs[0]) {
+		http.Error(w, "Job array not found", http.StatusNotFound)
+		return "", nil, false
+	}
+	return arrayID, jobs, true
+}
+
+// newJobArrayResponse aggregates the children of an array
+func newJobArrayResponse(arrayID string, jobs []storage.JobInfo, view string) JobArrayResponse {
+	resp := JobArrayResponse{
+		ArrayID: arrayID,
+		Total:   len(jobs),
+		Counts:  make(map[string]int),
+		Jobs:    renderJobs(jobs, view),
+	}
+
+	var progress float64
+	for _, job := range jobs {
+		resp.Counts[string(job.Status)]++
+		if job.Status.IsTerminal() {
+			progress += 100
+		} else {
+			progress += job.Progress
+		}
+	}
+	if len(jobs) > 0 {
+		resp.Progress = progress / float64(len(jobs))
+	}
+	resp.Status = string(aggregateArrayStatus(resp.Counts, len(jobs)))
+
+	return resp
+}
+
+// aggregateArrayStatus summarizes the children's statuses into one
+// Unfinished arrays report the furthest along active state; finished arrays
+// are completed only if every child completed, failed if any child failed
+func aggregateArrayStatus(counts map[string]int, total int) storage.JobStatus {
+	finished := counts[string(storage.JobStatusCompleted)] +
+		counts[string(storage.JobStatusFailed)] +
+		counts[string(storage.JobStatusCancelled)] +
+		counts[string(storage.JobStatusExpired)]
+
+	if finished < total {
+		switch {
+		case counts[string(storage.JobStatusCancelling)] > 0:
+			return storage.JobStatusCancelling
+		case counts[string(storage.JobStatusProcessing)] > 0, counts[string(storage.JobStatusScheduled)] > 0:
+			return storage.JobStatusProcessing
+		case counts[string(storage.JobStatusPaused)] == total-finished:
+			return storage.JobStatusPaused
+		default:
+			return storage.JobStatusQueued
+		}
+	}
+
+	switch {
+	case counts[string(storage.JobStatusCompleted)] == total:
+		return storage.JobStatusCompleted
+	case counts[string(storage.JobStatusFailed)] > 0:
+		return storage.JobStatusFailed
+	case counts[string(storage.JobStatusExpired)] > 0:
+		return storage.JobStatusExpired
+	default:
+		return storage.JobStatusCancelled
+	}
+}
+
+// expandJobArray expands the parameter grid into one job request per combination
+// Grid keys are expanded in sorted order so array indexes are stable
+func expandJobArray(array JobArrayRequest) ([]JobRequest, error) {
+	if err := array.JobRequest.Validate(); err != nil {
+		return nil, err
+	}
+	if len(array.Grid) == 0 {
+		return nil, fmt.Errorf("grid must have at least one parameter")
+	}
+
+	base := map[string]any{}
+	if array.Params != nil {
+		params, ok := array.Params.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("params must be an object to combine with a grid")
+		}
+		base = params
+	}
+
+	keys := make([]string, 0, len(array.Grid))
+	total := 1
+	for key, values := range array.Grid {
+		if len(values) == 0 {
+			return nil, fmt.Errorf("grid parameter %q has no values", key)
+		}
+		total *= len(values)
+		if total > MaxBatchSize {
+			return nil, fmt.Errorf("grid expands to more than %d jobs", MaxBatchSize)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	requests := make([]JobRequest, 0, total)
+	for i := 0; i < total; i++ {
+		params := make(map[string]any, len(base)+len(keys))
+		for k, v := range base {
+			params[k] = v
+		}
+
+		// Last key varies fastest, like nested loops in key order
+		n := i
+		for k := len(keys) - 1; k >= 0; k-- {
+			values := array.Grid[keys[k]]
+			params[keys[k]] = values[n%len(values)]
+			n /= len(values)
+		}
+
+		jobReq := array.JobRequest
+		jobReq.Name = fmt.Sprintf("%s[%d]", array.Name, i)
+		jobReq.Params = params
+		requests = append(requests, jobReq)
+	}
+
+	return requests, nil
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestExpandJobArray(t *testing.T) {
+	base := JobRequest{Type: JobTypeAITraining, Name: "sweep", GPUType: "A100", GPUCount: 1}
+
+	tests := []struct {
+		name    string
+		params  any
+		grid    map[string][]any
+		want    []map[string]any
+		wantErr bool
+	}{
+		{
+			name: "single parameter",
+			grid: map[string][]any{"lr": {0.1, 0.01}},
+			want: []map[string]any{{"lr": 0.1}, {"lr": 0.01}},
+		},
+		{
+			name: "keys in sorted order, last varies fastest",
+			grid: map[string][]any{"lr": {0.1, 0.01}, "batch": {16, 32}},
+			want: []map[string]any{
+				{"batch": 16, "lr": 0.1},
+				{"batch": 16, "lr": 0.01},
+				{"batch": 32, "lr": 0.1},
+				{"batch": 32, "lr": 0.01},
+			},
+		},
+		{
+			name:   "base params are kept and overridden",
+			params: map[string]any{"epochs": 3, "lr": 1.0},
+			grid:   map[string][]any{"lr": {0.1}},
+			want:   []map[string]any{{"epochs": 3, "lr": 0.1}},
+		},
+		{
+			name:    "empty grid",
+			grid:    map[string][]any{},
+			wantErr: true,
+		},
+		{
+			name:    "parameter without values",
+			grid:    map[string][]any{"lr": {}},
+			wantErr: true,
+		},
+		{
+			name:    "params that aren't an object",
+			params:  []any{1, 2},
+			grid:    map[string][]any{"lr": {0.1}},
+			wantErr: true,
+		},
+		{
+			name:    "too many combinations",
+			grid:    map[string][]any{"a": make([]any, 100), "b": make([]any, 100)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := base
+			req.Params = tt.params
+			requests, err := expandJobArray(JobArrayRequest{JobRequest: req, Grid: tt.grid})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(requests) != len(tt.want) {
+				t.Fatalf("got %d jobs, want %d", len(requests), len(tt.want))
+			}
+			for i, jobReq := range requests {
+				if want := fmt.Sprintf("sweep[%d]", i); jobReq.Name != want {
+					t.Errorf("job %d name = %s, want %s", i, jobReq.Name, want)
+				}
+				if !reflect.DeepEqual(jobReq.Params, tt.want[i]) {
+					t.Errorf("job %d params = %v, want %v", i, jobReq.Params, tt.want[i])
+				}
+			}
+		})
+	}
+}
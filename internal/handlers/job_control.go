@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"siger-api-gateway/internal/storage"
+)
+
+// Job control actions sent on jobs.control.<jobID>
+const (
+	JobControlPause   = "pause"
+	JobControlResume  = "resume"
+	JobControlPreempt = "preempt" // Checkpoint and stop, the gateway requeues the job later
+)
+
+// JobControlMessage asks the worker running a job to pause, resume or give up its GPUs
+// Sent on a per-job subject so workers only subscribe to the jobs they run
+type JobControlMessage struct {
+	JobID      string    `json:"job_id"`
+	Action     string    `json:"action"`
+	Checkpoint string    `json:"checkpoint,omitempty"` // Where a resumed job starts from
+	Attempt    int       `json:"attempt"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// PauseJob asks for a job to give its GPUs back without losing progress
+// Jobs that haven't started are paused right away, and a queued job's message
+// already in JetStream is cancelled so no worker starts it. Running jobs are
+// asked to checkpoint and stop; they stay processing until the worker reports
+// paused with the checkpoint it wrote
+func (h *JobSubmissionHandler) PauseJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.loadControlledJob(w, r)
+	if !ok {
+		return
+	}
+
+	message := "Pause requested"
+	switch job.Status {
+	case storage.JobStatusQueued, storage.JobStatusScheduled:
+		// Held jobs were never published, and the relay drops messages still in the outbox
+		published := job.Status == storage.JobStatusQueued && !job.Held
+		paused, err := h.jobStore.TransitionJob(job.JobID, storage.JobStatusPaused, "Job paused", "")
+		if err != nil {
+			h.writeControlError(w, paused, err, "paused")
+			return
+		}
+		if published {
+			if err := h.publishCancel(job, "paused"); err != nil {
+				h.logger.Warnf("Failed to cancel queued message of paused job: id=%s error=%v", job.JobID, err)
+			}
+		}
+		job = paused
+		message = "Job paused"
+	case storage.JobStatusProcessing:
+	default:
+		http.Error(w, "Job is "+string(job.Status)+", only queued or running jobs can be paused", http.StatusConflict)
+		return
+	}
+
+	if err := h.publishControl(job, JobControlPause); err != nil {
+		http.Error(w, "Failed to pause job: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.logger.Infof("Job pause requested: id=%s status=%s", job.JobID, job.Status)
+
+	resp := JobResponse{
+		JobID:     job.JobID,
+		Status:    string(job.Status),
+		Timestamp: time.Now().UTC(),
+		Message:   message,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ResumeJob requeues a paused job as a new attempt starting from its last checkpoint
+func (h *JobSubmissionHandler) ResumeJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.loadControlledJob(w, r)
+	if !ok {
+		return
+	}
+	if job.Status != storage.JobStatusPaused {
+		http.Error(w, "Job is "+string(job.Status)+", only paused jobs can be resumed", http.StatusConflict)
+		return
+	}
+
+	message := "Job resumed"
+	if job.Checkpoint != "" {
+		message = "Job resumed from checkpoint " + job.Checkpoint
+	}
+	resumed, err := h.jobStore.TransitionJob(job.JobID, storage.JobStatusQueued, message, "resumed")
+	if err != nil {
+		h.writeControlError(w, resumed, err, "resumed")
+		return
+	}
+
+	if err := h.publishControl(resumed, JobControlResume); err != nil {
+		h.logger.Warnf("Failed to publish job resume control message: id=%s error=%v", job.JobID, err)
+	}
+	if err := h.publishResumedJob(resumed); err != nil {
+		http.Error(w, "Failed to resume job: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.logger.Infof("Job resumed: id=%s attempt=%d checkpoint=%q", resumed.JobID, resumed.Attempt, resumed.Checkpoint)
+
+	resp := JobResponse{
+		JobID:     resumed.JobID,
+		Status:    string(resumed.Status),
+		Timestamp: time.Now().UTC(),
+		Message:   message,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// loadControlledJob looks up the job from the URL and checks the caller may access it
+func (h *JobSubmissionHandler) loadControlledJob(w http.ResponseWriter, r *http.Request) (storage.JobInfo, bool) {
+	jobID := chi.URLParam(r, "jobID")
+	if jobID == "" {
+		http.Error(w, "Job ID is required", http.StatusBadRequest)
+		return storage.JobInfo{}, false
+	}
+
+	job, err := h.jobStore.GetJob(jobID)
+	if err != nil || !canAccessJob(r.Context(), job) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return storage.JobInfo{}, false
+	}
+	return job, true
+}
+
+// writeControlError reports a pause or resume that lost a race with a status update
+func (h *JobSubmissionHandler) writeControlError(w http.ResponseWriter, job storage.JobInfo, err error, action string) {
+	if errors.Is(err, storage.ErrInvalidTransition) {
+		http.Error(w, "Job is "+string(job.Status)+", cannot be "+action, http.StatusConflict)
+		return
+	}
+	http.Error(w, "Failed to update job: "+err.Error(), http.StatusInternalServerError)
+}
+
+// publishControl sends a control message to the worker running the job
+func (h *JobSubmissionHandler) publishControl(job storage.JobInfo, action string) error {
+	if h.natsClient == nil {
+		h.logger.Warnf("NATS client not available, job control message not published: id=%s action=%s", job.JobID, action)
+		return nil
+	}
+
+	controlMsg := JobControlMessage{
+		JobID:      job.JobID,
+		Action:     action,
+		Checkpoint: job.Checkpoint,
+		Attempt:    job.Attempt,
+		Timestamp:  time.Now().UTC(),
+	}
+	if err := h.natsClient.Publish("jobs.control."+job.JobID, controlMsg); err != nil {
+		h.logger.Errorf("Failed to publish job control message: id=%s action=%s error=%v", job.JobID, action, err)
+		return err
+	}
+	return nil
+}
+
+// publishResumedJob republishes the stored job message for the resumed attempt
+// The job is failed if it can't be published, like a retry that can't be
+func (h *JobSubmissionHandler) publishResumedJob(job storage.JobInfo) error {
+	if h.holding() {
+		h.holdJob(job)
+		return nil
+	}
+
+	var jobMsg JobMessage
+	if err := json.Unmarshal(job.Payload, &jobMsg); err != nil {
+		h.logger.Errorf("Failed to decode stored job message for resume: id=%s error=%v", job.JobID, err)
+		return err
+	}
+	jobMsg.Attempt = job.Attempt
+	jobMsg.Checkpoint = job.Checkpoint
+	jobMsg.Timestamp = time.Now().UTC()
+
+	if err := h.publishJob(jobMsg); err != nil {
+		h.jobStore.TransitionJob(job.JobID, storage.JobStatusFailed, "Resume could not be published: "+err.Error(), "")
+		return err
+	}
+	return nil
+}
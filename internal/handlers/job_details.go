@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"siger-api-gateway/internal/storage"
+)
+
+// Job representation views
+// Compact is the original id/status/timestamp/message form, full adds
+// the submitted request, progress, timestamps and computed durations
+const (
+	JobViewCompact = "compact"
+	JobViewFull    = "full"
+)
+
+// JobDetail is the full representation of a job
+// Superset of JobResponse so clients reading the compact fields keep working
+// Durations are in seconds to keep them easy to chart
+type JobDetail struct {
+	JobID     string    `json:"job_id"`
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+	Message   string    `json:"message,omitempty"`
+
+	Type        string   `json:"type"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Project     string   `json:"project,omitempty"`
+	GPUType     string   `json:"gpu_type,omitempty"`
+	GPUCount    int      `json:"gpu_count"`
+	Priority    int      `json:"priority"`
+	Params      any      `json:"params,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+
+	Progress    float64    `json:"progress"`
+	SubmittedAt time.Time  `json:"submitted_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+
+	// Computed durations - still ticking while the job is queued or running
+	QueueTimeSeconds float64 `json:"queue_time_seconds"`
+	RunTimeSeconds   float64 `json:"run_time_seconds"`
+
+	RetryPolicy *storage.RetryPolicy `json:"retry_policy,omitempty"`
+	Attempt     int                  `json:"attempt"`
+	Attempts    []storage.JobAttempt `json:"attempts,omitempty"`
+
+	ArrayID    string `json:"array_id,omitempty"`
+	ArrayIndex int    `json:"array_index,omitempty"`
+
+	MaxRuntime string     `json:"max_runtime,omitempty"`
+	Deadline   *time.Time `json:"deadline,omitempty"`
+
+	DatasetID string   `json:"dataset_id,omitempty"`
+	Secrets   []string `json:"secrets,omitempty"`
+
+	Checkpoint   string     `json:"checkpoint,omitempty"`
+	CheckpointAt *time.Time `json:"checkpoint_at,omitempty"`
+
+	TemplateID      string `json:"template_id,omitempty"`
+	TemplateVersion int    `json:"template_version,omitempty"`
+	ResubmittedFrom string `json:"resubmitted_from,omitempty"`
+	Revision        int    `json:"revision"`
+	Held            bool   `json:"held,omitempty"`
+	Preemptible     bool   `json:"preemptible,omitempty"`
+	PreemptedBy     string `json:"preempted_by,omitempty"`
+}
+
+// newJobResponse builds the compact representation of a job
+func newJobResponse(job storage.JobInfo) JobResponse {
+	return JobResponse{
+		JobID:     job.JobID,
+		Status:    string(job.Status),
+		Timestamp: job.SubmittedAt,
+		Message:   job.Message,
+	}
+}
+
+// newJobDetail builds the full representation of a job as of now
+func newJobDetail(job storage.JobInfo, now time.Time) JobDetail {
+	detail := JobDetail{
+		JobID:       job.JobID,
+		Status:      string(job.Status),
+		Timestamp:   now,
+		Message:     job.Message,
+		Type:        job.Type,
+		Name:        job.Name,
+		Description: job.Description,
+		Project:     job.Project,
+		GPUType:     job.GPUType,
+		GPUCount:    job.GPUCount,
+		Priority:    job.Priority,
+		Params:      job.Params,
+		Tags:        job.Tags,
+		Progress:    job.Progress,
+		SubmittedAt: job.SubmittedAt,
+		RetryPolicy: job.RetryPolicy,
+		Attempt:     job.Attempt,
+		Attempts:    job.Attempts,
+		ArrayID:     job.ArrayID,
+		ArrayIndex:  job.ArrayIndex,
+		MaxRuntime:  job.MaxRuntime,
+		Checkpoint:  job.Checkpoint,
+		DatasetID:   job.DatasetID,
+		Secrets:     job.Secrets,
+
+		TemplateID:      job.TemplateID,
+		TemplateVersion: job.TemplateVersion,
+		ResubmittedFrom: job.ResubmittedFrom,
+		Revision:        job.Revision,
+		Held:            job.Held,
+		Preemptible:     job.Preemptible,
+		PreemptedBy:     job.PreemptedBy,
+	}
+
+	if !job.StartedAt.IsZero() {
+		startedAt := job.StartedAt
+		detail.StartedAt = &startedAt
+	}
+	if !job.CompletedAt.IsZero() {
+		completedAt := job.CompletedAt
+		detail.CompletedAt = &completedAt
+	}
+	if !job.CheckpointAt.IsZero() {
+		checkpointAt := job.CheckpointAt
+		detail.CheckpointAt = &checkpointAt
+	}
+	if !job.Deadline.IsZero() {
+		deadline := job.Deadline
+		detail.Deadline = &deadline
+	}
+
+	queueTime, runTime := jobDurations(job, now)
+	detail.QueueTimeSeconds = queueTime.Seconds()
+	detail.RunTimeSeconds = runTime.Seconds()
+
+	return detail
+}
+
+// jobDurations computes how long a job waited in the queue and how long it ran
+// Jobs cancelled before they started only accumulate queue time
+func jobDurations(job storage.JobInfo, now time.Time) (queueTime, runTime time.Duration) {
+	switch {
+	case !job.StartedAt.IsZero():
+		queueTime = job.StartedAt.Sub(job.SubmittedAt)
+		end := now
+		if !job.CompletedAt.IsZero() {
+			end = job.CompletedAt
+		}
+		runTime = end.Sub(job.StartedAt)
+	case !job.CompletedAt.IsZero():
+		queueTime = job.CompletedAt.Sub(job.SubmittedAt)
+	default:
+		queueTime = now.Sub(job.SubmittedAt)
+	}
+
+	if queueTime < 0 {
+		queueTime = 0
+	}
+	if runTime < 0 {
+		runTime = 0
+	}
+	return queueTime, runTime
+}
+
+// jobView returns the representation requested with the view query parameter
+func jobView(r *http.Request, defaultView string) (string, bool) {
+	view := r.URL.Query().Get("view")
+	switch view {
+	case "":
+		return defaultView, true
+	case JobViewCompact, JobViewFull:
+		return view, true
+	default:
+		return "", false
+	}
+}
+
+// renderJobs converts jobs to the requested view for list responses
+func renderJobs(jobs []storage.JobInfo, view string) any {
+	if view == JobViewFull {
+		now := time.Now().UTC()
+		details := make([]JobDetail, 0, len(jobs))
+		for _, job := range jobs {
+			details = append(details, newJobDetail(job, now))
+		}
+		return details
+	}
+
+	responses := make([]JobResponse, 0, len(jobs))
+	for _, job := range jobs {
+		responses = append(responses, newJobResponse(job))
+	}
+	return responses
+}
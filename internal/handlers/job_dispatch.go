@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/messaging"
+	"siger-api-gateway/internal/storage"
+)
+
+// defaultAgingInterval is the wait worth one priority point when the config has none
+const defaultAgingInterval = 10 * time.Minute
+
+// dispatchQueuedMessage is the message of jobs waiting for the dispatcher
+const dispatchQueuedMessage = "Waiting in the gateway's dispatch queue"
+
+// DispatchQueueEntry is one job waiting for the dispatcher, as admins see it
+type DispatchQueueEntry struct {
+	JobID             string    `json:"job_id"`
+	UserID            string    `json:"user_id"`
+	Project           string    `json:"project,omitempty"`
+	GPUType           string    `json:"gpu_type"`
+	GPUCount          int       `json:"gpu_count"`
+	Priority          int       `json:"priority"`
+	EffectivePriority float64   `json:"effective_priority"`
+	SubmittedAt       time.Time `json:"submitted_at"`
+}
+
+// DispatchQueueResponse is the response for GET /admin/dispatch
+type DispatchQueueResponse struct {
+	Enabled bool                 `json:"enabled"`
+	Jobs    []DispatchQueueEntry `json:"jobs"`
+}
+
+// SetDispatchConfig turns the priority dispatcher on or off
+// With it on every new job waits in the gateway and RunAdmission hands jobs
+// out by effective priority on dispatch.<type>
+func (h *JobSubmissionHandler) SetDispatchConfig(config internal.DispatchConfig) {
+	h.dispatch = config
+	h.agingInterval = defaultAgingInterval
+	if interval, err := time.ParseDuration(config.AgingInterval); err == nil {
+		h.agingInterval = interval
+	}
+	if config.Enabled && h.dispatchWake == nil {
+		h.dispatchWake = make(chan struct{}, 1)
+	}
+}
+
+// dispatching reports whether jobs go through the priority dispatcher
+func (h *JobSubmissionHandler) dispatching() bool {
+	return h.dispatch.Enabled
+}
+
+// jobSubject returns the subject jobs of a type are published on
+func (h *JobSubmissionHandler) jobSubject(jobType string) string {
+	if h.dispatching() {
+		return messaging.DispatchSubjectPrefix + jobType
+	}
+	return "jobs." + jobType
+}
+
+// wakeDispatcher runs a dispatch round now instead of on the next tick
+// So a new job on an idle cluster doesn't wait for the ticker
+func (h *JobSubmissionHandler) wakeDispatcher() {
+	select {
+	case h.dispatchWake <- struct{}{}:
+	default: // A round is already pending
+	}
+}
+
+// fairShare scores queued jobs by priority, time waited and how much of
+// the GPUs in use their user and project already hold
+type fairShare struct {
+	users    map[string]int
+	projects map[string]int
+	total    int
+	weight   float64
+	aging    time.Duration
+}
+
+// currentShares counts the GPUs held by every user and project
+// Dispatched jobs that haven't started count too, they're on their way
+func (h *JobSubmissionHandler) currentShares() *fairShare {
+	shares := &fairShare{
+		users:    make(map[string]int),
+		projects: make(map[string]int),
+		weight:   h.dispatch.FairShareWeight,
+		aging:    h.agingInterval,
+	}
+	for _, job := range h.jobStore.JobsByStatus(storage.JobStatusQueued, storage.JobStatusScheduled,
+		storage.JobStatusProcessing, storage.JobStatusCancelling) {
+		if !job.Held {
+			shares.add(job)
+		}
+	}
+	return shares
+}
+
+// add counts a job's GPUs against its user and project
+func (f *fairShare) add(job storage.JobInfo) {
+	f.users[job.UserID] += job.GPUCount
+	if job.Project != "" {
+		f.projects[job.Project] += job.GPUCount
+	}
+	f.total += job.GPUCount
+}
+
+// score returns a job's effective priority
+// Every aging interval waited adds a point, so any job eventually beats new
+// work. A user holding every GPU in use loses the full weight, and so does a
+// project
+func (f *fairShare) score(job storage.JobInfo, now time.Time) float64 {
+	score := float64(job.Priority)
+	if f.aging > 0 {
+		score += float64(now.Sub(job.SubmittedAt)) / float64(f.aging)
+	}
+	if f.total > 0 {
+		score -= f.weight * float64(f.users[job.UserID]) / float64(f.total)
+		if job.Project != "" {
+			score -= f.weight * float64(f.projects[job.Project]) / float64(f.total)
+		}
+	}
+	return score
+}
+
+// best returns the index of the job with the highest effective priority
+// Ties keep the order of HeldJobs, oldest first
+func (f *fairShare) best(jobs []storage.JobInfo, now time.Time) int {
+	best, bestScore := 0, f.score(jobs[0], now)
+	for i := 1; i < len(jobs); i++ {
+		if score := f.score(jobs[i], now); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+// dispatchHeldJobs hands out queued jobs by effective priority
+// Scores are recomputed after every release since the owner's share just
+// grew. When the top job doesn't fit, its GPU type is reserved for it for
+// the rest of the round, so small jobs can't starve a big one. Without
+// heartbeats the number of unstarted dispatched jobs is capped instead
+func (h *JobSubmissionHandler) dispatchHeldJobs() {
+	held := h.jobStore.HeldJobs()
+	if len(held) == 0 {
+		return
+	}
+
+	var available map[string]int
+	inFlight := 0
+	if h.workers != nil && h.workers.Ready() {
+		available = h.availableGPUs(false)
+	} else {
+		for _, job := range h.jobStore.JobsByStatus(storage.JobStatusQueued) {
+			if !job.Held {
+				inFlight++
+			}
+		}
+	}
+
+	h.dispatchRound(held, h.currentShares(), available, inFlight, time.Now())
+}
+
+// dispatchRound releases held jobs in order of effective priority
+// available is nil without heartbeats, then inFlight counts against MaxInFlight
+func (h *JobSubmissionHandler) dispatchRound(held []storage.JobInfo, shares *fairShare, available map[string]int, inFlight int, now time.Time) {
+	reserved := make(map[string]bool)
+	for len(held) > 0 {
+		i := shares.best(held, now)
+		job := held[i]
+		held = append(held[:i], held[i+1:]...)
+
+		if reserved[job.GPUType] {
+			continue
+		}
+		if available != nil {
+			if !takeGPUs(available, job.GPUType, job.GPUCount, false) {
+				// A job too big for any node would hold the type forever
+				if largest, known := h.largestNode(job.GPUType); !known || job.GPUCount <= largest {
+					reserved[job.GPUType] = true
+					h.preemptFor(job, available)
+				}
+				continue
+			}
+		} else if inFlight >= h.dispatch.MaxInFlight {
+			return
+		} else {
+			inFlight++
+		}
+
+		if h.releaseJob(job.JobID) {
+			shares.add(job)
+		}
+	}
+}
+
+// GetDispatchQueue lists the jobs waiting for the dispatcher in dispatch order
+// Order as of now - the next round may differ once shares change
+func (h *JobSubmissionHandler) GetDispatchQueue(w http.ResponseWriter, r *http.Request) {
+	resp := DispatchQueueResponse{Enabled: h.dispatching(), Jobs: []DispatchQueueEntry{}}
+
+	if resp.Enabled {
+		now := time.Now()
+		shares := h.currentShares()
+		held := h.jobStore.HeldJobs()
+		for len(held) > 0 {
+			i := shares.best(held, now)
+			job := held[i]
+			held = append(held[:i], held[i+1:]...)
+
+			resp.Jobs = append(resp.Jobs, DispatchQueueEntry{
+				JobID:             job.JobID,
+				UserID:            job.UserID,
+				Project:           job.Project,
+				GPUType:           job.GPUType,
+				GPUCount:          job.GPUCount,
+				Priority:          job.Priority,
+				EffectivePriority: shares.score(job, now),
+				SubmittedAt:       job.SubmittedAt,
+			})
+			shares.add(job) // Later jobs see the shares they'd be dispatched against
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// RegisterAdminRoutes registers the dispatch queue listing
+// Mounted under /admin by the caller
+func (h *JobSubmissionHandler) RegisterAdminRoutes(r chi.Router) {
+	r.Get("/dispatch", h.GetDispatchQueue)
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/storage"
+)
+
+// newTestHandler creates a job submission handler without NATS or workers
+func newTestHandler() *JobSubmissionHandler {
+	return &JobSubmissionHandler{
+		jobStore: storage.NewJobStore(1000),
+		logger:   zap.NewNop().Sugar(),
+	}
+}
+
+func TestFairShareScore(t *testing.T) {
+	now := time.Date(2023, 8, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		shares fairShare
+		job    storage.JobInfo
+		want   float64
+	}{
+		{
+			name:   "priority only",
+			shares: fairShare{aging: 10 * time.Minute},
+			job:    storage.JobInfo{Priority: 5, SubmittedAt: now},
+			want:   5,
+		},
+		{
+			name:   "aging adds a point per interval",
+			shares: fairShare{aging: 10 * time.Minute},
+			job:    storage.JobInfo{Priority: 5, SubmittedAt: now.Add(-30 * time.Minute)},
+			want:   8,
+		},
+		{
+			name:   "no aging without an interval",
+			shares: fairShare{},
+			job:    storage.JobInfo{Priority: 5, SubmittedAt: now.Add(-30 * time.Minute)},
+			want:   5,
+		},
+		{
+			name: "user holding half the GPUs loses half the weight",
+			shares: fairShare{
+				users: map[string]int{"alice": 4}, total: 8, weight: 10,
+			},
+			job:  storage.JobInfo{UserID: "alice", Priority: 5, SubmittedAt: now},
+			want: 0,
+		},
+		{
+			name: "project share is taken as well",
+			shares: fairShare{
+				users: map[string]int{"alice": 4}, projects: map[string]int{"nlp": 8}, total: 8, weight: 10,
+			},
+			job:  storage.JobInfo{UserID: "alice", Project: "nlp", Priority: 5, SubmittedAt: now},
+			want: -10,
+		},
+		{
+			name: "users without GPUs aren't penalized",
+			shares: fairShare{
+				users: map[string]int{"alice": 8}, total: 8, weight: 10,
+			},
+			job:  storage.JobInfo{UserID: "bob", Priority: 5, SubmittedAt: now},
+			want: 5,
+		},
+		{
+			name:   "nothing in use",
+			shares: fairShare{weight: 10},
+			job:    storage.JobInfo{UserID: "alice", Priority: 5, SubmittedAt: now},
+			want:   5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.shares.score(tt.job, now); got != tt.want {
+				t.Errorf("score() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFairShareBest(t *testing.T) {
+	now := time.Date(2023, 8, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		shares fairShare
+		jobs   []storage.JobInfo
+		want   string
+	}{
+		{
+			name:   "highest priority",
+			shares: fairShare{aging: 10 * time.Minute},
+			jobs: []storage.JobInfo{
+				{JobID: "low", Priority: 1, SubmittedAt: now},
+				{JobID: "high", Priority: 2, SubmittedAt: now},
+			},
+			want: "high",
+		},
+		{
+			name:   "ties keep the first job",
+			shares: fairShare{aging: 10 * time.Minute},
+			jobs: []storage.JobInfo{
+				{JobID: "first", Priority: 1, SubmittedAt: now},
+				{JobID: "second", Priority: 1, SubmittedAt: now},
+			},
+			want: "first",
+		},
+		{
+			name:   "aged job beats newer higher priority work",
+			shares: fairShare{aging: 10 * time.Minute},
+			jobs: []storage.JobInfo{
+				{JobID: "new", Priority: 3, SubmittedAt: now},
+				{JobID: "old", Priority: 0, SubmittedAt: now.Add(-time.Hour)},
+			},
+			want: "old",
+		},
+		{
+			name: "user holding the GPUs goes after others",
+			shares: fairShare{
+				users: map[string]int{"alice": 8}, total: 8, weight: 10, aging: 10 * time.Minute,
+			},
+			jobs: []storage.JobInfo{
+				{JobID: "alice", UserID: "alice", Priority: 5, SubmittedAt: now},
+				{JobID: "bob", UserID: "bob", Priority: 0, SubmittedAt: now},
+			},
+			want: "bob",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.jobs[tt.shares.best(tt.jobs, now)].JobID; got != tt.want {
+				t.Errorf("best() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatchRound(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name      string
+		static    map[string]internal.StaticGPUCapacity
+		available map[string]int
+		jobs      []storage.JobInfo
+		released  []string
+	}{
+		{
+			name:      "jobs that fit are released",
+			available: map[string]int{"A100": 4, "T4": 2},
+			jobs: []storage.JobInfo{
+				{JobID: "a", GPUType: "A100", GPUCount: 4},
+				{JobID: "b", GPUType: "T4", GPUCount: 2},
+			},
+			released: []string{"a", "b"},
+		},
+		{
+			name:      "big job reserves its GPU type",
+			available: map[string]int{"A100": 4, "T4": 2},
+			jobs: []storage.JobInfo{
+				{JobID: "big", GPUType: "A100", GPUCount: 8, Priority: 10},
+				{JobID: "small", GPUType: "A100", GPUCount: 1, Priority: 1},
+				{JobID: "other", GPUType: "T4", GPUCount: 1},
+			},
+			released: []string{"other"},
+		},
+		{
+			name:      "job bigger than any node doesn't reserve",
+			static:    map[string]internal.StaticGPUCapacity{"A100": {Nodes: 2, GPUsPerNode: 4}},
+			available: map[string]int{"A100": 4},
+			jobs: []storage.JobInfo{
+				{JobID: "huge", GPUType: "A100", GPUCount: 8, Priority: 10},
+				{JobID: "small", GPUType: "A100", GPUCount: 1, Priority: 1},
+			},
+			released: []string{"small"},
+		},
+		{
+			name:      "free GPUs run out",
+			available: map[string]int{"A100": 4},
+			jobs: []storage.JobInfo{
+				{JobID: "first", GPUType: "A100", GPUCount: 2, Priority: 2},
+				{JobID: "second", GPUType: "A100", GPUCount: 2, Priority: 1},
+				{JobID: "third", GPUType: "A100", GPUCount: 2},
+			},
+			released: []string{"first", "second"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler()
+			h.capacity.Static = tt.static
+			for _, job := range tt.jobs {
+				job.UserID = "alice"
+				job.Held = true
+				job.SubmittedAt = now
+				job.Payload = []byte("{}")
+				h.jobStore.AddJob(job)
+			}
+
+			shares := &fairShare{users: map[string]int{}, projects: map[string]int{}, aging: time.Hour}
+			h.dispatchRound(h.jobStore.HeldJobs(), shares, tt.available, 0, now)
+
+			released := map[string]bool{}
+			for _, id := range tt.released {
+				released[id] = true
+			}
+			for _, job := range tt.jobs {
+				stored, err := h.jobStore.GetJob(job.JobID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stored.Held == released[job.JobID] {
+					t.Errorf("job %s held = %v, want %v", job.JobID, stored.Held, !released[job.JobID])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"siger-api-gateway/internal/metering"
+	"siger-api-gateway/internal/storage"
+)
+
+// JobEstimateRequest is a job request plus how long the caller expects it to run
+type JobEstimateRequest struct {
+	JobRequest
+	ExpectedDuration string `json:"expected_duration"` // e.g. "2h30m"
+}
+
+// JobEstimate is the response for POST /jobs/estimate
+// The wait estimate is deliberately naive: the median queue-to-start time of
+// recently started jobs on the same GPU type, nothing about the jobs ahead
+type JobEstimate struct {
+	GPUType                 string  `json:"gpu_type"`
+	GPUCount                int     `json:"gpu_count"`
+	ExpectedDurationSeconds float64 `json:"expected_duration_seconds"`
+	GPUHours                float64 `json:"gpu_hours"`
+	PricePerGPUHour         float64 `json:"price_per_gpu_hour"`
+	EstimatedCost           float64 `json:"estimated_cost"`
+	Currency                string  `json:"currency"`
+
+	QueuedJobs           int     `json:"queued_jobs"`
+	QueuedGPUs           int     `json:"queued_gpus"`
+	EstimatedWaitSeconds float64 `json:"estimated_wait_seconds"`
+	WaitSampleSize       int     `json:"wait_sample_size"` // 0 means no recent starts to base the wait on
+}
+
+// SetMeter sets the meter whose price table is used for cost estimates
+// Optional - without it estimates report no cost
+func (h *JobSubmissionHandler) SetMeter(meter *metering.Meter) {
+	h.meter = meter
+}
+
+// EstimateJob returns the expected cost and queue wait for a job without submitting it
+func (h *JobSubmissionHandler) EstimateJob(w http.ResponseWriter, r *http.Request) {
+	var req JobEstimateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Estimates of template jobs are for the expanded job, like submissions
+	jobReq, err := h.applyTemplate(r.Context(), req.JobRequest)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	req.JobRequest = jobReq
+
+	if err := req.JobRequest.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ExpectedDuration == "" {
+		http.Error(w, "Expected duration is required", http.StatusBadRequest)
+		return
+	}
+	duration, err := time.ParseDuration(req.ExpectedDuration)
+	if err != nil || duration <= 0 {
+		http.Error(w, "Invalid expected duration: must be a positive duration like 2h30m", http.StatusBadRequest)
+		return
+	}
+
+	estimate := JobEstimate{
+		GPUType:                 string(req.GPUType),
+		GPUCount:                req.GPUCount,
+		ExpectedDurationSeconds: duration.Seconds(),
+		GPUHours:                duration.Hours() * float64(req.GPUCount),
+	}
+
+	if h.meter != nil {
+		estimate.PricePerGPUHour = h.meter.PriceFor(string(req.GPUType))
+		estimate.EstimatedCost = estimate.GPUHours * estimate.PricePerGPUHour
+		estimate.Currency = h.meter.Currency()
+	}
+
+	stats := h.jobStore.QueueStats(string(req.GPUType), storage.DefaultQueueSampleSize, storage.DefaultQueueLookback)
+	estimate.QueuedJobs = stats.QueuedJobs
+	estimate.QueuedGPUs = stats.QueuedGPUs
+	estimate.EstimatedWaitSeconds = stats.MedianQueueTime().Seconds()
+	estimate.WaitSampleSize = len(stats.RecentQueueTimes)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(estimate)
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/events"
+	"siger-api-gateway/internal/middleware"
+	"siger-api-gateway/internal/storage"
+)
+
+// sseHeartbeatInterval keeps idle streams alive through proxies and load balancers
+// Most of them drop idle connections after 30-60s
+const sseHeartbeatInterval = 15 * time.Second
+
+// JobEventsHandler streams job status changes to clients over Server-Sent Events
+// Replaces dashboard polling of GET /jobs/{jobID} with a push model
+// Fed from the job store, so changes made by workers and the gateway alike are streamed
+type JobEventsHandler struct {
+	broker   *events.Broker
+	jobStore *storage.JobStore
+	logger   internal.LoggerInterface
+}
+
+// NewJobEventsHandler creates a new job events handler
+// Registers with the job store so every status change is streamed
+func NewJobEventsHandler(broker *events.Broker, jobStore *storage.JobStore) *JobEventsHandler {
+	h := &JobEventsHandler{
+		broker:   broker,
+		jobStore: jobStore,
+		logger:   internal.Logger,
+	}
+
+	jobStore.OnChange(h.observe)
+
+	return h
+}
+
+// RegisterRoutes registers the job event stream routes
+func (h *JobEventsHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/jobs/events", h.StreamUserEvents)
+	r.Get("/jobs/{jobID}/events", h.StreamJobEvents)
+}
+
+// observe turns job status, attempt and progress changes into job events
+// Runs under the job store lock, so events go out in the order of the changes
+func (h *JobEventsHandler) observe(old *storage.JobInfo, job *storage.JobInfo) {
+	if job == nil {
+		return
+	}
+	if old != nil && old.Status == job.Status && old.Attempt == job.Attempt && old.Progress == job.Progress {
+		return
+	}
+
+	// The reason is on the history entry a status change just added
+	reason := ""
+	if n := len(job.History); n > 0 && (old == nil || old.Status != job.Status) && job.History[n-1].To == job.Status {
+		reason = job.History[n-1].Reason
+	}
+	h.broker.Publish(events.JobEvent{
+		JobID:    job.JobID,
+		UserID:   job.UserID,
+		Project:  job.Project,
+		Status:   string(job.Status),
+		Message:  job.Message,
+		Reason:   reason,
+		Progress: job.Progress,
+		Attempt:  job.Attempt,
+	})
+}
+
+// StreamJobEvents streams events for a single job
+// Only callers that can access the job may subscribe
+func (h *JobEventsHandler) StreamJobEvents(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "jobID")
+	if jobID == "" {
+		http.Error(w, "Job ID is required", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.jobStore.GetJob(jobID)
+	if err != nil || !canAccessJob(r.Context(), job) {
+		// Same response for missing and foreign jobs so IDs can't be probed
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	h.serveEvents(w, r,
+		func(ev events.JobEvent) bool { return ev.JobID == jobID },
+		func(afterID uint64) []events.JobEvent { return h.broker.JobHistory(jobID, afterID) },
+	)
+}
+
+// StreamUserEvents streams events for all jobs the authenticated user can access
+// Same rule as for single jobs, so project members see the project's jobs
+func (h *JobEventsHandler) StreamUserEvents(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok || userID == "" {
+		http.Error(w, "User ID not found in request context", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+	filter := func(ev events.JobEvent) bool { return canAccessOwner(ctx, ev.UserID, ev.Project) }
+	h.serveEvents(w, r, filter,
+		func(afterID uint64) []events.JobEvent { return h.broker.History(filter, afterID) },
+	)
+}
+
+// serveEvents runs the SSE loop: replay history after Last-Event-ID, then stream live events
+// We subscribe before reading history so nothing published in between is lost,
+// and skip live events we already replayed
+func (h *JobEventsHandler) serveEvents(w http.ResponseWriter, r *http.Request,
+	filter func(events.JobEvent) bool, history func(afterID uint64) []events.JobEvent) {
+
+	rc := http.NewResponseController(w)
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		// EventSource can't set headers on the first connect, allow a query param too
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var afterID uint64
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		afterID = id
+	}
+
+	liveEvents, cancel := h.broker.Subscribe(filter)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+
+	// Tell the browser how long to wait before reconnecting
+	fmt.Fprintf(w, "retry: %d\n\n", 3000)
+
+	for _, ev := range history(afterID) {
+		if err := writeSSEEvent(w, ev); err != nil {
+			return
+		}
+		afterID = ev.ID
+	}
+	if err := rc.Flush(); err != nil {
+		h.logger.Warnf("Streaming not supported by response writer: %v", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case ev, ok := <-liveEvents:
+			if !ok {
+				// Broker dropped us for being too slow, the client will reconnect and resume
+				return
+			}
+			if ev.ID <= afterID {
+				continue
+			}
+			if err := writeSSEEvent(w, ev); err != nil {
+				return
+			}
+			afterID = ev.ID
+			if err := rc.Flush(); err != nil {
+				return
+			}
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeSSEEvent writes a single job event in SSE wire format
+func writeSSEEvent(w http.ResponseWriter, ev events.JobEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: status\ndata: %s\n\n", ev.ID, data)
+	return err
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/events"
+	"siger-api-gateway/internal/messaging"
+	"siger-api-gateway/internal/middleware"
+	"siger-api-gateway/internal/storage"
+)
+
+// WebSocket timing settings
+// Pings every 30s keep the connection alive and detect dead clients
+// pongWait has to be longer than the ping period
+const (
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingPeriod     = 30 * time.Second
+	wsMaxMessageSize = 512
+	wsFrameBuffer    = 256
+)
+
+// JobStreamFrame is a single message sent to WebSocket clients
+// Type is "log" for worker log lines, "progress" for status/progress updates
+// and "error" when the stream can't continue
+type JobStreamFrame struct {
+	Type      string    `json:"type"`
+	JobID     string    `json:"job_id"`
+	Seq       uint64    `json:"seq,omitempty"` // Log stream sequence, use start_seq=seq+1 to resume
+	Stream    string    `json:"stream,omitempty"`
+	Line      string    `json:"line,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Progress  float64   `json:"progress,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// JobLogsHandler streams live job logs and progress over WebSocket
+// Logs come from the jobs.logs.<jobID> JetStream subjects workers publish to,
+// progress comes from the same event broker that feeds the SSE streams
+type JobLogsHandler struct {
+	natsClient *messaging.NATSClient
+	broker     *events.Broker
+	jobStore   *storage.JobStore
+	jwtSecret  string
+	upgrader   websocket.Upgrader
+	logger     internal.LoggerInterface
+}
+
+// NewJobLogsHandler creates a new job logs handler
+// Authentication is done by the handler itself since browsers can't send
+// an Authorization header when opening a WebSocket
+func NewJobLogsHandler(natsClient *messaging.NATSClient, broker *events.Broker, jobStore *storage.JobStore, jwtSecret string) *JobLogsHandler {
+	return &JobLogsHandler{
+		natsClient: natsClient,
+		broker:     broker,
+		jobStore:   jobStore,
+		jwtSecret:  jwtSecret,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 4096,
+			// Auth is token based, not cookie based, so cross-origin
+			// connections can't ride on a victim's session
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		logger: internal.Logger,
+	}
+}
+
+// RegisterRoutes registers the job log streaming routes
+// Must be registered outside the JWT middleware group
+func (h *JobLogsHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/jobs/{jobID}/logs/ws", h.StreamJobLogs)
+}
+
+// authenticate validates the gateway JWT from the Authorization header
+// or the token query parameter
+func (h *JobLogsHandler) authenticate(r *http.Request) (*middleware.UserClaims, error) {
+	tokenString := r.URL.Query().Get("token")
+	if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+		parts := strings.Split(authHeader, " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			return nil, middleware.ErrInvalidToken
+		}
+		tokenString = parts[1]
+	}
+	if tokenString == "" {
+		return nil, middleware.ErrNoToken
+	}
+	return middleware.ParseToken(tokenString, h.jwtSecret)
+}
+
+// StreamJobLogs upgrades the connection and streams logs and progress for a job
+// Query parameter start_seq tails the log stream from a JetStream sequence
+func (h *JobLogsHandler) StreamJobLogs(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "jobID")
+	if jobID == "" {
+		http.Error(w, "Job ID is required", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := h.authenticate(r)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	job, err := h.jobStore.GetJob(jobID)
+	if err != nil || !canAccessJob(middleware.ContextWithClaims(r.Context(), claims), job) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	var startSeq uint64
+	if v := r.URL.Query().Get("start_seq"); v != "" {
+		startSeq, err = strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid start_seq", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if h.natsClient == nil {
+		http.Error(w, "Log streaming unavailable: NATS not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade already wrote an HTTP error response
+		h.logger.Warnf("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	frames := make(chan JobStreamFrame, wsFrameBuffer)
+	send := func(frame JobStreamFrame) {
+		select {
+		case frames <- frame:
+		case <-ctx.Done():
+		}
+	}
+
+	// Send the current state first so clients don't start blind
+	send(JobStreamFrame{
+		Type:      "progress",
+		JobID:     job.JobID,
+		Status:    string(job.Status),
+		Message:   job.Message,
+		Timestamp: time.Now().UTC(),
+	})
+
+	stopLogs, err := h.natsClient.SubscribeToJobLogs(ctx, jobID, startSeq, func(msg messaging.JobLogMessage, seq uint64) {
+		send(JobStreamFrame{
+			Type:      "log",
+			JobID:     jobID,
+			Seq:       seq,
+			Stream:    msg.Stream,
+			Line:      msg.Line,
+			Progress:  msg.Progress,
+			Timestamp: msg.Timestamp,
+		})
+	})
+	if err != nil {
+		h.logger.Errorw("Failed to subscribe to job logs", "jobID", jobID, "error", err)
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		conn.WriteJSON(JobStreamFrame{
+			Type:      "error",
+			JobID:     jobID,
+			Message:   "Failed to subscribe to job logs",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	defer stopLogs()
+
+	if h.broker != nil {
+		updates, unsubscribe := h.broker.Subscribe(func(ev events.JobEvent) bool { return ev.JobID == jobID })
+		defer unsubscribe()
+
+		go func() {
+			for ev := range updates {
+				send(JobStreamFrame{
+					Type:      "progress",
+					JobID:     ev.JobID,
+					Status:    ev.Status,
+					Progress:  ev.Progress,
+					Message:   ev.Message,
+					Timestamp: ev.Timestamp,
+				})
+			}
+		}()
+	}
+
+	// Reader goroutine - we don't expect client messages but must read
+	// to process control frames and notice when the client goes away
+	go func() {
+		defer cancel()
+		conn.SetReadLimit(wsMaxMessageSize)
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	h.logger.Infof("Job log stream opened: id=%s user=%s start_seq=%d", jobID, claims.UserID, startSeq)
+
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	// Single writer loop - gorilla connections don't support concurrent writers
+	for {
+		select {
+		case <-ctx.Done():
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(wsWriteWait))
+			return
+
+		case frame := <-frames:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteJSON(frame); err != nil {
+				return
+			}
+
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/messaging"
+	"siger-api-gateway/internal/storage"
+	"siger-api-gateway/internal/workers"
+)
+
+// preemptionGrace is how long a victim's worker has to confirm it stopped
+// Past it the worker is taken as hung or gone and the victim is requeued anyway
+const preemptionGrace = 10 * time.Minute
+
+// SetPreemptionConfig sets whether held jobs may displace preemptible ones
+func (h *JobSubmissionHandler) SetPreemptionConfig(config internal.PreemptionConfig) {
+	h.preemption = config
+}
+
+// preemptFor makes room for a held job that doesn't fit in the free GPUs
+// available is what's left of each type in the current release round.
+// Reports whether the job is waiting on preempted jobs, so the caller keeps
+// their GPUs for it instead of backfilling smaller jobs
+func (h *JobSubmissionHandler) preemptFor(job storage.JobInfo, available map[string]int) bool {
+	if !h.preemption.Enabled || !job.MayPreempt || h.workers == nil || !h.workers.Ready() {
+		return false
+	}
+	if h.preemptionPending(job.JobID) {
+		return true // Victims are still giving their GPUs back
+	}
+	if largest, _ := h.largestNode(job.GPUType); job.GPUCount > largest {
+		return false // No amount of preemption makes this one fit
+	}
+
+	victims := h.selectVictims(job, available)
+	if len(victims) == 0 {
+		return false
+	}
+
+	preempted := 0
+	for _, victim := range victims {
+		if h.preemptJob(victim, job) {
+			preempted++
+		}
+	}
+	h.logger.Infof("Jobs preempted: for=%s priority=%d gpu=%s count=%d victims=%d",
+		job.JobID, job.Priority, job.GPUType, job.GPUCount, preempted)
+	return preempted > 0
+}
+
+// preemptionPending reports whether jobs preempted for jobID haven't been requeued yet
+func (h *JobSubmissionHandler) preemptionPending(jobID string) bool {
+	for _, victim := range h.jobStore.JobsByStatus(storage.JobStatusPreempted) {
+		if victim.PreemptedBy == jobID {
+			return true
+		}
+	}
+	return false
+}
+
+// selectVictims picks the running jobs to preempt so a job fits
+// Candidates are preemptible and at least minPriorityGap below the job.
+// Lowest priority goes first, then the most recently started since it has
+// the least to lose. Per GPU type the fewest victims win; nothing is picked
+// if no type can be freed up enough. Nodes aren't considered, only counts
+func (h *JobSubmissionHandler) selectVictims(job storage.JobInfo, available map[string]int) []storage.JobInfo {
+	var candidates []storage.JobInfo
+	for _, running := range h.jobStore.JobsByStatus(storage.JobStatusScheduled, storage.JobStatusProcessing) {
+		if running.Preemptible && int64(job.Priority)-int64(running.Priority) >= int64(h.preemption.MinPriorityGap) {
+			candidates = append(candidates, running)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Priority != candidates[j].Priority {
+			return candidates[i].Priority < candidates[j].Priority
+		}
+		return candidates[i].StartedAt.After(candidates[j].StartedAt)
+	})
+
+	var best []storage.JobInfo
+	found := false
+	for gpuType, free := range available {
+		if !workers.MatchesGPUType(gpuType, job.GPUType) {
+			continue
+		}
+
+		// Jobs that asked for any GPU could be on any type, they're skipped
+		var victims []storage.JobInfo
+		for _, candidate := range candidates {
+			if free >= job.GPUCount {
+				break
+			}
+			if strings.EqualFold(candidate.GPUType, gpuType) {
+				victims = append(victims, candidate)
+				free += candidate.GPUCount
+			}
+		}
+		if free >= job.GPUCount && (!found || len(victims) < len(best)) {
+			best, found = victims, true
+		}
+	}
+	return best
+}
+
+// preemptJob marks a victim preempted and tells its worker to stop
+func (h *JobSubmissionHandler) preemptJob(victim, preemptor storage.JobInfo) bool {
+	preempted, err := h.jobStore.PreemptJob(victim.JobID, preemptor.JobID, "Preempted for higher priority job "+preemptor.JobID)
+	if err != nil {
+		return false // Finished or cancelled in the meantime
+	}
+
+	if err := h.publishControl(preempted, JobControlPreempt); err != nil {
+		h.logger.Warnf("Failed to publish job preempt control message: id=%s error=%v", victim.JobID, err)
+	}
+	h.logger.Infof("Job preempted: id=%s priority=%d for=%s", victim.JobID, victim.Priority, preemptor.JobID)
+	return true
+}
+
+// requeuePreemptedJobs requeues victims once they're stopped and their
+// preemptor has left the queue, whether it started or was cancelled.
+// Stopped means the worker reported "preempted" with its checkpoint, so
+// the new attempt starts from it; a worker silent for preemptionGrace is
+// taken as gone
+func (h *JobSubmissionHandler) requeuePreemptedJobs() {
+	for _, victim := range h.jobStore.JobsByStatus(storage.JobStatusPreempted) {
+		if victim.PreemptedBy == "" {
+			continue
+		}
+		if !victim.PreemptionConfirmed && time.Since(victim.CompletedAt) < preemptionGrace {
+			continue
+		}
+		preemptor, err := h.jobStore.GetJob(victim.PreemptedBy)
+		if err == nil && preemptor.Status == storage.JobStatusQueued {
+			continue
+		}
+		if !victim.PreemptionConfirmed {
+			h.logger.Warnf("Preempted job never confirmed it stopped: id=%s attempt=%d", victim.JobID, victim.Attempt)
+		}
+
+		requeued, err := h.jobStore.RequeueJob(victim.JobID, storage.FailureReasonPreempted,
+			"Requeued after "+victim.PreemptedBy+" started")
+		if err != nil {
+			continue // Requeued by another round, or failed or cancelled
+		}
+		h.logger.Infof("Preempted job requeued: id=%s attempt=%d after=%s", requeued.JobID, requeued.Attempt, victim.PreemptedBy)
+		h.publishRetry(requeued.JobID, requeued.Attempt)
+	}
+}
+
+// requeueAfterPreemptor records victims confirming they stopped and
+// requeues victims whose preemptor a worker just picked up
+// Registered as a NATS status handler, the admission loop catches anything missed
+func (h *JobSubmissionHandler) requeueAfterPreemptor(update messaging.JobStatusUpdate, job storage.JobInfo) {
+	if !h.preemption.Enabled {
+		return
+	}
+	if job.Status == storage.JobStatusPreempted && job.PreemptedBy != "" {
+		if _, err := h.jobStore.ConfirmPreemption(job.JobID, update.Attempt); err != nil {
+			h.logger.Warnf("Failed to confirm preemption: id=%s error=%v", job.JobID, err)
+			return
+		}
+	} else if job.Status == storage.JobStatusQueued {
+		return
+	}
+	h.requeuePreemptedJobs()
+}
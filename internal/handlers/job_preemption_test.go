@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/storage"
+)
+
+func TestSelectVictims(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name      string
+		gap       int
+		job       storage.JobInfo
+		available map[string]int
+		running   []storage.JobInfo
+		want      []string
+	}{
+		{
+			name:      "lowest priority goes first",
+			gap:       1,
+			job:       storage.JobInfo{GPUType: "A100", GPUCount: 2, Priority: 10},
+			available: map[string]int{"A100": 0},
+			running: []storage.JobInfo{
+				{JobID: "mid", GPUType: "A100", GPUCount: 2, Priority: 5, Preemptible: true},
+				{JobID: "low", GPUType: "A100", GPUCount: 2, Priority: 1, Preemptible: true},
+			},
+			want: []string{"low"},
+		},
+		{
+			name:      "most recently started goes first on equal priority",
+			gap:       1,
+			job:       storage.JobInfo{GPUType: "A100", GPUCount: 2, Priority: 10},
+			available: map[string]int{"A100": 0},
+			running: []storage.JobInfo{
+				{JobID: "older", GPUType: "A100", GPUCount: 2, Priority: 1, Preemptible: true, StartedAt: now.Add(-time.Hour)},
+				{JobID: "newer", GPUType: "A100", GPUCount: 2, Priority: 1, Preemptible: true, StartedAt: now},
+			},
+			want: []string{"newer"},
+		},
+		{
+			name:      "free GPUs count towards the job",
+			gap:       1,
+			job:       storage.JobInfo{GPUType: "A100", GPUCount: 4, Priority: 10},
+			available: map[string]int{"A100": 2},
+			running: []storage.JobInfo{
+				{JobID: "a", GPUType: "A100", GPUCount: 2, Priority: 1, Preemptible: true},
+				{JobID: "b", GPUType: "A100", GPUCount: 2, Priority: 2, Preemptible: true},
+			},
+			want: []string{"a"},
+		},
+		{
+			name:      "priority gap is kept",
+			gap:       5,
+			job:       storage.JobInfo{GPUType: "A100", GPUCount: 2, Priority: 10},
+			available: map[string]int{"A100": 0},
+			running: []storage.JobInfo{
+				{JobID: "close", GPUType: "A100", GPUCount: 2, Priority: 6, Preemptible: true},
+			},
+			want: nil,
+		},
+		{
+			name:      "jobs that aren't preemptible are left alone",
+			gap:       1,
+			job:       storage.JobInfo{GPUType: "A100", GPUCount: 2, Priority: 10},
+			available: map[string]int{"A100": 0},
+			running: []storage.JobInfo{
+				{JobID: "pinned", GPUType: "A100", GPUCount: 2, Priority: 1},
+			},
+			want: nil,
+		},
+		{
+			name:      "nothing is picked if the job still wouldn't fit",
+			gap:       1,
+			job:       storage.JobInfo{GPUType: "A100", GPUCount: 8, Priority: 10},
+			available: map[string]int{"A100": 0},
+			running: []storage.JobInfo{
+				{JobID: "a", GPUType: "A100", GPUCount: 2, Priority: 1, Preemptible: true},
+			},
+			want: nil,
+		},
+		{
+			name:      "any picks the type needing the fewest victims",
+			gap:       1,
+			job:       storage.JobInfo{GPUType: "any", GPUCount: 4, Priority: 10},
+			available: map[string]int{"A100": 0, "T4": 0},
+			running: []storage.JobInfo{
+				{JobID: "a1", GPUType: "A100", GPUCount: 2, Priority: 1, Preemptible: true},
+				{JobID: "a2", GPUType: "A100", GPUCount: 2, Priority: 1, Preemptible: true},
+				{JobID: "t", GPUType: "T4", GPUCount: 4, Priority: 1, Preemptible: true},
+			},
+			want: []string{"t"},
+		},
+		{
+			name:      "extreme priorities don't overflow",
+			gap:       1,
+			job:       storage.JobInfo{GPUType: "A100", GPUCount: 2, Priority: math.MinInt},
+			available: map[string]int{"A100": 0},
+			running: []storage.JobInfo{
+				{JobID: "a", GPUType: "A100", GPUCount: 2, Priority: 0, Preemptible: true},
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler()
+			h.preemption = internal.PreemptionConfig{Enabled: true, MinPriorityGap: tt.gap}
+			for _, job := range tt.running {
+				job.Status = storage.JobStatusProcessing
+				if job.StartedAt.IsZero() {
+					job.StartedAt = now
+				}
+				h.jobStore.AddJob(job)
+			}
+
+			var got []string
+			for _, victim := range h.selectVictims(tt.job, tt.available) {
+				got = append(got, victim.JobID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectVictims() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"siger-api-gateway/internal/storage"
+)
+
+// JobListResponse is a page of jobs returned by GET /jobs
+// Jobs holds JobResponse or JobDetail values depending on the requested view
+type JobListResponse struct {
+	Jobs       any    `json:"jobs"`
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// parseJobQuery builds a job store query from the request's query parameters
+// Supported: status (comma separated or repeated), project, array_id, type, gpu_type, tag, name_prefix,
+// submitted_from/submitted_before (RFC3339), order (asc|desc), limit and cursor
+func parseJobQuery(r *http.Request) (storage.JobQuery, error) {
+	params := r.URL.Query()
+
+	q := storage.JobQuery{
+		Project:    params.Get("project"),
+		ArrayID:    params.Get("array_id"),
+		Type:       params.Get("type"),
+		GPUType:    params.Get("gpu_type"),
+		Tag:        params.Get("tag"),
+		NamePrefix: params.Get("name_prefix"),
+		Cursor:     params.Get("cursor"),
+		Limit:      storage.DefaultQueryLimit,
+	}
+
+	for _, value := range params["status"] {
+		for _, status := range strings.Split(value, ",") {
+			status = strings.TrimSpace(status)
+			if status != "" {
+				q.Statuses = append(q.Statuses, storage.JobStatus(status))
+			}
+		}
+	}
+
+	if v := params.Get("submitted_from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return q, fmt.Errorf("invalid submitted_from: must be RFC3339")
+		}
+		q.SubmittedFrom = t
+	}
+	if v := params.Get("submitted_before"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return q, fmt.Errorf("invalid submitted_before: must be RFC3339")
+		}
+		q.SubmittedBefore = t
+	}
+
+	switch params.Get("order") {
+	case "", "desc":
+		q.Ascending = false
+	case "asc":
+		q.Ascending = true
+	default:
+		return q, fmt.Errorf("invalid order: must be asc or desc")
+	}
+
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > storage.MaxQueryLimit {
+			return q, fmt.Errorf("invalid limit: must be between 1 and %d", storage.MaxQueryLimit)
+		}
+		q.Limit = limit
+	}
+
+	return q, nil
+}
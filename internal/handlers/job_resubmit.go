@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"siger-api-gateway/internal/storage"
+)
+
+// ResubmitRequest holds the optional overrides for POST /jobs/{jobID}/resubmit
+// Everything left out is copied from the original job
+type ResubmitRequest struct {
+	Name     string  `json:"name,omitempty"`
+	GPUType  GPUType `json:"gpu_type,omitempty"`
+	GPUCount int     `json:"gpu_count,omitempty"`
+	Priority *int    `json:"priority,omitempty"` // Pointer so 0 can be requested
+	Params   any     `json:"params,omitempty"`   // Merged into the original params
+}
+
+// ResubmitJob submits a copy of an existing job as a new job
+// Meant for jobs that died on a bad node, but works on any job so it doubles
+// as a clone. The copy is built from the message originally sent to workers
+// and goes through the same validation and quota checks as a new submission
+func (h *JobSubmissionHandler) ResubmitJob(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "jobID")
+	if jobID == "" {
+		http.Error(w, "Job ID is required", http.StatusBadRequest)
+		return
+	}
+
+	// The body is optional - no overrides means an identical copy
+	var overrides ResubmitRequest
+	if err := json.NewDecoder(r.Body).Decode(&overrides); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	original, err := h.jobStore.GetJob(jobID)
+	if err != nil || !canAccessJob(r.Context(), original) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	jobReq, err := resubmitRequest(original, overrides)
+	if err != nil {
+		h.logger.Errorf("Failed to decode stored job message for resubmit: id=%s error=%v", jobID, err)
+		http.Error(w, "Original job request is not available", http.StatusConflict)
+		return
+	}
+
+	now := time.Now().UTC()
+	job, err := h.newPendingJob(r.Context(), jobReq, now)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	job.info.ResubmittedFrom, job.msg.ResubmittedFrom = original.JobID, original.JobID
+
+	jobs := []pendingJob{job}
+	publishErrs, ok := h.enqueueJobs(w, r, jobs)
+	if !ok {
+		return
+	}
+	job = jobs[0] // Holding happens in enqueueJobs
+	if publishErrs[0] != nil {
+		http.Error(w, "Failed to submit job: "+publishErrs[0].Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Infof("Job resubmitted: id=%s from=%s", job.info.JobID, original.JobID)
+
+	resp := job.response(now, "Job resubmitted from "+original.JobID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// resubmitRequest rebuilds a job request from a stored job and applies the overrides
+// Array membership isn't copied; the new job stands on its own
+func resubmitRequest(original storage.JobInfo, overrides ResubmitRequest) (JobRequest, error) {
+	var jobMsg JobMessage
+	if err := json.Unmarshal(original.Payload, &jobMsg); err != nil {
+		return JobRequest{}, err
+	}
+
+	jobReq := JobRequest{
+		Type:        jobMsg.Type,
+		Name:        jobMsg.Name,
+		Description: jobMsg.Description,
+		Project:     jobMsg.Project,
+		GPUType:     jobMsg.GPUType,
+		GPUCount:    jobMsg.GPUCount,
+		Priority:    jobMsg.Priority,
+		Params:      jobMsg.Params,
+		Tags:        jobMsg.Tags,
+		RetryPolicy: original.RetryPolicy,
+		MaxRuntime:  jobMsg.MaxRuntime,
+		DatasetID:   jobMsg.DatasetID,
+		Secrets:     jobMsg.Secrets,
+		Preemptible: jobMsg.Preemptible,
+
+		// Already expanded - keep the template version the original used
+		TemplateID:      original.TemplateID,
+		TemplateVersion: original.TemplateVersion,
+		templateApplied: true,
+	}
+
+	if overrides.Name != "" {
+		jobReq.Name = overrides.Name
+	}
+	if overrides.GPUType != "" {
+		jobReq.GPUType = overrides.GPUType
+	}
+	if overrides.GPUCount != 0 {
+		jobReq.GPUCount = overrides.GPUCount
+	}
+	if overrides.Priority != nil {
+		jobReq.Priority = *overrides.Priority
+	}
+	jobReq.Params = mergeParams(jobReq.Params, overrides.Params)
+
+	// A deadline that already passed would just reject the copy
+	if jobMsg.Deadline != nil && jobMsg.Deadline.After(time.Now()) {
+		jobReq.Deadline = jobMsg.Deadline
+	}
+
+	return jobReq, nil
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"siger-api-gateway/internal/messaging"
+	"siger-api-gateway/internal/storage"
+)
+
+// retryFailedJob republishes a failed job if its retry policy allows it
+// Registered as a NATS status handler so it sees every jobs.status update
+// The job is requeued right away and published after the backoff delay
+// Preempted jobs are retried the same way with reason "preempted"; if the policy
+// won't retry them they are failed, since nothing else will pick them up
+func (h *JobSubmissionHandler) retryFailedJob(update messaging.JobStatusUpdate, job storage.JobInfo) {
+	if job.Status != storage.JobStatusFailed && job.Status != storage.JobStatusPreempted {
+		return
+	}
+	if job.RetryPolicy == nil {
+		return // Preempted jobs without a policy are left to the scheduler
+	}
+	if job.PreemptedBy != "" {
+		return // Preempted by the gateway, requeued once the preemptor starts
+	}
+
+	reason := update.Reason
+	if job.Status == storage.JobStatusPreempted && reason == "" {
+		reason = storage.FailureReasonPreempted
+	}
+
+	policy := job.RetryPolicy
+	attempt := retryAttempt(job)
+	if attempt >= policy.MaxAttempts {
+		h.logger.Infof("Job failed after final attempt: id=%s attempt=%d", job.JobID, job.Attempt)
+		h.failPreemptedJob(job, reason, "Preempted on final attempt")
+		return
+	}
+	if !policy.ShouldRetry(reason) {
+		h.logger.Infof("Job failure not retryable: id=%s reason=%q", job.JobID, reason)
+		h.failPreemptedJob(job, reason, "Preempted and retry policy does not retry "+reason)
+		return
+	}
+
+	nextAttempt := attempt + 1
+	delay := policy.BackoffFor(nextAttempt)
+	message := fmt.Sprintf("Retry scheduled in %s (attempt %d of %d)", delay, nextAttempt, policy.MaxAttempts)
+
+	requeued, err := h.jobStore.RequeueJob(job.JobID, reason, message)
+	if err != nil {
+		h.logger.Warnf("Failed to requeue job for retry: id=%s error=%v", job.JobID, err)
+		return
+	}
+
+	h.logger.Infof("Job retry scheduled: id=%s attempt=%d delay=%s reason=%q",
+		job.JobID, requeued.Attempt, delay, reason)
+
+	time.AfterFunc(delay, func() {
+		h.publishRetry(requeued.JobID, requeued.Attempt)
+	})
+}
+
+// retryAttempt returns the job's attempt number as the retry policy counts it
+// Resuming a paused job starts a new attempt too, but the user asked for
+// that one, so it doesn't use up the retries
+func retryAttempt(job storage.JobInfo) int {
+	attempt := job.Attempt
+	for _, previous := range job.Attempts {
+		if previous.Status == storage.JobStatusPaused {
+			attempt--
+		}
+	}
+	return attempt
+}
+
+// failPreemptedJob fails a preempted job that won't be retried
+func (h *JobSubmissionHandler) failPreemptedJob(job storage.JobInfo, reason, message string) {
+	if job.Status != storage.JobStatusPreempted {
+		return
+	}
+	if _, err := h.jobStore.TransitionJob(job.JobID, storage.JobStatusFailed, message, reason); err != nil {
+		h.logger.Warnf("Failed to fail preempted job: id=%s error=%v", job.JobID, err)
+	}
+}
+
+// publishRetry republishes the stored job message for the given attempt
+// Skips the publish if the job moved on while we were waiting (e.g. cancelled)
+func (h *JobSubmissionHandler) publishRetry(jobID string, attempt int) {
+	job, err := h.jobStore.GetJob(jobID)
+	if err != nil {
+		h.logger.Warnf("Job disappeared before retry: id=%s error=%v", jobID, err)
+		return
+	}
+	if job.Status != storage.JobStatusQueued || job.Attempt != attempt {
+		h.logger.Infof("Skipping stale retry: id=%s status=%s attempt=%d", jobID, job.Status, job.Attempt)
+		return
+	}
+	if h.holding() {
+		h.holdJob(job)
+		return
+	}
+
+	var jobMsg JobMessage
+	if err := json.Unmarshal(job.Payload, &jobMsg); err != nil {
+		h.logger.Errorf("Failed to decode stored job message for retry: id=%s error=%v", jobID, err)
+		return
+	}
+	jobMsg.Attempt = attempt
+	jobMsg.Checkpoint = job.Checkpoint // Retries pick up from the last checkpoint as well
+	jobMsg.Timestamp = time.Now().UTC()
+
+	// Queued in the outbox, so a NATS outage delays the retry instead of failing it
+	if err := h.publishJob(jobMsg); err != nil {
+		h.logger.Errorf("Failed to publish job retry: id=%s attempt=%d error=%v", jobID, attempt, err)
+		h.jobStore.UpdateJobStatus(jobID, storage.JobStatusFailed, "Retry could not be published: "+err.Error())
+		return
+	}
+
+	h.logger.Infof("Job retry queued: id=%s attempt=%d", jobID, attempt)
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -10,7 +13,12 @@ import (
 
 	"siger-api-gateway/internal"
 	"siger-api-gateway/internal/messaging"
+	"siger-api-gateway/internal/metering"
+	"siger-api-gateway/internal/middleware"
+	"siger-api-gateway/internal/quota"
+	"siger-api-gateway/internal/secrets"
 	"siger-api-gateway/internal/storage"
+	"siger-api-gateway/internal/workers"
 )
 
 // JobType defines the type of job to submit
@@ -55,11 +63,95 @@ type JobRequest struct {
 	Type        JobType  `json:"type"`
 	Name        string   `json:"name"`
 	Description string   `json:"description,omitempty"`
+	Project     string   `json:"project,omitempty"` // Project to account the job to, caller must be a member
 	GPUType     GPUType  `json:"gpu_type"`
 	GPUCount    int      `json:"gpu_count"`
 	Priority    int      `json:"priority,omitempty"`
 	Params      any      `json:"params"`
 	Tags        []string `json:"tags,omitempty"`
+
+	// Optional retry policy - failed jobs are republished by the gateway
+	RetryPolicy *storage.RetryPolicy `json:"retry_policy,omitempty"`
+
+	// Optional limits - running jobs are cancelled once they have run for
+	// MaxRuntime (e.g. "12h") or pass the Deadline, and queued jobs that
+	// reach the Deadline expire without starting
+	MaxRuntime string     `json:"max_runtime,omitempty"`
+	Deadline   *time.Time `json:"deadline,omitempty"`
+
+	// Optional uploaded dataset, the caller must be able to read it
+	DatasetID string `json:"dataset_id,omitempty"`
+
+	// Names of the caller's secrets the job needs, never the values
+	Secrets []string `json:"secrets,omitempty"`
+
+	// Lets the gateway stop the job for higher priority work, it's
+	// requeued once that work has started
+	Preemptible bool `json:"preemptible,omitempty"`
+
+	// Optional stored template - its job is filled in with Variables and
+	// the fields above override it. Version 0 means the latest version
+	TemplateID      string         `json:"template_id,omitempty"`
+	TemplateVersion int            `json:"template_version,omitempty"`
+	Variables       map[string]any `json:"variables,omitempty"`
+
+	templateApplied bool
+}
+
+// MaxJobPriority bounds job priorities in both directions
+// Keeps priorities comparable with the aging and fair-share points
+const MaxJobPriority = 1000
+
+// Validate checks the fields every job needs before it can be queued
+func (req JobRequest) Validate() error {
+	if req.Type == "" {
+		return errors.New("Job type is required")
+	}
+	if req.Name == "" {
+		return errors.New("Job name is required")
+	}
+	if req.GPUCount < 1 {
+		return errors.New("GPU count must be at least 1")
+	}
+	if err := validatePriority(req.Priority); err != nil {
+		return err
+	}
+	if req.RetryPolicy != nil {
+		if err := req.RetryPolicy.Validate(); err != nil {
+			return fmt.Errorf("Invalid retry policy: %w", err)
+		}
+	}
+	if req.MaxRuntime != "" {
+		d, err := time.ParseDuration(req.MaxRuntime)
+		if err != nil {
+			return fmt.Errorf("Invalid max runtime: %w", err)
+		}
+		if d <= 0 {
+			return errors.New("Max runtime must be positive")
+		}
+	}
+	if req.Deadline != nil && !req.Deadline.After(time.Now()) {
+		return errors.New("Deadline must be in the future")
+	}
+	seen := make(map[string]bool, len(req.Secrets))
+	for _, name := range req.Secrets {
+		if !secrets.ValidName(name) {
+			return fmt.Errorf("Invalid secret name %q", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("Secret %q is listed twice", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// validatePriority checks a priority is within MaxJobPriority
+func validatePriority(priority int) error {
+	if priority < -MaxJobPriority || priority > MaxJobPriority {
+		return fmt.Errorf("Priority must be between %d and %d", -MaxJobPriority, MaxJobPriority)
+	}
+	return nil
 }
 
 // JobResponse represents the response for a job submission
@@ -70,6 +162,7 @@ type JobResponse struct {
 	Status    string    `json:"status"`
 	Timestamp time.Time `json:"timestamp"`
 	Message   string    `json:"message,omitempty"`
+	Warnings  []string  `json:"warnings,omitempty"`
 }
 
 // JobMessage represents a message to be published to NATS
@@ -78,6 +171,7 @@ type JobResponse struct {
 type JobMessage struct {
 	JobID       string    `json:"job_id"`
 	UserID      string    `json:"user_id,omitempty"`
+	Project     string    `json:"project,omitempty"`
 	Type        JobType   `json:"type"`
 	Name        string    `json:"name"`
 	Description string    `json:"description,omitempty"`
@@ -87,6 +181,33 @@ type JobMessage struct {
 	Params      any       `json:"params"`
 	Tags        []string  `json:"tags,omitempty"`
 	Timestamp   time.Time `json:"timestamp"`
+	Attempt     int       `json:"attempt"` // 1 for the first run, bumped on every retry
+	ArrayID     string    `json:"array_id,omitempty"`
+	ArrayIndex  int       `json:"array_index,omitempty"`
+
+	// Limits so workers can stop the job themselves as well
+	MaxRuntime string     `json:"max_runtime,omitempty"`
+	Deadline   *time.Time `json:"deadline,omitempty"`
+
+	ResubmittedFrom string `json:"resubmitted_from,omitempty"` // Job this one is a copy of
+	Checkpoint      string `json:"checkpoint,omitempty"`       // Set on resumed attempts
+
+	// Uploaded dataset the job reads. Workers sharing the gateway's storage
+	// may open DatasetURI, others download DatasetURL with DatasetToken
+	DatasetID       string `json:"dataset_id,omitempty"`
+	DatasetURI      string `json:"dataset_uri,omitempty"`
+	DatasetChecksum string `json:"dataset_checksum,omitempty"`
+	DatasetURL      string `json:"dataset_url,omitempty"`
+	DatasetToken    string `json:"dataset_token,omitempty"`
+
+	// Secrets the job needs, fetched once with SecretsToken from
+	// POST /api/v1/jobs/{jobID}/secrets - the values are never in the message
+	Secrets      []string `json:"secrets,omitempty"`
+	SecretsToken string   `json:"secrets_token,omitempty"`
+
+	// Preemptible jobs get a "preempt" on jobs.control.<jobID> when they're
+	// displaced and should checkpoint and stop
+	Preemptible bool `json:"preemptible,omitempty"`
 }
 
 // JobSubmissionHandler handles job submission requests
@@ -95,58 +216,143 @@ type JobMessage struct {
 type JobSubmissionHandler struct {
 	natsClient *messaging.NATSClient
 	jobStore   *storage.JobStore
+	quotas     *quota.Manager
+	meter      *metering.Meter
+	templates  *storage.TemplateStore
+	datasets   *storage.DatasetStore
+	secrets    *secrets.Store
+	workers    *workers.Registry
+	capacity   internal.CapacityConfig
 	logger     internal.LoggerInterface
+
+	// Priority dispatcher, see SetDispatchConfig
+	dispatch      internal.DispatchConfig
+	agingInterval time.Duration
+	dispatchWake  chan struct{}
+	preemption    internal.PreemptionConfig
+
+	// Relay publishing the job store's outbox, nil without NATS
+	outbox *messaging.OutboxRelay
 }
 
 // NewJobSubmissionHandler creates a new job submission handler
 // Now using a real job store for persistence instead of ephemeral responses
 // This gives us job history, status tracking, and user filtering - virjilakrum
 func NewJobSubmissionHandler(natsClient *messaging.NATSClient, jobStore *storage.JobStore) *JobSubmissionHandler {
-	return &JobSubmissionHandler{
+	h := &JobSubmissionHandler{
 		natsClient: natsClient,
 		jobStore:   jobStore,
 		logger:     internal.Logger,
 	}
+
+	// Failed jobs with a retry policy are republished from the status update path
+	// Jobs preempted by the gateway are requeued once their preemptor starts
+	if natsClient != nil {
+		natsClient.OnStatusUpdate(h.retryFailedJob)
+		natsClient.OnStatusUpdate(h.requeueAfterPreemptor)
+	}
+
+	return h
 }
 
-// RegisterRoutes registers the job submission routes
-// Using RESTful patterns for job management
-// These endpoints map directly to GPU cluster operations - virjilakrum
-func (h *JobSubmissionHandler) RegisterRoutes(r chi.Router) {
-	r.Post("/jobs", h.SubmitJob)
-	r.Get("/jobs/{jobID}", h.GetJobStatus)
-	r.Delete("/jobs/{jobID}", h.CancelJob)
+// SetOutboxRelay sets the relay woken up when job messages are queued
+// Optional - without it queued messages wait for the relay's next tick
+func (h *JobSubmissionHandler) SetOutboxRelay(relay *messaging.OutboxRelay) {
+	h.outbox = relay
+}
 
-	// New endpoints for listing jobs
-	r.Get("/jobs", h.ListJobs)
-	r.Get("/jobs/status/{status}", h.ListJobsByStatus)
+// SetQuotaManager sets the quota manager checked on every submission
+// Optional - without it no quotas are enforced
+func (h *JobSubmissionHandler) SetQuotaManager(quotas *quota.Manager) {
+	h.quotas = quotas
 }
 
-// SubmitJob handles a job submission request
-// This puts the job into the appropriate NATS queue for processing
-// Queue selection is based on job type for better worker specialization - virjilakrum
-func (h *JobSubmissionHandler) SubmitJob(w http.ResponseWriter, r *http.Request) {
-	// Parse request body
-	var jobReq JobRequest
-	if err := json.NewDecoder(r.Body).Decode(&jobReq); err != nil {
-		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+// requestError is a job request problem together with the HTTP status it maps to
+type requestError struct {
+	status  int
+	message string
+}
+
+// Error implements the error interface
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// writeRequestError writes err with its status, or 500 for unexpected errors
+func writeRequestError(w http.ResponseWriter, err error) {
+	if reqErr, ok := err.(*requestError); ok {
+		http.Error(w, reqErr.message, reqErr.status)
 		return
 	}
+	http.Error(w, "Failed to submit job: "+err.Error(), http.StatusInternalServerError)
+}
+
+// pendingJob is a validated job that hasn't been stored or published yet
+type pendingJob struct {
+	info     storage.JobInfo
+	msg      JobMessage
+	warnings []string
+}
+
+// response builds the submission response for a stored job
+// Held jobs say so instead of the given message
+func (job pendingJob) response(now time.Time, message string) JobResponse {
+	resp := JobResponse{
+		JobID:     job.info.JobID,
+		Status:    string(storage.JobStatusQueued),
+		Timestamp: now,
+		Message:   message,
+		Warnings:  job.warnings,
+	}
+	if job.info.Held {
+		resp.Message = job.info.Message
+	}
+	return resp
+}
+
+// newPendingJob validates a request and builds the stored job and worker message
+// Every submission path goes through here so the checks can't drift apart
+func (h *JobSubmissionHandler) newPendingJob(ctx context.Context, jobReq JobRequest, now time.Time) (pendingJob, error) {
+	jobReq, err := h.applyTemplate(ctx, jobReq)
+	if err != nil {
+		return pendingJob{}, err
+	}
 
-	// Validate request
 	// Strict validation prevents invalid jobs from being queued
 	// This saves resources that would be wasted on doomed jobs - virjilakrum
-	if jobReq.Type == "" {
-		http.Error(w, "Job type is required", http.StatusBadRequest)
-		return
+	if err := jobReq.Validate(); err != nil {
+		return pendingJob{}, &requestError{status: http.StatusBadRequest, message: err.Error()}
 	}
-	if jobReq.Name == "" {
-		http.Error(w, "Job name is required", http.StatusBadRequest)
-		return
+
+	// Jobs for hardware that doesn't exist would sit in the queue forever
+	warnings, err := h.checkCapacity(jobReq.GPUType, jobReq.GPUCount)
+	if err != nil {
+		return pendingJob{}, err
 	}
-	if jobReq.GPUCount < 1 {
-		http.Error(w, "GPU count must be at least 1", http.StatusBadRequest)
-		return
+
+	// Get user ID from context (set by JWT middleware)
+	userIDStr, _ := ctx.Value(middleware.UserIDContextKey).(string)
+
+	// Jobs can only be accounted to projects the caller belongs to
+	if jobReq.Project != "" && !middleware.IsProjectMember(ctx, jobReq.Project) {
+		return pendingJob{}, &requestError{
+			status:  http.StatusForbidden,
+			message: "Forbidden: not a member of project " + jobReq.Project,
+		}
+	}
+
+	// Datasets are checked now rather than when a worker fails to read them
+	var dataset storage.Dataset
+	if jobReq.DatasetID != "" {
+		if dataset, err = h.resolveDataset(ctx, jobReq.DatasetID); err != nil {
+			return pendingJob{}, err
+		}
+	}
+
+	if len(jobReq.Secrets) > 0 {
+		if err := h.checkSecrets(userIDStr, jobReq.Secrets); err != nil {
+			return pendingJob{}, err
+		}
 	}
 
 	// Generate a unique job ID
@@ -154,72 +360,264 @@ func (h *JobSubmissionHandler) SubmitJob(w http.ResponseWriter, r *http.Request)
 	// This is critical as we scale to thousands of jobs per minute - virjilakrum
 	jobID := uuid.New().String()
 
-	// Get user ID from context (assuming authentication middleware has set it)
-	userID := r.Context().Value("user_id")
-	var userIDStr string
-	if userID != nil {
-		userIDStr = userID.(string)
+	job := pendingJob{
+		info: storage.JobInfo{
+			JobID:       jobID,
+			UserID:      userIDStr,
+			Project:     jobReq.Project,
+			Type:        string(jobReq.Type),
+			Name:        jobReq.Name,
+			Status:      storage.JobStatusQueued,
+			SubmittedAt: now,
+			Message:     "Job submitted successfully",
+			Description: jobReq.Description,
+			GPUType:     string(jobReq.GPUType),
+			GPUCount:    jobReq.GPUCount,
+			Priority:    jobReq.Priority,
+			Params:      jobReq.Params,
+			Tags:        jobReq.Tags,
+			RetryPolicy: jobReq.RetryPolicy,
+			Attempt:     1,
+
+			TemplateID:      jobReq.TemplateID,
+			TemplateVersion: jobReq.TemplateVersion,
+			MaxRuntime:      jobReq.MaxRuntime,
+			DatasetID:       dataset.DatasetID,
+			Secrets:         jobReq.Secrets,
+			Preemptible:     jobReq.Preemptible,
+			MayPreempt:      middleware.HasPermission(ctx, middleware.PermissionJobsPreempt),
+		},
+		msg: JobMessage{
+			JobID:       jobID,
+			UserID:      userIDStr,
+			Project:     jobReq.Project,
+			Type:        jobReq.Type,
+			Name:        jobReq.Name,
+			Description: jobReq.Description,
+			GPUType:     jobReq.GPUType,
+			GPUCount:    jobReq.GPUCount,
+			Priority:    jobReq.Priority,
+			Params:      jobReq.Params,
+			Tags:        jobReq.Tags,
+			Timestamp:   now,
+			Attempt:     1,
+			MaxRuntime:  jobReq.MaxRuntime,
+			Deadline:    jobReq.Deadline,
+
+			DatasetID:       dataset.DatasetID,
+			DatasetURI:      dataset.URI,
+			DatasetChecksum: dataset.Checksum,
+			DatasetURL:      jobDatasetURL(jobID, dataset.DatasetID),
+			Secrets:         jobReq.Secrets,
+			Preemptible:     jobReq.Preemptible,
+		},
+	}
+	if jobReq.Deadline != nil {
+		job.info.Deadline = jobReq.Deadline.UTC()
 	}
+	job.warnings = warnings
+	return job, nil
+}
 
-	// Current timestamp
-	now := time.Now().UTC()
+// enqueueJobs admits, stores and publishes jobs
+// Quota admission is all or nothing; if it fails the response is written and ok is false
+// Otherwise every job is stored together with its outbox message and the
+// per-job errors are returned, jobs whose message couldn't be built are
+// marked failed
+func (h *JobSubmissionHandler) enqueueJobs(w http.ResponseWriter, r *http.Request, jobs []pendingJob) ([]error, bool) {
+	infos := make([]storage.JobInfo, len(jobs))
+	for i := range jobs {
+		// Keep the serialized message around so retries publish exactly what was submitted
+		payload, err := json.Marshal(jobs[i].msg)
+		if err != nil {
+			h.logger.Errorf("Failed to marshal job message: %v", err)
+			http.Error(w, "Failed to submit job: "+err.Error(), http.StatusInternalServerError)
+			return nil, false
+		}
+		jobs[i].info.Payload = payload
+		infos[i] = jobs[i].info
+	}
+
+	// Enforce quotas before the jobs are stored or published
+	// 403 if the request can never fit, 429 if it fits once other jobs finish
+	if h.quotas != nil {
+		role, _ := r.Context().Value(middleware.UserRoleContextKey).(string)
+		if err := h.quotas.AdmitBatch(infos, role); err != nil {
+			h.writeQuotaError(w, err, infos[0], len(infos))
+			return nil, false
+		}
+	}
 
-	// Create job message
-	jobMsg := JobMessage{
-		JobID:       jobID,
-		UserID:      userIDStr,
-		Type:        jobReq.Type,
-		Name:        jobReq.Name,
-		Description: jobReq.Description,
-		GPUType:     jobReq.GPUType,
-		GPUCount:    jobReq.GPUCount,
-		Priority:    jobReq.Priority,
-		Params:      jobReq.Params,
-		Tags:        jobReq.Tags,
-		Timestamp:   now,
+	// Jobs that can't start now wait in the gateway instead of on jobs.<type>
+	h.holdSaturatedJobs(jobs)
+	for i := range jobs {
+		infos[i] = jobs[i].info
+	}
+
+	publishErrs := make([]error, len(jobs))
+	var messages []storage.OutboxMessage
+	for i, job := range jobs {
+		if job.info.Held {
+			h.logger.Infof("Job held until GPUs free up: id=%s gpu=%s count=%d", job.info.JobID, job.info.GPUType, job.info.GPUCount)
+			continue
+		}
+		message, ok, err := h.outboxMessage(job.msg)
+		if err != nil {
+			publishErrs[i] = err
+			continue
+		}
+		if ok {
+			messages = append(messages, message)
+		}
 	}
 
 	// Store job information in the job store
 	// This is what allows us to track job status persistently - virjilakrum
-	h.jobStore.AddJob(storage.JobInfo{
-		JobID:       jobID,
-		UserID:      userIDStr,
-		Type:        string(jobReq.Type),
-		Name:        jobReq.Name,
-		Status:      storage.JobStatusQueued,
-		SubmittedAt: now,
-		Message:     "Job submitted successfully",
-	})
+	// The messages go in under the same lock, the relay publishes them
+	h.jobStore.AddJobsWithMessages(infos, messages)
 
-	// Determine the subject based on job type
-	// Using NATS subject hierarchy to route to appropriate workers
-	// This lets us add new job types without changing code - virjilakrum
-	subject := "jobs." + string(jobReq.Type)
+	for i, err := range publishErrs {
+		if err == nil {
+			continue
+		}
+		h.jobStore.TransitionJob(jobs[i].info.JobID, storage.JobStatusFailed,
+			"Job could not be published: "+err.Error(), "")
+	}
+	h.outbox.Wake()
+	if h.dispatching() {
+		h.wakeDispatcher()
+	}
+	return publishErrs, true
+}
+
+// writeQuotaError writes a quota rejection for jobs of the given user and project
+func (h *JobSubmissionHandler) writeQuotaError(w http.ResponseWriter, err error, job storage.JobInfo, count int) {
+	quotaErr, ok := err.(*quota.Error)
+	if !ok {
+		http.Error(w, "Failed to check quota: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Infow("Job rejected by quota", "user", job.UserID, "project", job.Project,
+		"jobs", count, "reason", quotaErr.Error())
+	if !quotaErr.Permanent {
+		w.Header().Set("Retry-After", "60")
+	}
+	http.Error(w, "Quota exceeded: "+quotaErr.Error(), quotaErr.StatusCode())
+}
+
+// publishJob queues a message for a stored job in the outbox
+// Used for released holds, retries and resumes; the relay publishes it
+func (h *JobSubmissionHandler) publishJob(jobMsg JobMessage) error {
+	message, ok, err := h.outboxMessage(jobMsg)
+	if err != nil || !ok {
+		return err
+	}
 
+	h.jobStore.EnqueueMessage(message)
+	h.outbox.Wake()
+	return nil
+}
+
+// outboxMessage builds the outbox message that starts a job attempt
+// ok is false without NATS, there's nothing to publish the message with
+func (h *JobSubmissionHandler) outboxMessage(jobMsg JobMessage) (storage.OutboxMessage, bool, error) {
 	// Publish job message to NATS
 	// Using JetStream for persistence in case workers are offline
 	// This gives us at-least-once delivery semantics - virjilakrum
-	if h.natsClient != nil {
-		_, err := h.natsClient.PublishToStream(subject, jobMsg)
-		if err != nil {
-			h.logger.Errorf("Failed to publish job message: %v", err)
-			http.Error(w, "Failed to submit job: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
+	if h.natsClient == nil {
+		h.logger.Warnf("NATS client not available, job stored but not published: id=%s", jobMsg.JobID)
+		return storage.OutboxMessage{}, false, nil
+	}
+
+	// The token is added here, after the payload was stored, so retries
+	// never republish a token that was already used
+	if err := h.attachSecretsToken(&jobMsg); err != nil {
+		h.logger.Errorf("Failed to issue secrets token: id=%s error=%v", jobMsg.JobID, err)
+		return storage.OutboxMessage{}, false, err
+	}
+	if err := h.attachDatasetToken(&jobMsg); err != nil {
+		h.logger.Errorf("Failed to issue dataset token: id=%s error=%v", jobMsg.JobID, err)
+		return storage.OutboxMessage{}, false, err
+	}
 
-		h.logger.Infof("Job submitted: id=%s type=%s gpu=%s count=%d", jobID, jobReq.Type, jobReq.GPUType, jobReq.GPUCount)
-	} else {
-		h.logger.Warnf("NATS client not available, job stored but not published: id=%s", jobID)
+	payload, err := json.Marshal(jobMsg)
+	if err != nil {
+		h.logger.Errorf("Failed to marshal job message: id=%s error=%v", jobMsg.JobID, err)
+		return storage.OutboxMessage{}, false, err
 	}
 
-	// Return response
-	resp := JobResponse{
-		JobID:     jobID,
-		Status:    string(storage.JobStatusQueued),
-		Timestamp: now,
-		Message:   "Job submitted successfully",
+	// Determine the subject based on job type
+	// Using NATS subject hierarchy to route to appropriate workers
+	// This lets us add new job types without changing code - virjilakrum
+	// With the dispatcher on they go to a work queue instead
+	now := time.Now().UTC()
+	return storage.OutboxMessage{
+		ID:            uuid.New().String(),
+		JobID:         jobMsg.JobID,
+		Attempt:       jobMsg.Attempt,
+		Subject:       h.jobSubject(string(jobMsg.Type)),
+		Payload:       payload,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}, true, nil
+}
+
+// RegisterRoutes registers the job submission routes
+// Using RESTful patterns for job management
+// These endpoints map directly to GPU cluster operations - virjilakrum
+func (h *JobSubmissionHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/jobs", h.SubmitJob)
+	r.Post("/jobs/estimate", h.EstimateJob)
+	r.Post("/jobs/batch", h.SubmitBatch)
+	r.Get("/jobs/arrays/{arrayID}", h.GetJobArray)
+	r.Delete("/jobs/arrays/{arrayID}", h.CancelJobArray)
+	r.Get("/jobs/{jobID}", h.GetJobStatus)
+	r.Patch("/jobs/{jobID}", h.UpdateJob)
+	r.Delete("/jobs/{jobID}", h.CancelJob)
+	r.Get("/jobs/{jobID}/history", h.GetJobHistory)
+	r.Post("/jobs/{jobID}/resubmit", h.ResubmitJob)
+	r.Post("/jobs/{jobID}/pause", h.PauseJob)
+	r.Post("/jobs/{jobID}/resume", h.ResumeJob)
+
+	// Filtered and paginated job listing
+	r.Get("/jobs", h.ListJobs)
+}
+
+// SubmitJob handles a job submission request
+// This puts the job into the appropriate NATS queue for processing
+// Queue selection is based on job type for better worker specialization - virjilakrum
+func (h *JobSubmissionHandler) SubmitJob(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var jobReq JobRequest
+	if err := json.NewDecoder(r.Body).Decode(&jobReq); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
 	}
 
+	// Validate request and build the job
+	now := time.Now().UTC()
+	job, err := h.newPendingJob(r.Context(), jobReq, now)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	// Store and publish
+	jobs := []pendingJob{job}
+	publishErrs, ok := h.enqueueJobs(w, r, jobs)
+	if !ok {
+		return
+	}
+	job = jobs[0] // Holding happens in enqueueJobs
+	if publishErrs[0] != nil {
+		http.Error(w, "Failed to submit job: "+publishErrs[0].Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	resp := job.response(now, "Job submitted successfully")
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted) // 202 Accepted
 	json.NewEncoder(w).Encode(resp)
@@ -235,28 +633,70 @@ func (h *JobSubmissionHandler) GetJobStatus(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	view, ok := jobView(r, JobViewFull)
+	if !ok {
+		http.Error(w, "Invalid view: must be compact or full", http.StatusBadRequest)
+		return
+	}
+
 	// Get job information from the job store
+	// Foreign jobs get the same 404 as missing ones so IDs can't be probed
 	jobInfo, err := h.jobStore.GetJob(jobID)
+	if err == storage.ErrJobNotFound || (err == nil && !canAccessJob(r.Context(), jobInfo)) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		if err == storage.ErrJobNotFound {
-			http.Error(w, "Job not found", http.StatusNotFound)
-		} else {
-			h.logger.Errorw("Failed to get job status", "jobID", jobID, "error", err)
-			http.Error(w, "Failed to get job status: "+err.Error(), http.StatusInternalServerError)
-		}
+		h.logger.Errorw("Failed to get job status", "jobID", jobID, "error", err)
+		http.Error(w, "Failed to get job status: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Return job status
-	resp := JobResponse{
-		JobID:     jobInfo.JobID,
-		Status:    string(jobInfo.Status),
-		Timestamp: time.Now().UTC(),
-		Message:   jobInfo.Message,
+	// Return job status - full details unless the compact form was asked for
+	// The ETag is the job revision, for If-Match on PATCH
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", jobETag(jobInfo))
+	if view == JobViewCompact {
+		resp := newJobResponse(jobInfo)
+		resp.Timestamp = time.Now().UTC()
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	json.NewEncoder(w).Encode(newJobDetail(jobInfo, time.Now().UTC()))
+}
+
+// JobHistoryResponse is the response for GET /jobs/{jobID}/history
+type JobHistoryResponse struct {
+	JobID   string                  `json:"job_id"`
+	Status  string                  `json:"status"`
+	History []storage.JobTransition `json:"history"`
+}
+
+// GetJobHistory returns the ordered lifecycle transitions of a job
+func (h *JobSubmissionHandler) GetJobHistory(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "jobID")
+	if jobID == "" {
+		http.Error(w, "Job ID is required", http.StatusBadRequest)
+		return
+	}
+
+	jobInfo, err := h.jobStore.GetJob(jobID)
+	if err != nil || !canAccessJob(r.Context(), jobInfo) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	history := jobInfo.History
+	if history == nil {
+		history = []storage.JobTransition{}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(JobHistoryResponse{
+		JobID:   jobInfo.JobID,
+		Status:  string(jobInfo.Status),
+		History: history,
+	})
 }
 
 // CancelJob handles a job cancellation request
@@ -269,125 +709,152 @@ func (h *JobSubmissionHandler) CancelJob(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Check if the job exists
-	_, err := h.jobStore.GetJob(jobID)
-	if err != nil {
-		if err == storage.ErrJobNotFound {
-			http.Error(w, "Job not found", http.StatusNotFound)
-		} else {
-			h.logger.Errorw("Failed to get job for cancellation", "jobID", jobID, "error", err)
-			http.Error(w, "Failed to cancel job: "+err.Error(), http.StatusInternalServerError)
-		}
+	// Check if the job exists and belongs to the caller
+	jobInfo, err := h.jobStore.GetJob(jobID)
+	if err == storage.ErrJobNotFound || (err == nil && !canAccessJob(r.Context(), jobInfo)) {
+		http.Error(w, "Job not found", http.StatusNotFound)
 		return
 	}
-
-	// Update job status
-	err = h.jobStore.UpdateJobStatus(jobID, storage.JobStatusCancelled, "Job cancellation requested")
 	if err != nil {
-		h.logger.Errorw("Failed to update job status for cancellation", "jobID", jobID, "error", err)
+		h.logger.Errorw("Failed to get job for cancellation", "jobID", jobID, "error", err)
 		http.Error(w, "Failed to cancel job: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Publish a cancel message to NATS
-	// Using a dedicated subject for cancellations
-	// Workers subscribe to this to detect jobs they should stop - virjilakrum
-	if h.natsClient != nil {
-		cancelMsg := struct {
-			JobID     string    `json:"job_id"`
-			Timestamp time.Time `json:"timestamp"`
-		}{
-			JobID:     jobID,
-			Timestamp: time.Now().UTC(),
-		}
-
-		err = h.natsClient.Publish("jobs.cancel", cancelMsg)
-		if err != nil {
-			h.logger.Errorf("Failed to publish job cancellation message: %v", err)
-			http.Error(w, "Failed to cancel job: "+err.Error(), http.StatusInternalServerError)
+	// Update job status
+	// Finished jobs are left alone and never re-published to jobs.cancel
+	jobInfo, err = h.cancelJob(jobID, "Job cancellation requested", "")
+	if err != nil {
+		if err == storage.ErrJobFinished {
+			http.Error(w, "Job already "+string(jobInfo.Status)+", cannot be cancelled", http.StatusConflict)
 			return
 		}
-
-		h.logger.Infof("Job cancellation requested: id=%s", jobID)
-	} else {
-		h.logger.Warnf("NATS client not available, job cancelled but notification not published: id=%s", jobID)
+		http.Error(w, "Failed to cancel job: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	// Return response
 	resp := JobResponse{
 		JobID:     jobID,
-		Status:    string(storage.JobStatusCancelled),
+		Status:    string(jobInfo.Status), // cancelling until the worker confirms
 		Timestamp: time.Now().UTC(),
 		Message:   "Job cancellation requested",
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	if jobInfo.Status == storage.JobStatusCancelling {
+		w.WriteHeader(http.StatusAccepted)
+	}
 	json.NewEncoder(w).Encode(resp)
 }
 
-// ListJobs handles listing all jobs for the authenticated user
-// This endpoint is critical for building user dashboards
-// Only shows jobs belonging to the authenticated user - virjilakrum
-func (h *JobSubmissionHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
-	// Get user ID from context (assuming authentication middleware has set it)
-	userID := r.Context().Value("user_id")
-	if userID == nil {
-		http.Error(w, "User ID not found in request context", http.StatusUnauthorized)
-		return
+// cancelJob cancels a job in the store and tells the workers about it
+// The reason is passed on to workers, e.g. "timeout" from the watchdog
+func (h *JobSubmissionHandler) cancelJob(jobID, message, reason string) (storage.JobInfo, error) {
+	jobInfo, err := h.jobStore.CancelJob(jobID, message, reason)
+	if err != nil {
+		if err != storage.ErrJobFinished {
+			h.logger.Errorw("Failed to update job status for cancellation", "jobID", jobID, "error", err)
+		}
+		return jobInfo, err
 	}
 
-	userIDStr := userID.(string)
+	// The cancel stands once it's stored, the watchdog resends it to workers
+	if err := h.publishCancel(jobInfo, reason); err != nil {
+		h.logger.Warnf("Job cancelled but workers not notified yet: id=%s error=%v", jobID, err)
+	}
+	return jobInfo, nil
+}
 
-	// Get all jobs for the user
-	jobs := h.jobStore.ListJobsByUser(userIDStr)
+// publishCancel tells workers to stop a job
+// Using a dedicated subject for cancellations
+// Workers subscribe to this to detect jobs they should stop - virjilakrum
+// The attempt lets a worker drop that attempt's message if it's still queued
+func (h *JobSubmissionHandler) publishCancel(job storage.JobInfo, reason string) error {
+	jobID := job.JobID
+	if h.natsClient == nil {
+		h.logger.Warnf("NATS client not available, job cancelled but notification not published: id=%s", jobID)
+		return nil
+	}
 
-	// Convert to response format
-	var responses []JobResponse
-	for _, job := range jobs {
-		responses = append(responses, JobResponse{
-			JobID:     job.JobID,
-			Status:    string(job.Status),
-			Timestamp: job.SubmittedAt,
-			Message:   job.Message,
-		})
+	cancelMsg := struct {
+		JobID     string    `json:"job_id"`
+		Attempt   int       `json:"attempt"`
+		Reason    string    `json:"reason,omitempty"`
+		Timestamp time.Time `json:"timestamp"`
+	}{
+		JobID:     jobID,
+		Attempt:   job.Attempt,
+		Reason:    reason,
+		Timestamp: time.Now().UTC(),
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(responses)
+	if err := h.natsClient.Publish("jobs.cancel", cancelMsg); err != nil {
+		h.logger.Errorf("Failed to publish job cancellation message: %v", err)
+		return err
+	}
+
+	h.logger.Infof("Job cancellation requested: id=%s", jobID)
+	return nil
 }
 
-// ListJobsByStatus handles listing all jobs with a specific status
-// This is mostly for admin users to see all jobs in the system
-// Important for monitoring and debugging - virjilakrum
-func (h *JobSubmissionHandler) ListJobsByStatus(w http.ResponseWriter, r *http.Request) {
-	// Get status parameter
-	statusParam := chi.URLParam(r, "status")
-	if statusParam == "" {
-		http.Error(w, "Status parameter is required", http.StatusBadRequest)
+// ListJobs handles listing jobs with filtering, sorting and cursor pagination
+// Users see their own jobs and their projects' jobs; admins can pass user_id=<id>
+// or scope=all to look across users (this replaced /jobs/status/{status})
+func (h *JobSubmissionHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context (set by JWT middleware)
+	userIDStr, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok || userIDStr == "" {
+		http.Error(w, "User ID not found in request context", http.StatusUnauthorized)
+		return
+	}
+	role, _ := r.Context().Value(middleware.UserRoleContextKey).(string)
+
+	// Lists default to the compact form to keep responses small
+	view, ok := jobView(r, JobViewCompact)
+	if !ok {
+		http.Error(w, "Invalid view: must be compact or full", http.StatusBadRequest)
 		return
 	}
 
-	// Only allow admin users to list all jobs
-	role := r.Context().Value("user_role")
-	if role == nil || role.(string) != "admin" {
-		http.Error(w, "Unauthorized: admin role required", http.StatusForbidden)
+	query, err := parseJobQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Get all jobs with the specified status
-	jobs := h.jobStore.ListJobsByStatus(storage.JobStatus(statusParam))
+	// Scope the query to the caller and their projects unless an admin asked for more
+	query.UserID = userIDStr
+	query.Projects, _ = r.Context().Value(middleware.UserProjectsContextKey).([]string)
+	scope := r.URL.Query().Get("scope")
+	otherUser := r.URL.Query().Get("user_id")
+	if scope == "all" || otherUser != "" {
+		if role != "admin" {
+			http.Error(w, "Unauthorized: admin role required", http.StatusForbidden)
+			return
+		}
+		query.UserID = otherUser
+		query.Projects = nil
+	}
 
-	// Convert to response format
-	var responses []JobResponse
-	for _, job := range jobs {
-		responses = append(responses, JobResponse{
-			JobID:     job.JobID,
-			Status:    string(job.Status),
-			Timestamp: job.SubmittedAt,
-			Message:   job.Message,
-		})
+	page, err := h.jobStore.QueryJobs(query)
+	if err != nil {
+		if err == storage.ErrInvalidCursor {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		} else {
+			h.logger.Errorw("Failed to list jobs", "error", err)
+			http.Error(w, "Failed to list jobs: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	resp := JobListResponse{
+		Jobs:       renderJobs(page.Jobs, view),
+		Total:      page.Total,
+		Limit:      query.Limit,
+		NextCursor: page.NextCursor,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(responses)
+	json.NewEncoder(w).Encode(resp)
 }
@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/middleware"
+	"siger-api-gateway/internal/storage"
+)
+
+// placeholderPattern matches {{variable}} placeholders in template strings
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// variableNamePattern is what a template variable may be called
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// TemplateRequest is the body for creating or updating a job template
+// Job uses the same fields as a job submission; any string in it may contain
+// {{variable}} placeholders, and a string that is only a placeholder takes
+// the variable's value with its JSON type, so "gpu_count": "{{gpus}}" works
+type TemplateRequest struct {
+	Name        string                              `json:"name"`
+	Description string                              `json:"description,omitempty"`
+	Project     string                              `json:"project,omitempty"` // Share with the project's members
+	Job         json.RawMessage                     `json:"job"`
+	Variables   map[string]storage.TemplateVariable `json:"variables,omitempty"`
+}
+
+// TemplateListResponse is the response for GET /templates
+type TemplateListResponse struct {
+	Templates []storage.JobTemplate `json:"templates"`
+}
+
+// TemplateHandler manages reusable job templates
+// Saves teams from copy-pasting the same training params into every submission
+type TemplateHandler struct {
+	templates *storage.TemplateStore
+	logger    internal.LoggerInterface
+}
+
+// NewTemplateHandler creates a new template handler
+func NewTemplateHandler(templates *storage.TemplateStore) *TemplateHandler {
+	return &TemplateHandler{
+		templates: templates,
+		logger:    internal.Logger,
+	}
+}
+
+// RegisterRoutes registers the template routes
+func (h *TemplateHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/templates", h.CreateTemplate)
+	r.Get("/templates", h.ListTemplates)
+	r.Get("/templates/{templateID}", h.GetTemplate)
+	r.Put("/templates/{templateID}", h.UpdateTemplate)
+	r.Delete("/templates/{templateID}", h.DeleteTemplate)
+}
+
+// CreateTemplate stores a new template owned by the caller
+func (h *TemplateHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok || userID == "" {
+		http.Error(w, "User ID not found in request context", http.StatusUnauthorized)
+		return
+	}
+
+	template, ok := h.decodeTemplate(w, r)
+	if !ok {
+		return
+	}
+	template.TemplateID = uuid.New().String()
+	template.OwnerID = userID
+
+	template = h.templates.CreateTemplate(template)
+	h.logger.Infof("Job template created: id=%s name=%s owner=%s", template.TemplateID, template.Name, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(template)
+}
+
+// ListTemplates returns the caller's templates and those shared with their projects
+func (h *TemplateHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	project := r.URL.Query().Get("project")
+	templates := h.templates.ListTemplates(func(t storage.JobTemplate) bool {
+		if project != "" && t.Project != project {
+			return false
+		}
+		return canReadTemplate(r.Context(), t)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TemplateListResponse{Templates: templates})
+}
+
+// GetTemplate returns the latest version of a template, or ?version=N
+func (h *TemplateHandler) GetTemplate(w http.ResponseWriter, r *http.Request) {
+	version := 0
+	if v := r.URL.Query().Get("version"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid version: must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		version = n
+	}
+
+	template, err := h.templates.GetTemplate(chi.URLParam(r, "templateID"), version)
+	if err != nil || !canReadTemplate(r.Context(), template) {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(template)
+}
+
+// UpdateTemplate stores a new version of a template
+// Jobs already submitted keep pointing at the version they used
+func (h *TemplateHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	templateID := chi.URLParam(r, "templateID")
+	existing, ok := h.loadWritableTemplate(w, r, templateID)
+	if !ok {
+		return
+	}
+
+	template, ok := h.decodeTemplate(w, r)
+	if !ok {
+		return
+	}
+	template.TemplateID = existing.TemplateID
+
+	template, err := h.templates.UpdateTemplate(template)
+	if err != nil {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+	h.logger.Infof("Job template updated: id=%s version=%d", template.TemplateID, template.Version)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(template)
+}
+
+// DeleteTemplate removes a template and its history
+func (h *TemplateHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	templateID := chi.URLParam(r, "templateID")
+	if _, ok := h.loadWritableTemplate(w, r, templateID); !ok {
+		return
+	}
+
+	if err := h.templates.DeleteTemplate(templateID); err != nil {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+	h.logger.Infof("Job template deleted: id=%s", templateID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadWritableTemplate loads a template the caller may modify
+// Project members can use a shared template but only its owner can change it
+func (h *TemplateHandler) loadWritableTemplate(w http.ResponseWriter, r *http.Request, templateID string) (storage.JobTemplate, bool) {
+	template, err := h.templates.GetTemplate(templateID, 0)
+	if err != nil || !canReadTemplate(r.Context(), template) {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return storage.JobTemplate{}, false
+	}
+
+	userID, _ := r.Context().Value(middleware.UserIDContextKey).(string)
+	if template.OwnerID != userID && !middleware.HasPermission(r.Context(), middleware.PermissionTemplatesAny) {
+		http.Error(w, "Forbidden: only the template owner can modify it", http.StatusForbidden)
+		return storage.JobTemplate{}, false
+	}
+	return template, true
+}
+
+// decodeTemplate parses and validates a template request body
+func (h *TemplateHandler) decodeTemplate(w http.ResponseWriter, r *http.Request) (storage.JobTemplate, bool) {
+	var req TemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return storage.JobTemplate{}, false
+	}
+
+	if req.Name == "" {
+		http.Error(w, "Template name is required", http.StatusBadRequest)
+		return storage.JobTemplate{}, false
+	}
+	if req.Project != "" && !middleware.IsProjectMember(r.Context(), req.Project) {
+		http.Error(w, "Forbidden: not a member of project "+req.Project, http.StatusForbidden)
+		return storage.JobTemplate{}, false
+	}
+	if err := validateTemplateJob(req.Job, req.Variables); err != nil {
+		http.Error(w, "Invalid template: "+err.Error(), http.StatusBadRequest)
+		return storage.JobTemplate{}, false
+	}
+
+	return storage.JobTemplate{
+		Name:        req.Name,
+		Description: req.Description,
+		Project:     req.Project,
+		Job:         req.Job,
+		Variables:   req.Variables,
+	}, true
+}
+
+// validateTemplateJob checks the job JSON and that every placeholder is declared
+func validateTemplateJob(job json.RawMessage, variables map[string]storage.TemplateVariable) error {
+	var fields map[string]any
+	if err := json.Unmarshal(job, &fields); err != nil || fields == nil {
+		return fmt.Errorf("job must be a JSON object")
+	}
+	if _, ok := fields["template_id"]; ok {
+		return fmt.Errorf("job can't reference another template")
+	}
+
+	for name := range variables {
+		if !variableNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid variable name %q", name)
+		}
+	}
+
+	var undeclared []string
+	for _, match := range placeholderPattern.FindAllStringSubmatch(string(job), -1) {
+		if _, ok := variables[match[1]]; !ok {
+			undeclared = append(undeclared, match[1])
+		}
+	}
+	if len(undeclared) > 0 {
+		sort.Strings(undeclared)
+		return fmt.Errorf("undeclared variables: %s", strings.Join(undeclared, ", "))
+	}
+	return nil
+}
+
+// canReadTemplate reports whether the caller may see and use a template
+func canReadTemplate(ctx context.Context, template storage.JobTemplate) bool {
+	userID, _ := ctx.Value(middleware.UserIDContextKey).(string)
+	if userID != "" && template.OwnerID == userID {
+		return true
+	}
+	if template.Project != "" && middleware.IsProjectMember(ctx, template.Project) {
+		return true
+	}
+	return middleware.HasPermission(ctx, middleware.PermissionTemplatesAny)
+}
+
+// SetTemplateStore sets the store used to resolve template_id on submissions
+// Optional - without it submissions referencing a template are rejected
+func (h *JobSubmissionHandler) SetTemplateStore(templates *storage.TemplateStore) {
+	h.templates = templates
+}
+
+// applyTemplate expands the template referenced by a job request
+// The template's job is filled in with the request's variables, then every
+// field set on the request overrides it; params objects are merged key by key
+func (h *JobSubmissionHandler) applyTemplate(ctx context.Context, jobReq JobRequest) (JobRequest, error) {
+	if jobReq.TemplateID == "" || jobReq.templateApplied {
+		return jobReq, nil
+	}
+	if h.templates == nil {
+		return jobReq, &requestError{status: http.StatusBadRequest, message: "Job templates are not available"}
+	}
+
+	template, err := h.templates.GetTemplate(jobReq.TemplateID, jobReq.TemplateVersion)
+	if err != nil || !canReadTemplate(ctx, template) {
+		return jobReq, &requestError{status: http.StatusNotFound, message: "Template not found"}
+	}
+
+	values, err := templateValues(template, jobReq.Variables)
+	if err != nil {
+		return jobReq, &requestError{status: http.StatusBadRequest, message: "Invalid template variables: " + err.Error()}
+	}
+
+	var fields any
+	if err := json.Unmarshal(template.Job, &fields); err != nil {
+		return jobReq, fmt.Errorf("decoding template %s: %w", template.TemplateID, err)
+	}
+	expanded, err := json.Marshal(substitutePlaceholders(fields, values))
+	if err != nil {
+		return jobReq, fmt.Errorf("encoding template %s: %w", template.TemplateID, err)
+	}
+
+	var base JobRequest
+	if err := json.Unmarshal(expanded, &base); err != nil {
+		return jobReq, &requestError{status: http.StatusBadRequest, message: "Template does not expand to a valid job: " + err.Error()}
+	}
+
+	// Request fields win over the template
+	if jobReq.Type != "" {
+		base.Type = jobReq.Type
+	}
+	if jobReq.Name != "" {
+		base.Name = jobReq.Name
+	}
+	if jobReq.Description != "" {
+		base.Description = jobReq.Description
+	}
+	if jobReq.Project != "" {
+		base.Project = jobReq.Project
+	}
+	if base.Project == "" {
+		base.Project = template.Project
+	}
+	if jobReq.GPUType != "" {
+		base.GPUType = jobReq.GPUType
+	}
+	if jobReq.GPUCount != 0 {
+		base.GPUCount = jobReq.GPUCount
+	}
+	if jobReq.Priority != 0 {
+		base.Priority = jobReq.Priority
+	}
+	if jobReq.Tags != nil {
+		base.Tags = jobReq.Tags
+	}
+	if jobReq.RetryPolicy != nil {
+		base.RetryPolicy = jobReq.RetryPolicy
+	}
+	if jobReq.DatasetID != "" {
+		base.DatasetID = jobReq.DatasetID
+	}
+	if jobReq.Secrets != nil {
+		base.Secrets = jobReq.Secrets
+	}
+	if jobReq.Preemptible {
+		base.Preemptible = true
+	}
+	if jobReq.MaxRuntime != "" {
+		base.MaxRuntime = jobReq.MaxRuntime
+	}
+	if jobReq.Deadline != nil {
+		base.Deadline = jobReq.Deadline
+	}
+	base.Params = mergeParams(base.Params, jobReq.Params)
+
+	base.TemplateID = template.TemplateID
+	base.TemplateVersion = template.Version
+	base.Variables = jobReq.Variables
+	base.templateApplied = true
+	return base, nil
+}
+
+// templateValues works out the value of every template variable
+func templateValues(template storage.JobTemplate, provided map[string]any) (map[string]any, error) {
+	for name := range provided {
+		if _, ok := template.Variables[name]; !ok {
+			return nil, fmt.Errorf("unknown variable %q", name)
+		}
+	}
+
+	values := make(map[string]any, len(template.Variables))
+	var missing []string
+	for name, variable := range template.Variables {
+		switch value, ok := provided[name]; {
+		case ok:
+			values[name] = value
+		case variable.Default != nil:
+			values[name] = variable.Default
+		case variable.Required:
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing required variables: %s", strings.Join(missing, ", "))
+	}
+	return values, nil
+}
+
+// substitutePlaceholders replaces {{variable}} placeholders throughout a decoded JSON value
+// Placeholders without a value are left as they are
+func substitutePlaceholders(value any, values map[string]any) any {
+	switch v := value.(type) {
+	case string:
+		// A lone placeholder takes the value as is, keeping numbers numbers
+		if match := placeholderPattern.FindStringSubmatch(v); match != nil && match[0] == strings.TrimSpace(v) {
+			if replacement, ok := values[match[1]]; ok {
+				return replacement
+			}
+		}
+		return placeholderPattern.ReplaceAllStringFunc(v, func(placeholder string) string {
+			name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+			if replacement, ok := values[name]; ok {
+				return fmt.Sprint(replacement)
+			}
+			return placeholder
+		})
+	case map[string]any:
+		for key, item := range v {
+			v[key] = substitutePlaceholders(item, values)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = substitutePlaceholders(item, values)
+		}
+		return v
+	}
+	return value
+}
+
+// mergeParams overlays override params on top of the base params
+// Objects are merged one level deep, anything else replaces the base
+func mergeParams(base, override any) any {
+	if override == nil {
+		return base
+	}
+	baseMap, ok := base.(map[string]any)
+	overrideMap, ok2 := override.(map[string]any)
+	if !ok || !ok2 {
+		return override
+	}
+
+	merged := make(map[string]any, len(baseMap)+len(overrideMap))
+	for k, v := range baseMap {
+		merged[k] = v
+	}
+	for k, v := range overrideMap {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"siger-api-gateway/internal/middleware"
+	"siger-api-gateway/internal/quota"
+	"siger-api-gateway/internal/storage"
+)
+
+// JobUpdateRequest is the body of PATCH /jobs/{jobID}
+// Omitted fields are left alone; an empty tags list clears the tags
+type JobUpdateRequest struct {
+	Priority *int     `json:"priority,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	GPUType  GPUType  `json:"gpu_type,omitempty"`
+}
+
+// JobUpdateMessage is published on jobs.update when a queued job changes
+// Schedulers holding the job's original message apply it before dispatching
+type JobUpdateMessage struct {
+	JobID     string    `json:"job_id"`
+	Priority  int       `json:"priority"`
+	Tags      []string  `json:"tags,omitempty"`
+	GPUType   GPUType   `json:"gpu_type"`
+	Revision  int       `json:"revision"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// UpdateJob changes the priority, tags or GPU type of a queued job
+// Saves users from cancelling and resubmitting, which loses their place in line
+// Send the ETag from GET /jobs/{jobID} as If-Match to make sure nothing changed
+// in between; the job must be queued either way, so a worker starting the job
+// at the same moment wins and the update gets a 409
+func (h *JobSubmissionHandler) UpdateJob(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "jobID")
+	if jobID == "" {
+		http.Error(w, "Job ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var updateReq JobUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if updateReq.Priority == nil && updateReq.Tags == nil && updateReq.GPUType == "" {
+		http.Error(w, "Nothing to update: set priority, tags or gpu_type", http.StatusBadRequest)
+		return
+	}
+
+	revision, ok := parseIfMatch(r.Header.Get("If-Match"))
+	if !ok {
+		http.Error(w, "Invalid If-Match header: must be a job ETag", http.StatusBadRequest)
+		return
+	}
+
+	jobInfo, err := h.jobStore.GetJob(jobID)
+	if err != nil || !canAccessJob(r.Context(), jobInfo) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	role, _ := r.Context().Value(middleware.UserRoleContextKey).(string)
+	updated, err := h.jobStore.UpdateQueuedJob(jobID, revision, func(job *storage.JobInfo) error {
+		old := *job
+		if err := applyJobUpdate(job, updateReq); err != nil {
+			return err
+		}
+		if job.GPUType == old.GPUType {
+			return nil
+		}
+		// Warnings can't be returned from a PATCH, only rejections apply
+		if _, err := h.checkCapacity(GPUType(job.GPUType), job.GPUCount); err != nil {
+			return err
+		}
+		if h.quotas != nil {
+			return h.quotas.AdmitChange(old, *job, role)
+		}
+		return nil
+	})
+	switch {
+	case err == nil:
+	case errors.Is(err, storage.ErrRevisionMismatch):
+		w.Header().Set("ETag", jobETag(updated))
+		http.Error(w, "Job was modified since it was read", http.StatusPreconditionFailed)
+		return
+	case errors.Is(err, storage.ErrJobNotQueued):
+		http.Error(w, "Job already "+string(updated.Status)+", only queued jobs can be modified", http.StatusConflict)
+		return
+	case errors.Is(err, storage.ErrJobNotFound):
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	default:
+		var reqErr *requestError
+		if errors.As(err, &reqErr) {
+			writeRequestError(w, err)
+			return
+		}
+		var quotaErr *quota.Error
+		if errors.As(err, &quotaErr) {
+			h.writeQuotaError(w, err, jobInfo, 1)
+			return
+		}
+		h.logger.Errorw("Failed to update job", "jobID", jobID, "error", err)
+		http.Error(w, "Failed to update job: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Infof("Job updated: id=%s priority=%d gpu_type=%s revision=%d",
+		jobID, updated.Priority, updated.GPUType, updated.Revision)
+	h.publishJobUpdate(updated)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", jobETag(updated))
+	json.NewEncoder(w).Encode(newJobDetail(updated, time.Now().UTC()))
+}
+
+// applyJobUpdate sets the requested fields on the job and its stored message
+// The message is what retries and resubmits publish, so it has to follow along
+func applyJobUpdate(job *storage.JobInfo, updateReq JobUpdateRequest) error {
+	var jobMsg JobMessage
+	if err := json.Unmarshal(job.Payload, &jobMsg); err != nil {
+		return err
+	}
+
+	if updateReq.Priority != nil {
+		if err := validatePriority(*updateReq.Priority); err != nil {
+			return &requestError{status: http.StatusBadRequest, message: err.Error()}
+		}
+		job.Priority = *updateReq.Priority
+		jobMsg.Priority = *updateReq.Priority
+	}
+	if updateReq.Tags != nil {
+		job.Tags = updateReq.Tags
+		jobMsg.Tags = updateReq.Tags
+	}
+	if updateReq.GPUType != "" {
+		job.GPUType = string(updateReq.GPUType)
+		jobMsg.GPUType = updateReq.GPUType
+	}
+
+	payload, err := json.Marshal(jobMsg)
+	if err != nil {
+		return err
+	}
+	job.Payload = payload
+	return nil
+}
+
+// publishJobUpdate tells schedulers and workers about a modified job
+// The change is already stored, so a failed publish is logged rather than
+// failing the request; the new values also go out with any retry
+func (h *JobSubmissionHandler) publishJobUpdate(job storage.JobInfo) {
+	if h.natsClient == nil {
+		h.logger.Warnf("NATS client not available, job updated but notification not published: id=%s", job.JobID)
+		return
+	}
+
+	updateMsg := JobUpdateMessage{
+		JobID:     job.JobID,
+		Priority:  job.Priority,
+		Tags:      job.Tags,
+		GPUType:   GPUType(job.GPUType),
+		Revision:  job.Revision,
+		Timestamp: time.Now().UTC(),
+	}
+	if err := h.natsClient.Publish("jobs.update", updateMsg); err != nil {
+		h.logger.Errorf("Failed to publish job update message: id=%s error=%v", job.JobID, err)
+	}
+}
+
+// jobETag returns the ETag for a job, derived from its revision
+func jobETag(job storage.JobInfo) string {
+	return strconv.Quote(strconv.Itoa(job.Revision))
+}
+
+// parseIfMatch reads the revision from an If-Match header, 0 if there is none
+func parseIfMatch(header string) (int, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, true
+	}
+
+	revision, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+	if err != nil || revision < 1 {
+		return 0, false
+	}
+	return revision, true
+}
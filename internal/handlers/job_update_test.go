@@ -0,0 +1,31 @@
+package handlers
+
+import "testing"
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		header   string
+		revision int
+		ok       bool
+	}{
+		{header: "", revision: 0, ok: true},
+		{header: "*", revision: 0, ok: true},
+		{header: `"3"`, revision: 3, ok: true},
+		{header: ` "3" `, revision: 3, ok: true},
+		{header: `W/"3"`, revision: 3, ok: true},
+		{header: "3", revision: 3, ok: true},
+		{header: `"0"`, ok: false},
+		{header: `"-1"`, ok: false},
+		{header: `"abc"`, ok: false},
+		{header: `"1", "2"`, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			revision, ok := parseIfMatch(tt.header)
+			if revision != tt.revision || ok != tt.ok {
+				t.Errorf("parseIfMatch(%q) = %d, %v, want %d, %v", tt.header, revision, ok, tt.revision, tt.ok)
+			}
+		})
+	}
+}
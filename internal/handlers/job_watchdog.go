@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"siger-api-gateway/internal/storage"
+)
+
+// DefaultWatchdogInterval is how often the watchdog checks job limits
+// Limits are meant in hours, so being up to 30s late doesn't matter
+const DefaultWatchdogInterval = 30 * time.Second
+
+// timeoutCancelGrace is how long a worker has to stop a job cancelled for
+// timeout. After it the job is cancelled without the worker, so a hung one
+// can't keep it going
+const timeoutCancelGrace = 5 * time.Minute
+
+// RunWatchdog enforces job deadlines and maximum runtimes until ctx is done
+// Workers get max_runtime and deadline too, but a hung worker won't stop
+// itself, so the gateway doesn't rely on them
+func (h *JobSubmissionHandler) RunWatchdog(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultWatchdogInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.checkJobLimits(time.Now().UTC())
+		}
+	}
+}
+
+// checkJobLimits expires waiting jobs past their deadline and cancels
+// running jobs past their deadline or maximum runtime. Held jobs are queued
+// and expire like any other, paused and preempted ones are cancelled
+func (h *JobSubmissionHandler) checkJobLimits(now time.Time) {
+	for _, job := range h.jobStore.JobsByStatus(storage.JobStatusQueued, storage.JobStatusScheduled) {
+		if job.Deadline.IsZero() || now.Before(job.Deadline) {
+			continue
+		}
+		h.expireJob(job)
+	}
+
+	for _, job := range h.jobStore.JobsByStatus(storage.JobStatusPaused, storage.JobStatusPreempted) {
+		if job.Deadline.IsZero() || now.Before(job.Deadline) {
+			continue
+		}
+		message := "Deadline " + job.Deadline.Format(time.RFC3339) + " passed while the job was " + string(job.Status)
+		if _, err := h.cancelJob(job.JobID, message, storage.FailureReasonTimeout); err != nil {
+			if err != storage.ErrJobFinished {
+				h.logger.Warnf("Failed to cancel job over its deadline: id=%s error=%v", job.JobID, err)
+			}
+			continue
+		}
+		h.logger.Infof("Job cancelled by watchdog: id=%s reason=%q", job.JobID, message)
+	}
+
+	for _, job := range h.jobStore.JobsByStatus(storage.JobStatusCancelling) {
+		h.enforceCancel(job, now)
+	}
+
+	for _, job := range h.jobStore.JobsByStatus(storage.JobStatusProcessing) {
+		message := runLimitExceeded(job, now)
+		if message == "" {
+			continue
+		}
+		if _, err := h.cancelJob(job.JobID, message, storage.FailureReasonTimeout); err != nil {
+			if err != storage.ErrJobFinished {
+				h.logger.Warnf("Failed to cancel job over its limit: id=%s error=%v", job.JobID, err)
+			}
+			continue
+		}
+		h.logger.Infof("Job cancelled by watchdog: id=%s reason=%q", job.JobID, message)
+	}
+}
+
+// enforceCancel resends the cancel of a job its worker hasn't stopped yet
+// The first one may have been lost with NATS down. Jobs cancelled for timeout
+// are cancelled without the worker after the grace period
+func (h *JobSubmissionHandler) enforceCancel(job storage.JobInfo, now time.Time) {
+	requestedAt, reason := cancelRequestedAt(job)
+	if reason != storage.FailureReasonTimeout || now.Sub(requestedAt) < timeoutCancelGrace {
+		h.publishCancel(job, reason)
+		return
+	}
+
+	message := fmt.Sprintf("Worker did not stop the job within %s of the timeout cancel", timeoutCancelGrace)
+	if _, err := h.jobStore.TransitionJob(job.JobID, storage.JobStatusCancelled, message, storage.FailureReasonTimeout); err != nil {
+		// Most likely the worker confirmed since the scan
+		h.logger.Debugf("Job not force cancelled: id=%s error=%v", job.JobID, err)
+		return
+	}
+	h.logger.Warnf("Job force cancelled by watchdog: id=%s requested=%s", job.JobID, requestedAt.Format(time.RFC3339))
+}
+
+// cancelRequestedAt returns when and why a cancelling job was cancelled
+func cancelRequestedAt(job storage.JobInfo) (time.Time, string) {
+	for i := len(job.History) - 1; i >= 0; i-- {
+		if job.History[i].To == storage.JobStatusCancelling {
+			return job.History[i].Timestamp, job.History[i].Reason
+		}
+	}
+	return time.Time{}, ""
+}
+
+// expireJob marks a job that never started as expired
+// Scheduled jobs may already sit on a worker and published queued ones in
+// the stream, so workers are told to drop them. Held jobs were never published
+func (h *JobSubmissionHandler) expireJob(job storage.JobInfo) {
+	message := "Deadline " + job.Deadline.Format(time.RFC3339) + " passed before the job started"
+	if _, err := h.jobStore.TransitionJob(job.JobID, storage.JobStatusExpired, message, storage.FailureReasonDeadline); err != nil {
+		// Most likely started or finished since the scan
+		h.logger.Debugf("Job not expired: id=%s error=%v", job.JobID, err)
+		return
+	}
+
+	h.logger.Infof("Job expired: id=%s deadline=%s", job.JobID, job.Deadline.Format(time.RFC3339))
+
+	published := job.Status == storage.JobStatusQueued && !job.Held
+	if job.Status == storage.JobStatusScheduled || published {
+		h.publishCancel(job, storage.FailureReasonDeadline)
+	}
+}
+
+// runLimitExceeded describes which limit a running job is over, or returns ""
+func runLimitExceeded(job storage.JobInfo, now time.Time) string {
+	if !job.Deadline.IsZero() && !now.Before(job.Deadline) {
+		return "Deadline " + job.Deadline.Format(time.RFC3339) + " exceeded"
+	}
+	if job.MaxRuntime == "" || job.StartedAt.IsZero() {
+		return ""
+	}
+
+	maxRuntime, err := time.ParseDuration(job.MaxRuntime)
+	if err != nil || maxRuntime <= 0 {
+		return "" // Validated at submission, can't happen
+	}
+	if now.Sub(job.StartedAt) < maxRuntime {
+		return ""
+	}
+	return fmt.Sprintf("Maximum runtime of %s exceeded", maxRuntime)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/middleware"
+	"siger-api-gateway/internal/quota"
+)
+
+// QuotaHandler exposes the caller's quota limits and current usage
+// Lets users see why a submission was rejected before they retry
+type QuotaHandler struct {
+	quotas *quota.Manager
+	logger internal.LoggerInterface
+}
+
+// QuotaResponse is the response for GET /quota
+type QuotaResponse struct {
+	Enabled  bool                    `json:"enabled"`
+	User     quota.Report            `json:"user"`
+	Projects map[string]quota.Report `json:"projects,omitempty"`
+}
+
+// NewQuotaHandler creates a new quota handler
+func NewQuotaHandler(quotas *quota.Manager) *QuotaHandler {
+	return &QuotaHandler{
+		quotas: quotas,
+		logger: internal.Logger,
+	}
+}
+
+// RegisterRoutes registers the quota routes
+func (h *QuotaHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/quota", h.GetQuota)
+}
+
+// GetQuota returns the limits and usage for the caller and their projects
+func (h *QuotaHandler) GetQuota(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok || userID == "" {
+		http.Error(w, "User ID not found in request context", http.StatusUnauthorized)
+		return
+	}
+	role, _ := r.Context().Value(middleware.UserRoleContextKey).(string)
+	projects, _ := r.Context().Value(middleware.UserProjectsContextKey).([]string)
+
+	resp := QuotaResponse{
+		Enabled: h.quotas.Enabled(),
+		User:    h.quotas.UserReport(userID, role),
+	}
+	if len(projects) > 0 {
+		resp.Projects = make(map[string]quota.Report, len(projects))
+		for _, project := range projects {
+			resp.Projects[project] = h.quotas.ProjectReport(project)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/middleware"
+	"siger-api-gateway/internal/secrets"
+	"siger-api-gateway/internal/storage"
+)
+
+// secretsTokenHeader carries the one-time token workers redeem for a job's secrets
+const secretsTokenHeader = "X-Secrets-Token"
+
+// SecretRequest is the body of PUT /secrets/{name}
+type SecretRequest struct {
+	Value string `json:"value"`
+}
+
+// SecretListResponse is the response for GET /secrets
+type SecretListResponse struct {
+	Secrets []secrets.Secret `json:"secrets"`
+}
+
+// JobSecretsResponse is what a worker gets for a redeemed secrets token
+type JobSecretsResponse struct {
+	JobID   string            `json:"job_id"`
+	Attempt int               `json:"attempt"`
+	Secrets map[string]string `json:"secrets"`
+}
+
+// SecretHandler manages user secrets and hands them to workers
+// Values can be written and deleted but never read back through the API,
+// the only way out is a job's one-time secrets token
+type SecretHandler struct {
+	secrets  *secrets.Store
+	jobStore *storage.JobStore
+	logger   internal.LoggerInterface
+}
+
+// NewSecretHandler creates a new secret handler
+func NewSecretHandler(secretStore *secrets.Store, jobStore *storage.JobStore) *SecretHandler {
+	return &SecretHandler{
+		secrets:  secretStore,
+		jobStore: jobStore,
+		logger:   internal.Logger,
+	}
+}
+
+// RegisterRoutes registers the self-service secret routes
+func (h *SecretHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/secrets", h.ListSecrets)
+	r.Put("/secrets/{name}", h.PutSecret)
+	r.Delete("/secrets/{name}", h.DeleteSecret)
+}
+
+// RegisterWorkerRoutes registers the route workers fetch job secrets from
+// Must be registered outside the JWT middleware group, the token is the credential
+func (h *SecretHandler) RegisterWorkerRoutes(r chi.Router) {
+	r.Post("/jobs/{jobID}/secrets", h.FetchJobSecrets)
+}
+
+// ListSecrets returns the names of the caller's secrets
+func (h *SecretHandler) ListSecrets(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok || userID == "" {
+		http.Error(w, "User ID not found in request context", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SecretListResponse{Secrets: h.secrets.List(userID)})
+}
+
+// PutSecret creates or replaces one of the caller's secrets
+func (h *SecretHandler) PutSecret(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok || userID == "" {
+		http.Error(w, "User ID not found in request context", http.StatusUnauthorized)
+		return
+	}
+
+	var req SecretRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*secrets.MaxValueSize)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Value == "" {
+		http.Error(w, "Secret value is required", http.StatusBadRequest)
+		return
+	}
+
+	secret, created, err := h.secrets.Put(userID, chi.URLParam(r, "name"), req.Value)
+	if err != nil {
+		if errors.Is(err, secrets.ErrInvalidName) || errors.Is(err, secrets.ErrValueTooLong) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.logger.Errorf("Failed to store secret: user=%s name=%s error=%v", userID, secret.Name, err)
+		http.Error(w, "Failed to store secret", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Infof("Secret stored: user=%s name=%s created=%t", userID, secret.Name, created)
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(secret)
+}
+
+// DeleteSecret removes one of the caller's secrets
+func (h *SecretHandler) DeleteSecret(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok || userID == "" {
+		http.Error(w, "User ID not found in request context", http.StatusUnauthorized)
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	if err := h.secrets.Delete(userID, name); err != nil {
+		http.Error(w, "Secret not found", http.StatusNotFound)
+		return
+	}
+	h.logger.Infof("Secret deleted: user=%s name=%s", userID, name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// FetchJobSecrets redeems a job's one-time secrets token for the secret values
+// The token only works for the attempt it was issued to, while that attempt runs
+func (h *SecretHandler) FetchJobSecrets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	jobID := chi.URLParam(r, "jobID")
+	token := strings.TrimSpace(r.Header.Get(secretsTokenHeader))
+	if token == "" {
+		http.Error(w, "Missing "+secretsTokenHeader+" header", http.StatusUnauthorized)
+		return
+	}
+
+	active := func(grant secrets.Grant) bool {
+		job, err := h.jobStore.GetJob(jobID)
+		return err == nil && job.Attempt == grant.Attempt && !job.Status.EndsAttempt()
+	}
+	grant, values, err := h.secrets.Redeem(jobID, token, active)
+	if err != nil {
+		if errors.Is(err, secrets.ErrInvalidToken) {
+			h.logger.Warnf("Rejected job secrets token: job=%s remote=%s", jobID, r.RemoteAddr)
+			http.Error(w, "Invalid or already used secrets token", http.StatusUnauthorized)
+			return
+		}
+		if errors.Is(err, secrets.ErrAttemptInactive) {
+			http.Error(w, "Job attempt is no longer active", http.StatusGone)
+			return
+		}
+		if errors.Is(err, secrets.ErrNotFound) {
+			http.Error(w, "A secret of this job no longer exists: "+err.Error(), http.StatusGone)
+			return
+		}
+		h.logger.Errorf("Failed to reveal job secrets: job=%s error=%v", jobID, err)
+		http.Error(w, "Failed to read job secrets", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Infof("Job secrets fetched: job=%s attempt=%d names=%s", jobID, grant.Attempt, strings.Join(grant.Names, ","))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(JobSecretsResponse{
+		JobID:   jobID,
+		Attempt: grant.Attempt,
+		Secrets: values,
+	})
+}
+
+// SetSecretStore sets the store jobs reference secrets from
+// Optional - without it submissions referencing secrets are rejected
+func (h *JobSubmissionHandler) SetSecretStore(secretStore *secrets.Store) {
+	h.secrets = secretStore
+}
+
+// checkSecrets verifies the caller has every secret a job references
+func (h *JobSubmissionHandler) checkSecrets(userID string, names []string) error {
+	if h.secrets == nil {
+		return &requestError{status: http.StatusBadRequest, message: "Secrets are not available"}
+	}
+
+	var missing *secrets.MissingError
+	if err := h.secrets.Check(userID, names); errors.As(err, &missing) {
+		return &requestError{status: http.StatusBadRequest, message: "Unknown secret: " + missing.Name}
+	}
+	return nil
+}
+
+// attachSecretsToken gives a job message a fresh token for its secrets
+// Called on every publish, each attempt redeems its own token
+func (h *JobSubmissionHandler) attachSecretsToken(jobMsg *JobMessage) error {
+	if len(jobMsg.Secrets) == 0 || h.secrets == nil {
+		return nil
+	}
+
+	token, err := h.secrets.IssueToken(jobMsg.JobID, jobMsg.Attempt, jobMsg.UserID, jobMsg.Secrets)
+	if err != nil {
+		return err
+	}
+	jobMsg.SecretsToken = token
+	return nil
+}
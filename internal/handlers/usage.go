@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/metering"
+	"siger-api-gateway/internal/middleware"
+)
+
+// Usage report formats
+const (
+	UsageFormatJSON = "json"
+	UsageFormatCSV  = "csv"
+)
+
+// UsageHandler serves GPU usage and cost reports
+// Users see their own usage, admins get the whole cluster for chargeback
+type UsageHandler struct {
+	meter  *metering.Meter
+	logger internal.LoggerInterface
+}
+
+// NewUsageHandler creates a new usage handler
+func NewUsageHandler(meter *metering.Meter) *UsageHandler {
+	return &UsageHandler{
+		meter:  meter,
+		logger: internal.Logger,
+	}
+}
+
+// RegisterRoutes registers the self-service usage routes
+func (h *UsageHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/usage", h.GetUsage)
+}
+
+// RegisterAdminRoutes registers the cluster-wide usage routes
+// Mount behind RequireRole("admin")
+func (h *UsageHandler) RegisterAdminRoutes(r chi.Router) {
+	r.Get("/usage", h.GetAllUsage)
+}
+
+// GetUsage returns the caller's usage, grouped by GPU type and day by default
+func (h *UsageHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok || userID == "" {
+		http.Error(w, "User ID not found in request context", http.StatusUnauthorized)
+		return
+	}
+
+	h.serveUsage(w, r, userID, []string{metering.GroupByGPUType, metering.GroupByDay})
+}
+
+// GetAllUsage returns usage across all users, grouped by user, GPU type and day by default
+// Accepts user_id to narrow the report down to a single user
+func (h *UsageHandler) GetAllUsage(w http.ResponseWriter, r *http.Request) {
+	h.serveUsage(w, r, r.URL.Query().Get("user_id"),
+		[]string{metering.GroupByUser, metering.GroupByGPUType, metering.GroupByDay})
+}
+
+// serveUsage summarizes usage for a user (or everyone) and writes it as JSON or CSV
+func (h *UsageHandler) serveUsage(w http.ResponseWriter, r *http.Request, userID string, defaultGroupBy []string) {
+	from, to, err := parseUsageRange(r, time.Now().UTC())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	groupBy := defaultGroupBy
+	if v := r.URL.Query().Get("group_by"); v != "" {
+		groupBy = nil
+		for _, dimension := range strings.Split(v, ",") {
+			if dimension = strings.TrimSpace(dimension); dimension != "" {
+				groupBy = append(groupBy, dimension)
+			}
+		}
+	}
+	if err := metering.ValidateGroupBy(groupBy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = UsageFormatJSON
+	}
+	if format != UsageFormatJSON && format != UsageFormatCSV {
+		http.Error(w, "Invalid format: must be json or csv", http.StatusBadRequest)
+		return
+	}
+
+	records := h.meter.Records(userID, from, to)
+	summary := metering.Summarize(records, groupBy, from, to, h.meter.Currency())
+
+	if format == UsageFormatCSV {
+		filename := fmt.Sprintf("usage-%s-%s.csv", from.Format("20060102"), to.Format("20060102"))
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		if err := metering.WriteCSV(w, summary); err != nil {
+			h.logger.Errorf("Failed to write usage CSV: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// parseUsageRange reads the from/to query parameters (RFC3339 or YYYY-MM-DD)
+// Defaults to the current calendar month so far, which is what billing asks for
+func parseUsageRange(r *http.Request, now time.Time) (time.Time, time.Time, error) {
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := now
+
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := parseUsageTime(v)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid from: must be RFC3339 or YYYY-MM-DD")
+		}
+		from = t
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := parseUsageTime(v)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid to: must be RFC3339 or YYYY-MM-DD")
+		}
+		to = t
+	}
+
+	if !to.After(from) {
+		return from, to, fmt.Errorf("invalid range: to must be after from")
+	}
+	return from, to, nil
+}
+
+// parseUsageTime parses an RFC3339 timestamp or a UTC date
+func parseUsageTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse("2006-01-02", v)
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/middleware"
+	"siger-api-gateway/internal/storage"
+	"siger-api-gateway/internal/webhooks"
+)
+
+// defaultWebhookStatuses are used when a webhook doesn't list any
+// Most people only want to hear about jobs that are done
+var defaultWebhookStatuses = []storage.JobStatus{
+	storage.JobStatusCompleted,
+	storage.JobStatusFailed,
+	storage.JobStatusCancelled,
+	storage.JobStatusExpired,
+}
+
+// WebhookRequest is the body of POST /webhooks
+type WebhookRequest struct {
+	URL      string   `json:"url"`
+	JobID    string   `json:"job_id,omitempty"`   // Only this job, otherwise all of the caller's jobs
+	Statuses []string `json:"statuses,omitempty"` // Defaults to the terminal statuses
+	Secret   string   `json:"secret,omitempty"`   // HMAC key, generated if empty
+}
+
+// WebhookCreatedResponse is the only response that includes the signing secret
+type WebhookCreatedResponse struct {
+	webhooks.Webhook
+	Secret string `json:"secret"`
+}
+
+// WebhookListResponse is the response for GET /webhooks
+type WebhookListResponse struct {
+	Webhooks []webhooks.Webhook `json:"webhooks"`
+}
+
+// DeliveryListResponse is the response for the delivery log and dead-letter list
+type DeliveryListResponse struct {
+	Deliveries []webhooks.Delivery `json:"deliveries"`
+}
+
+// WebhookHandler manages webhooks called on job status changes
+// Notifications are fed from the job store, so changes the gateway makes
+// itself (expiry, cancels, retries) are covered as well as worker updates
+type WebhookHandler struct {
+	store      *webhooks.Store
+	dispatcher *webhooks.Dispatcher
+	jobStore   *storage.JobStore
+	logger     internal.LoggerInterface
+}
+
+// NewWebhookHandler creates a new webhook handler
+// Registers with the job store so every status change triggers webhooks
+func NewWebhookHandler(store *webhooks.Store, dispatcher *webhooks.Dispatcher, jobStore *storage.JobStore) *WebhookHandler {
+	h := &WebhookHandler{
+		store:      store,
+		dispatcher: dispatcher,
+		jobStore:   jobStore,
+		logger:     internal.Logger,
+	}
+
+	jobStore.OnChange(h.observe)
+
+	return h
+}
+
+// RegisterRoutes registers the webhook routes
+func (h *WebhookHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/webhooks", h.CreateWebhook)
+	r.Get("/webhooks", h.ListWebhooks)
+	r.Get("/webhooks/{webhookID}", h.GetWebhook)
+	r.Delete("/webhooks/{webhookID}", h.DeleteWebhook)
+	r.Post("/webhooks/{webhookID}/ping", h.PingWebhook)
+	r.Get("/webhooks/{webhookID}/deliveries", h.ListDeliveries)
+	r.Get("/webhooks/{webhookID}/dead-letters", h.ListDeadLetters)
+	r.Post("/webhooks/{webhookID}/dead-letters/{deliveryID}/redeliver", h.Redeliver)
+}
+
+// observe passes job status changes on to the dispatcher
+// Runs under the job store lock; Notify only queues deliveries
+func (h *WebhookHandler) observe(old *storage.JobInfo, job *storage.JobInfo) {
+	if job == nil || (old != nil && old.Status == job.Status && old.Attempt == job.Attempt) {
+		return
+	}
+
+	// The reason is on the history entry the change just added
+	reason := ""
+	if n := len(job.History); n > 0 && job.History[n-1].To == job.Status {
+		reason = job.History[n-1].Reason
+	}
+	h.dispatcher.Notify(*job, reason)
+}
+
+// CreateWebhook registers a webhook for the caller's jobs or a single job
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok || userID == "" {
+		http.Error(w, "User ID not found in request context", http.StatusUnauthorized)
+		return
+	}
+
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		http.Error(w, "Webhook URL must be an absolute http or https URL", http.StatusBadRequest)
+		return
+	}
+	if err := h.dispatcher.CheckTarget(target); err != nil {
+		http.Error(w, "Invalid webhook URL: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	statuses := defaultWebhookStatuses
+	if len(req.Statuses) > 0 {
+		statuses = make([]storage.JobStatus, 0, len(req.Statuses))
+		for _, s := range req.Statuses {
+			status, ok := storage.ParseJobStatus(s)
+			if !ok {
+				http.Error(w, "Invalid status: "+s, http.StatusBadRequest)
+				return
+			}
+			statuses = append(statuses, status)
+		}
+	}
+
+	if req.JobID != "" {
+		job, err := h.jobStore.GetJob(req.JobID)
+		if err != nil || !canAccessJob(r.Context(), job) {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			http.Error(w, "Failed to create webhook: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		secret = hex.EncodeToString(raw)
+	}
+
+	webhook, err := h.store.CreateWebhook(webhooks.Webhook{
+		WebhookID: uuid.New().String(),
+		OwnerID:   userID,
+		URL:       target.String(),
+		JobID:     req.JobID,
+		Statuses:  statuses,
+		Secret:    secret,
+	})
+	if err != nil {
+		if errors.Is(err, webhooks.ErrTooManyWebhooks) {
+			http.Error(w, "Webhook limit reached, delete unused webhooks first", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to create webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.logger.Infof("Webhook created: id=%s owner=%s job=%s host=%s", webhook.WebhookID, userID, webhook.JobID, target.Host)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(WebhookCreatedResponse{Webhook: webhook, Secret: secret})
+}
+
+// ListWebhooks returns the caller's webhooks
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok || userID == "" {
+		http.Error(w, "User ID not found in request context", http.StatusUnauthorized)
+		return
+	}
+
+	list := h.store.ListWebhooks(userID)
+	if list == nil {
+		list = []webhooks.Webhook{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WebhookListResponse{Webhooks: list})
+}
+
+// GetWebhook returns one of the caller's webhooks
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := h.loadOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// DeleteWebhook removes a webhook along with its delivery log
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := h.loadOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteWebhook(webhook.WebhookID); err != nil {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	h.logger.Infof("Webhook deleted: id=%s", webhook.WebhookID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PingWebhook sends a test event, its outcome shows up in the delivery log
+func (h *WebhookHandler) PingWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := h.loadOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	delivery, err := h.dispatcher.Ping(webhook)
+	if err != nil {
+		http.Error(w, "Failed to queue test event: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// ListDeliveries returns a webhook's recent deliveries, newest first
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := h.loadOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DeliveryListResponse{Deliveries: h.store.Deliveries(webhook.WebhookID)})
+}
+
+// ListDeadLetters returns the deliveries that ran out of attempts, newest first
+func (h *WebhookHandler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := h.loadOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DeliveryListResponse{Deliveries: h.store.DeadLetters(webhook.WebhookID)})
+}
+
+// Redeliver sends a dead letter again, e.g. once the receiver is fixed
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := h.loadOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	delivery, err := h.dispatcher.Redeliver(webhook.WebhookID, chi.URLParam(r, "deliveryID"))
+	if err != nil {
+		if errors.Is(err, webhooks.ErrDeliveryNotFound) {
+			http.Error(w, "Dead letter not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to redeliver: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.logger.Infof("Webhook dead letter redelivered: webhook=%s delivery=%s", webhook.WebhookID, delivery.DeliveryID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// loadOwnedWebhook loads a webhook belonging to the caller
+// Other users' webhooks get the same 404 as missing ones
+func (h *WebhookHandler) loadOwnedWebhook(w http.ResponseWriter, r *http.Request) (webhooks.Webhook, bool) {
+	userID, _ := r.Context().Value(middleware.UserIDContextKey).(string)
+	webhook, err := h.store.GetWebhook(chi.URLParam(r, "webhookID"))
+	if err != nil || userID == "" || webhook.OwnerID != userID {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return webhooks.Webhook{}, false
+	}
+	return webhook, true
+}
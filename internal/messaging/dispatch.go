@@ -0,0 +1,44 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// DispatchSubjectPrefix is where the gateway's dispatcher publishes jobs
+// Workers of a type share one durable pull consumer on dispatch.<type>
+const DispatchSubjectPrefix = "dispatch."
+
+// dispatchStreamName is the work-queue stream next to the job stream
+func (c *NATSClient) dispatchStreamName() string {
+	return c.config.Stream + "-dispatch"
+}
+
+// EnsureDispatchStream ensures the dispatch stream exists
+// Work-queue retention removes a job once a worker acks it, so every job is
+// handed out once and in the order the dispatcher released it
+func (c *NATSClient) EnsureDispatchStream() error {
+	if !c.initialized {
+		return errors.New("NATS client not initialized")
+	}
+
+	maxAge, err := time.ParseDuration(c.config.MaxAge)
+	if err != nil {
+		return fmt.Errorf("invalid max age duration: %w", err)
+	}
+
+	_, err = c.js.CreateOrUpdateStream(context.Background(), jetstream.StreamConfig{
+		Name:        c.dispatchStreamName(),
+		Description: "Work queue for jobs released by the gateway dispatcher",
+		Subjects:    []string{DispatchSubjectPrefix + "*"},
+		Retention:   jetstream.WorkQueuePolicy,
+		MaxAge:      maxAge,
+		Replicas:    c.config.Replicas,
+		Storage:     jetstream.FileStorage,
+	})
+	return err
+}
@@ -0,0 +1,107 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// JobLogSubjectPrefix is the subject prefix workers publish job logs to
+// Workers publish each log line (or small batch) to jobs.logs.<jobID>
+// Kept out of the main jobs stream so chatty logs don't crowd out job messages
+const JobLogSubjectPrefix = "jobs.logs."
+
+// JobLogMessage is a log line published by a worker for a running job
+// Progress is optional so workers can piggyback progress on log output
+type JobLogMessage struct {
+	JobID     string    `json:"job_id"`
+	Stream    string    `json:"stream,omitempty"` // stdout or stderr
+	Line      string    `json:"line"`
+	Progress  float64   `json:"progress,omitempty"` // 0-100 percent
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// JobLogSubject returns the subject a job's logs are published on
+func JobLogSubject(jobID string) string {
+	return JobLogSubjectPrefix + jobID
+}
+
+// logStreamName returns the JetStream stream holding job logs
+func (c *NATSClient) logStreamName() string {
+	return c.config.Stream + "-logs"
+}
+
+// EnsureLogStream ensures the job log stream exists
+// Same retention as the job stream - logs are only useful while the job is recent
+func (c *NATSClient) EnsureLogStream() error {
+	if !c.initialized {
+		return errors.New("NATS client not initialized")
+	}
+
+	maxAge, err := time.ParseDuration(c.config.MaxAge)
+	if err != nil {
+		return fmt.Errorf("invalid max age duration: %w", err)
+	}
+
+	_, err = c.js.CreateOrUpdateStream(context.Background(), jetstream.StreamConfig{
+		Name:        c.logStreamName(),
+		Description: "Stream for job logs",
+		Subjects:    []string{JobLogSubjectPrefix + ">"},
+		MaxAge:      maxAge,
+		Replicas:    c.config.Replicas,
+		Storage:     jetstream.FileStorage,
+	})
+	return err
+}
+
+// SubscribeToJobLogs tails the logs of a job from the log stream
+// A startSeq of 0 replays every retained line, otherwise delivery starts at that
+// stream sequence so clients can resume where they left off. The returned stop
+// func must be called when the caller is done
+func (c *NATSClient) SubscribeToJobLogs(ctx context.Context, jobID string, startSeq uint64,
+	handler func(msg JobLogMessage, seq uint64)) (func(), error) {
+	if !c.initialized {
+		return nil, errors.New("NATS client not initialized")
+	}
+
+	cfg := jetstream.OrderedConsumerConfig{
+		FilterSubjects: []string{JobLogSubject(jobID)},
+		DeliverPolicy:  jetstream.DeliverAllPolicy,
+	}
+	if startSeq > 0 {
+		cfg.DeliverPolicy = jetstream.DeliverByStartSequencePolicy
+		cfg.OptStartSeq = startSeq
+	}
+
+	consumer, err := c.js.OrderedConsumer(ctx, c.logStreamName(), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log consumer: %w", err)
+	}
+
+	consumeCtx, err := consumer.Consume(func(msg jetstream.Msg) {
+		var logMsg JobLogMessage
+		if err := json.Unmarshal(msg.Data(), &logMsg); err != nil {
+			// Not every worker sends JSON - treat the raw payload as the line
+			logMsg = JobLogMessage{JobID: jobID, Line: string(msg.Data())}
+		}
+
+		var seq uint64
+		if meta, err := msg.Metadata(); err == nil {
+			seq = meta.Sequence.Stream
+			if logMsg.Timestamp.IsZero() {
+				logMsg.Timestamp = meta.Timestamp
+			}
+		}
+
+		handler(logMsg, seq)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume job logs: %w", err)
+	}
+
+	return consumeCtx.Stop, nil
+}
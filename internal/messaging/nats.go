@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/nats-io/nats.go"
@@ -20,6 +21,7 @@ type JobStore interface {
 	AddJob(job storage.JobInfo)
 	GetJob(id string) (storage.JobInfo, error)
 	UpdateJobStatus(id string, status storage.JobStatus, message string) error
+	TransitionJob(id string, status storage.JobStatus, message string, reason string) (storage.JobInfo, error)
 }
 
 // NATSClient is a client for connecting to NATS
@@ -32,8 +34,17 @@ type NATSClient struct {
 	jobStore    *storage.JobStore
 	initialized bool
 	config      NATSConfig
+
+	// Listeners notified after a status update has been applied to the store
+	statusHandlers []StatusHandler
+	handlersMutex  sync.RWMutex
 }
 
+// StatusHandler is called for every status update applied to the job store
+// The job passed in is the stored state after the update
+// Lets other components react to worker updates without a second subscription
+type StatusHandler func(update JobStatusUpdate, job storage.JobInfo)
+
 // NATSConfig holds configuration for the NATS client
 // Separated from the main config for cleaner code organization
 // Makes it easier to run with different NATS clusters - virjilakrum
@@ -52,9 +63,18 @@ type JobStatusUpdate struct {
 	Status    string    `json:"status"`
 	Message   string    `json:"message,omitempty"`
 	Timestamp time.Time `json:"timestamp"`
+	Reason    string    `json:"reason,omitempty"`   // Failure reason code, e.g. "preempted" or "oom"
+	Attempt   int       `json:"attempt,omitempty"`  // Attempt from the job message, updates for older attempts are dropped
 	Progress  float64   `json:"progress,omitempty"` // 0-100 percent
 	StartedAt time.Time `json:"started_at,omitempty"`
 	EndedAt   time.Time `json:"ended_at,omitempty"`
+
+	// Checkpoint the worker saved, e.g. "s3://ckpt/job-1/step-4000"
+	// Sent with "paused" and whenever a periodic checkpoint is written
+	Checkpoint string `json:"checkpoint,omitempty"`
+
+	// Outputs uploaded so far, usually sent with "completed"
+	Artifacts []storage.JobArtifact `json:"artifacts,omitempty"`
 }
 
 // NewNATSClient creates a new NATS client
@@ -126,6 +146,35 @@ func (c *NATSClient) SetJobStore(jobStore *storage.JobStore) {
 	c.jobStore = jobStore
 }
 
+// OnStatusUpdate registers a handler that is called after each status update
+// Handlers run on the status update goroutine so they should not block for long
+func (c *NATSClient) OnStatusUpdate(handler StatusHandler) {
+	c.handlersMutex.Lock()
+	defer c.handlersMutex.Unlock()
+
+	c.statusHandlers = append(c.statusHandlers, handler)
+}
+
+// notifyStatusHandlers calls every registered status handler
+// A panicking handler must not stop the others from running
+func (c *NATSClient) notifyStatusHandlers(update JobStatusUpdate, job storage.JobInfo) {
+	c.handlersMutex.RLock()
+	handlers := make([]StatusHandler, len(c.statusHandlers))
+	copy(handlers, c.statusHandlers)
+	c.handlersMutex.RUnlock()
+
+	for _, handler := range handlers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					c.logger.Errorf("Panic in status handler: %v", r)
+				}
+			}()
+			handler(update, job)
+		}()
+	}
+}
+
 // EnsureStream ensures that the stream exists
 // Critical for ensuring our job messages are persisted
 // Uses MaxAge to prevent infinite storage growth - virjilakrum
@@ -193,7 +242,7 @@ func (c *NATSClient) Subscribe(subject string, handler func([]byte)) error {
 // PublishToStream publishes a message to the JetStream
 // Returns the server acknowledgment for confirmed delivery
 // Critical for reliable job submission - virjilakrum
-func (c *NATSClient) PublishToStream(subject string, message interface{}) (*jetstream.PubAck, error) {
+func (c *NATSClient) PublishToStream(subject string, message interface{}, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
 	if !c.initialized {
 		return nil, errors.New("NATS client not initialized")
 	}
@@ -203,7 +252,7 @@ func (c *NATSClient) PublishToStream(subject string, message interface{}) (*jets
 		return nil, fmt.Errorf("failed to marshal message: %w", err)
 	}
 
-	ack, err := c.js.Publish(context.Background(), subject, data)
+	ack, err := c.js.Publish(context.Background(), subject, data, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to publish to stream: %w", err)
 	}
@@ -239,49 +288,60 @@ func (c *NATSClient) SubscribeToStatusUpdates() error {
 			}
 
 			// Convert to job store status
-			var status storage.JobStatus
-			switch update.Status {
-			case "queued":
-				status = storage.JobStatusQueued
-			case "processing":
-				status = storage.JobStatusProcessing
-			case "completed":
-				status = storage.JobStatusCompleted
-			case "failed":
-				status = storage.JobStatusFailed
-			case "cancelled":
-				status = storage.JobStatusCancelled
-			default:
+			status, ok := storage.ParseJobStatus(update.Status)
+			if !ok {
 				c.logger.Warnf("Unknown job status: %s", update.Status)
 				return
 			}
 
 			// Update job in store
-			err := c.jobStore.UpdateJobStatus(update.JobID, status, update.Message)
+			// Illegal transitions (e.g. a late "queued" for a completed job) are dropped
+			// The worker's times go in with the status so the attempt is billed by them
+			jobInfo, err := c.jobStore.ReportStatus(update.JobID, storage.StatusReport{
+				Status:    status,
+				Message:   update.Message,
+				Reason:    update.Reason,
+				Attempt:   update.Attempt,
+				StartedAt: update.StartedAt,
+				EndedAt:   update.EndedAt,
+			})
 			if err != nil {
-				c.logger.Warnf("Failed to update job status: %v", err)
+				if errors.Is(err, storage.ErrInvalidTransition) || errors.Is(err, storage.ErrStaleAttempt) {
+					c.logger.Warnf("Ignoring status update for job %s: %v", update.JobID, err)
+				} else {
+					c.logger.Warnf("Failed to update job status: %v", err)
+				}
 				return
 			}
 
-			// Get current job info to update timestamps
-			jobInfo, err := c.jobStore.GetJob(update.JobID)
-			if err != nil {
-				c.logger.Warnf("Failed to get job for timestamp update: %v", err)
-				return
+			// Keep the latest progress so job details can show it
+			if update.Progress > 0 {
+				if err := c.jobStore.UpdateJobProgress(update.JobID, update.Attempt, update.Progress); err != nil {
+					c.logger.Warnf("Failed to update job progress: %v", err)
+				}
+			}
+
+			// Remember the latest checkpoint so a resume can start from it
+			if update.Checkpoint != "" {
+				if err := c.jobStore.UpdateJobCheckpoint(update.JobID, update.Attempt, update.Checkpoint); err != nil {
+					c.logger.Warnf("Failed to update job checkpoint: %v", err)
+				}
 			}
 
-			// Update timestamps
-			if !update.StartedAt.IsZero() {
-				jobInfo.StartedAt = update.StartedAt
-				c.jobStore.AddJob(jobInfo) // Re-add the job with updated timestamps
+			if len(update.Artifacts) > 0 {
+				if err := c.jobStore.AddJobArtifacts(update.JobID, update.Attempt, update.Artifacts); err != nil {
+					c.logger.Warnf("Failed to record job artifacts: %v", err)
+				}
 			}
 
-			if !update.EndedAt.IsZero() {
-				jobInfo.CompletedAt = update.EndedAt
-				c.jobStore.AddJob(jobInfo) // Re-add the job with updated timestamps
+			// Get current job info for the listeners
+			if latest, err := c.jobStore.GetJob(update.JobID); err == nil {
+				jobInfo = latest
 			}
 
 			c.logger.Infof("Updated job status: id=%s status=%s", update.JobID, update.Status)
+
+			c.notifyStatusHandlers(update, jobInfo)
 		}()
 	})
 
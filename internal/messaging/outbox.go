@@ -0,0 +1,135 @@
+package messaging
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/metrics"
+	"siger-api-gateway/internal/storage"
+)
+
+// Outbox relay timing
+const (
+	DefaultOutboxInterval = time.Second      // Poll interval, new messages wake the relay right away
+	OutboxStuckAfter      = time.Minute      // Messages older than this count as stuck
+	outboxMaxBackoff      = 30 * time.Second // Retry cap while NATS is unreachable
+)
+
+// OutboxRelay publishes the job messages stored in the job store's outbox
+// A message is removed only after JetStream acked it, so a NATS outage
+// delays jobs instead of losing them. Messages for jobs that left the queue
+// or moved on to another attempt are dropped
+type OutboxRelay struct {
+	client   *NATSClient
+	store    *storage.JobStore
+	wake     chan struct{}
+	failures int       // Failed rounds in a row
+	retryAt  time.Time // No publishing before this while NATS is failing
+	logger   internal.LoggerInterface
+}
+
+// NewOutboxRelay creates a relay publishing the store's outbox through client
+func NewOutboxRelay(client *NATSClient, store *storage.JobStore) *OutboxRelay {
+	return &OutboxRelay{
+		client: client,
+		store:  store,
+		wake:   make(chan struct{}, 1),
+		logger: internal.Logger,
+	}
+}
+
+// Run publishes due messages until ctx is done
+func (r *OutboxRelay) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultOutboxInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.relay()
+		case <-r.wake:
+			r.relay()
+		}
+	}
+}
+
+// Wake runs a relay round now instead of on the next tick
+// Safe on a nil relay, submissions without NATS have nothing to wake
+func (r *OutboxRelay) Wake() {
+	if r == nil {
+		return
+	}
+	select {
+	case r.wake <- struct{}{}:
+	default: // A round is already pending
+	}
+}
+
+// relay publishes every due message, oldest first
+// Stops at the first failure so messages keep their order, and backs off
+// as a whole since the next message would hit the same outage. Messages
+// carry their outbox ID as Nats-Msg-Id, so one that JetStream stored but
+// whose ack was lost isn't stored twice on the retry
+func (r *OutboxRelay) relay() {
+	now := time.Now().UTC()
+	if now.Before(r.retryAt) {
+		r.updateMetrics()
+		return
+	}
+
+	for _, message := range r.store.DueMessages(now) {
+		job, err := r.store.GetJob(message.JobID)
+		if err != nil || job.Status != storage.JobStatusQueued || job.Attempt != message.Attempt || job.Held {
+			r.store.RemoveMessage(message.ID)
+			metrics.OutboxPublishTotal.WithLabelValues("dropped").Inc()
+			r.logger.Infof("Outbox message dropped, job moved on: id=%s attempt=%d", message.JobID, message.Attempt)
+			continue
+		}
+
+		if _, err := r.client.PublishToStream(message.Subject, message.Payload, jetstream.WithMsgID(message.ID)); err != nil {
+			r.failures++
+			backoff := outboxBackoff(r.failures)
+			r.retryAt = now.Add(backoff)
+			r.store.MarkMessageFailed(message.ID, err.Error(), r.retryAt)
+			metrics.OutboxPublishTotal.WithLabelValues("failed").Inc()
+			r.logger.Warnf("Failed to publish outbox message: id=%s attempt=%d retry_in=%s error=%v",
+				message.JobID, message.Attempt, backoff, err)
+			break // Publish failures are almost always NATS itself, the rest would fail too
+		}
+
+		r.failures = 0
+		r.store.RemoveMessage(message.ID)
+		metrics.OutboxPublishTotal.WithLabelValues("published").Inc()
+		r.logger.Infof("Job published: id=%s attempt=%d subject=%s", message.JobID, message.Attempt, message.Subject)
+	}
+	r.updateMetrics()
+}
+
+// updateMetrics exports the outbox backlog
+func (r *OutboxRelay) updateMetrics() {
+	stats := r.store.OutboxStats(time.Now().UTC(), OutboxStuckAfter)
+	metrics.OutboxPendingMessages.Set(float64(stats.Pending))
+	metrics.OutboxStuckMessages.Set(float64(stats.Stuck))
+	metrics.OutboxOldestMessageAge.Set(stats.OldestAge.Seconds())
+}
+
+// outboxBackoff returns the wait after n failed rounds in a row: 1s doubling, capped
+func outboxBackoff(failures int) time.Duration {
+	backoff := time.Second
+	for i := 1; i < failures && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > outboxMaxBackoff {
+		backoff = outboxMaxBackoff
+	}
+	return backoff
+}
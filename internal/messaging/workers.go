@@ -0,0 +1,37 @@
+package messaging
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WorkerHeartbeatSubject is where workers announce themselves and their GPUs
+// Plain NATS, not JetStream - a missed heartbeat is stale anyway
+const WorkerHeartbeatSubject = "workers.heartbeat"
+
+// WorkerHeartbeat is sent by every worker at a fixed interval
+// GPUs and FreeGPUs are keyed by GPU type, e.g. {"H100": 8}
+type WorkerHeartbeat struct {
+	WorkerID  string            `json:"worker_id"`
+	Hostname  string            `json:"hostname,omitempty"`
+	GPUs      map[string]int    `json:"gpus"`      // Installed GPUs
+	FreeGPUs  map[string]int    `json:"free_gpus"` // GPUs not running a job right now
+	Labels    map[string]string `json:"labels,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// SubscribeToHeartbeats calls handler for every worker heartbeat
+func (c *NATSClient) SubscribeToHeartbeats(handler func(WorkerHeartbeat)) error {
+	return c.Subscribe(WorkerHeartbeatSubject, func(data []byte) {
+		var heartbeat WorkerHeartbeat
+		if err := json.Unmarshal(data, &heartbeat); err != nil {
+			c.logger.Warnf("Failed to unmarshal worker heartbeat: %v", err)
+			return
+		}
+		if heartbeat.WorkerID == "" {
+			c.logger.Warn("Ignoring worker heartbeat without a worker ID")
+			return
+		}
+		handler(heartbeat)
+	})
+}
@@ -0,0 +1,174 @@
+package metering
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/storage"
+)
+
+// Defaults for the usage ledger
+// 500k records is a few months of history for our cluster at ~50 bytes a field
+const (
+	DefaultMaxRecords = 500000
+	DefaultCurrency   = "USD"
+)
+
+// Record is the GPU consumption of a single finished job attempt
+// The price is captured when the record is written so changing the price
+// table later doesn't rewrite past bills
+type Record struct {
+	JobID           string    `json:"job_id"`
+	Attempt         int       `json:"attempt"`
+	UserID          string    `json:"user_id"`
+	Project         string    `json:"project,omitempty"`
+	JobType         string    `json:"job_type"`
+	GPUType         string    `json:"gpu_type"`
+	GPUCount        int       `json:"gpu_count"`
+	Status          string    `json:"status"`
+	StartedAt       time.Time `json:"started_at"`
+	EndedAt         time.Time `json:"ended_at"`
+	GPUSeconds      float64   `json:"gpu_seconds"`
+	PricePerGPUHour float64   `json:"price_per_gpu_hour"`
+	Cost            float64   `json:"cost"`
+}
+
+// Meter turns finished job runs into usage records
+// Driven by job store changes like the quota manager, so it sees every
+// attempt including the ones the retry policy requeues
+type Meter struct {
+	config     internal.MeteringConfig
+	mutex      sync.RWMutex
+	records    []Record // Ordered by EndedAt
+	seen       map[recordKey]struct{}
+	maxRecords int
+	logger     internal.LoggerInterface
+}
+
+// recordKey identifies a job attempt so repeated terminal updates are billed once
+type recordKey struct {
+	jobID   string
+	attempt int
+}
+
+// NewMeter creates a new meter with the given price table
+func NewMeter(config internal.MeteringConfig, maxRecords int) *Meter {
+	if maxRecords <= 0 {
+		maxRecords = DefaultMaxRecords
+	}
+
+	return &Meter{
+		config:     config,
+		seen:       make(map[recordKey]struct{}),
+		maxRecords: maxRecords,
+		logger:     internal.Logger,
+	}
+}
+
+// Currency returns the currency costs are reported in
+func (m *Meter) Currency() string {
+	if m.config.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.config.Currency
+}
+
+// PriceFor returns the price per GPU-hour for a GPU type
+func (m *Meter) PriceFor(gpuType string) float64 {
+	if price, ok := m.config.Prices[gpuType]; ok {
+		return price
+	}
+	return m.config.DefaultPrice
+}
+
+// Observe is a storage.ChangeHandler that records a job attempt when it ends
+func (m *Meter) Observe(old *storage.JobInfo, job *storage.JobInfo) {
+	if job == nil || !job.Status.EndsAttempt() {
+		return
+	}
+	if old != nil && old.Status.EndsAttempt() && old.Attempt == job.Attempt {
+		return // Attempt already booked, e.g. preempted and then failed
+	}
+	if job.StartedAt.IsZero() || job.GPUCount <= 0 {
+		return // Never ran on a GPU, nothing to bill
+	}
+
+	endedAt := job.CompletedAt
+	if endedAt.IsZero() {
+		endedAt = time.Now().UTC()
+	}
+	if !endedAt.After(job.StartedAt) {
+		return
+	}
+
+	record := Record{
+		JobID:           job.JobID,
+		Attempt:         job.Attempt,
+		UserID:          job.UserID,
+		Project:         job.Project,
+		JobType:         job.Type,
+		GPUType:         job.GPUType,
+		GPUCount:        job.GPUCount,
+		Status:          string(job.Status),
+		StartedAt:       job.StartedAt,
+		EndedAt:         endedAt,
+		GPUSeconds:      endedAt.Sub(job.StartedAt).Seconds() * float64(job.GPUCount),
+		PricePerGPUHour: m.PriceFor(job.GPUType),
+	}
+	record.Cost = record.GPUSeconds / 3600 * record.PricePerGPUHour
+
+	m.add(record)
+}
+
+// add appends a record, keeping the ledger ordered and bounded
+func (m *Meter) add(record Record) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key := recordKey{jobID: record.JobID, attempt: record.Attempt}
+	if _, ok := m.seen[key]; ok {
+		return
+	}
+	m.seen[key] = struct{}{}
+
+	// Records almost always arrive in EndedAt order, so this is an append
+	i := sort.Search(len(m.records), func(i int) bool { return m.records[i].EndedAt.After(record.EndedAt) })
+	m.records = append(m.records, Record{})
+	copy(m.records[i+1:], m.records[i:])
+	m.records[i] = record
+
+	if overflow := len(m.records) - m.maxRecords; overflow > 0 {
+		for _, dropped := range m.records[:overflow] {
+			delete(m.seen, recordKey{jobID: dropped.JobID, attempt: dropped.Attempt})
+		}
+		m.records = append([]Record(nil), m.records[overflow:]...)
+		m.logger.Debugf("Usage ledger full, dropped %d oldest records", overflow)
+	}
+}
+
+// Records returns the records of runs overlapping [from, to) for a user, or all users if userID is empty
+// Zero from/to leave that side of the range open
+func (m *Meter) Records(userID string, from, to time.Time) []Record {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	// Runs that ended before from can't overlap the range
+	start := 0
+	if !from.IsZero() {
+		start = sort.Search(len(m.records), func(i int) bool { return m.records[i].EndedAt.After(from) })
+	}
+
+	records := make([]Record, 0)
+	for _, record := range m.records[start:] {
+		if userID != "" && record.UserID != userID {
+			continue
+		}
+		if !to.IsZero() && !record.StartedAt.Before(to) {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records
+}
@@ -0,0 +1,225 @@
+package metering
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Dimensions usage can be grouped by
+const (
+	GroupByUser    = "user"
+	GroupByProject = "project"
+	GroupByJobType = "type"
+	GroupByGPUType = "gpu_type"
+	GroupByDay     = "day"
+)
+
+// dayLayout is the format of the day dimension (UTC)
+const dayLayout = "2006-01-02"
+
+// UsageGroup is the aggregated usage for one combination of the grouped dimensions
+// Dimensions that weren't grouped by are left empty
+type UsageGroup struct {
+	UserID     string  `json:"user_id,omitempty"`
+	Project    string  `json:"project,omitempty"`
+	JobType    string  `json:"job_type,omitempty"`
+	GPUType    string  `json:"gpu_type,omitempty"`
+	Day        string  `json:"day,omitempty"`
+	Jobs       int     `json:"jobs"` // Job attempts that ran in this group
+	GPUSeconds float64 `json:"gpu_seconds"`
+	GPUHours   float64 `json:"gpu_hours"`
+	Cost       float64 `json:"cost"`
+}
+
+// Summary is usage aggregated over a time range
+type Summary struct {
+	From          time.Time    `json:"from"`
+	To            time.Time    `json:"to"`
+	GroupBy       []string     `json:"group_by"`
+	Currency      string       `json:"currency"`
+	TotalGPUHours float64      `json:"total_gpu_hours"`
+	TotalCost     float64      `json:"total_cost"`
+	Groups        []UsageGroup `json:"groups"`
+}
+
+// ValidateGroupBy checks the group_by dimensions
+func ValidateGroupBy(groupBy []string) error {
+	seen := make(map[string]bool, len(groupBy))
+	for _, dimension := range groupBy {
+		switch dimension {
+		case GroupByUser, GroupByProject, GroupByJobType, GroupByGPUType, GroupByDay:
+		default:
+			return fmt.Errorf("invalid group_by: unknown dimension %q", dimension)
+		}
+		if seen[dimension] {
+			return fmt.Errorf("invalid group_by: duplicate dimension %q", dimension)
+		}
+		seen[dimension] = true
+	}
+	return nil
+}
+
+// Summarize aggregates records over [from, to)
+// Runs are clipped to the range and split at UTC midnight when grouping by day,
+// so a job running over a month boundary is billed to the right month
+func Summarize(records []Record, groupBy []string, from, to time.Time, currency string) Summary {
+	summary := Summary{
+		From:     from,
+		To:       to,
+		GroupBy:  groupBy,
+		Currency: currency,
+		Groups:   make([]UsageGroup, 0),
+	}
+
+	byDay := false
+	for _, dimension := range groupBy {
+		if dimension == GroupByDay {
+			byDay = true
+		}
+	}
+
+	groups := make(map[UsageGroup]*UsageGroup)
+	for _, record := range records {
+		start, end := record.StartedAt, record.EndedAt
+		if !from.IsZero() && start.Before(from) {
+			start = from
+		}
+		if !to.IsZero() && end.After(to) {
+			end = to
+		}
+		if !end.After(start) {
+			continue
+		}
+
+		for _, span := range splitRun(start, end, byDay) {
+			key := groupKey(record, groupBy, span.start)
+			group, ok := groups[key]
+			if !ok {
+				group = &key
+				groups[key] = group
+			}
+
+			gpuSeconds := span.end.Sub(span.start).Seconds() * float64(record.GPUCount)
+			group.Jobs++
+			group.GPUSeconds += gpuSeconds
+			group.Cost += gpuSeconds / 3600 * record.PricePerGPUHour
+		}
+	}
+
+	for _, group := range groups {
+		group.GPUHours = group.GPUSeconds / 3600
+		summary.TotalGPUHours += group.GPUHours
+		summary.TotalCost += group.Cost
+		summary.Groups = append(summary.Groups, *group)
+	}
+
+	sort.Slice(summary.Groups, func(i, j int) bool {
+		a, b := summary.Groups[i], summary.Groups[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		if a.JobType != b.JobType {
+			return a.JobType < b.JobType
+		}
+		return a.GPUType < b.GPUType
+	})
+
+	return summary
+}
+
+// span is a part of a run
+type span struct {
+	start time.Time
+	end   time.Time
+}
+
+// splitRun splits [start, end) at UTC midnights when byDay is set
+func splitRun(start, end time.Time, byDay bool) []span {
+	if !byDay {
+		return []span{{start: start, end: end}}
+	}
+
+	var spans []span
+	for start.Before(end) {
+		y, m, d := start.UTC().Date()
+		midnight := time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+		if midnight.After(end) {
+			midnight = end
+		}
+		spans = append(spans, span{start: start, end: midnight})
+		start = midnight
+	}
+	return spans
+}
+
+// groupKey returns the group a part of a run belongs to
+func groupKey(record Record, groupBy []string, spanStart time.Time) UsageGroup {
+	var key UsageGroup
+	for _, dimension := range groupBy {
+		switch dimension {
+		case GroupByUser:
+			key.UserID = record.UserID
+		case GroupByProject:
+			key.Project = record.Project
+		case GroupByJobType:
+			key.JobType = record.JobType
+		case GroupByGPUType:
+			key.GPUType = record.GPUType
+		case GroupByDay:
+			key.Day = spanStart.UTC().Format(dayLayout)
+		}
+	}
+	return key
+}
+
+// WriteCSV writes the summary groups as CSV, one row per group
+// Only the grouped dimensions get a column so spreadsheets pivot cleanly
+func WriteCSV(w io.Writer, summary Summary) error {
+	writer := csv.NewWriter(w)
+
+	header := append([]string(nil), summary.GroupBy...)
+	header = append(header, "jobs", "gpu_hours", "cost", "currency")
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, group := range summary.Groups {
+		row := make([]string, 0, len(header))
+		for _, dimension := range summary.GroupBy {
+			switch dimension {
+			case GroupByUser:
+				row = append(row, group.UserID)
+			case GroupByProject:
+				row = append(row, group.Project)
+			case GroupByJobType:
+				row = append(row, group.JobType)
+			case GroupByGPUType:
+				row = append(row, group.GPUType)
+			case GroupByDay:
+				row = append(row, group.Day)
+			}
+		}
+		row = append(row,
+			strconv.Itoa(group.Jobs),
+			strconv.FormatFloat(group.GPUHours, 'f', 4, 64),
+			strconv.FormatFloat(group.Cost, 'f', 2, 64),
+			summary.Currency,
+		)
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
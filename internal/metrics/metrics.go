@@ -61,4 +61,37 @@ var (
 		},
 		[]string{"service"},
 	)
+
+	// OutboxPendingMessages tracks job messages stored but not yet published
+	OutboxPendingMessages = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "gateway_outbox_pending_messages",
+			Help: "Number of job messages waiting in the outbox to be published",
+		},
+	)
+
+	// OutboxStuckMessages tracks outbox messages waiting longer than they should
+	OutboxStuckMessages = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "gateway_outbox_stuck_messages",
+			Help: "Number of outbox messages waiting for more than a minute",
+		},
+	)
+
+	// OutboxOldestMessageAge tracks how long the oldest outbox message has waited
+	OutboxOldestMessageAge = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "gateway_outbox_oldest_message_age_seconds",
+			Help: "Age of the oldest message in the outbox in seconds",
+		},
+	)
+
+	// OutboxPublishTotal counts outbox publish attempts by result
+	OutboxPublishTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_outbox_publish_total",
+			Help: "Total number of outbox messages handled by result (published, failed, dropped)",
+		},
+		[]string{"result"},
+	)
 )
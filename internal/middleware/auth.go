@@ -17,9 +17,10 @@ import (
 // Including role in the JWT itself saves database lookups on each request
 // Tradeoff is that role changes require re-issuance of tokens - virjilakrum
 type UserClaims struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	UserID   string   `json:"user_id"`
+	Username string   `json:"username"`
+	Role     string   `json:"role"`
+	Projects []string `json:"projects,omitempty"` // Projects the user is a member of
 	jwt.RegisteredClaims
 }
 
@@ -43,6 +44,8 @@ const (
 	UserIDContextKey   = contextKey("user_id")
 	UsernameContextKey = contextKey("username")
 	UserRoleContextKey = contextKey("user_role")
+
+	UserProjectsContextKey = contextKey("user_projects")
 )
 
 // JWTAuth returns a middleware that validates JWT tokens
@@ -67,45 +70,67 @@ func JWTAuth(jwtSecret string) func(next http.Handler) http.Handler {
 
 			tokenString := parts[1]
 
-			// Parse and validate token
-			// Using HMAC-SHA256 for symmetric key signing
-			// Considered RSA for asymmetric but the key management was too complex - virjilakrum
-			token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, func(token *jwt.Token) (interface{}, error) {
-				// Make sure the signing method is what we expect
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-				}
-				return []byte(jwtSecret), nil
-			})
-
+			claims, err := ParseToken(tokenString, jwtSecret)
 			if err != nil {
-				if errors.Is(err, jwt.ErrTokenExpired) {
+				switch {
+				case errors.Is(err, ErrExpiredToken):
 					http.Error(w, "Unauthorized: token has expired", http.StatusUnauthorized)
-				} else {
+				case errors.Is(err, ErrInvalidToken):
+					http.Error(w, "Unauthorized: invalid token claims", http.StatusUnauthorized)
+				default:
 					internal.Logger.Errorw("JWT validation error", "error", err)
 					http.Error(w, "Unauthorized: invalid token", http.StatusUnauthorized)
 				}
 				return
 			}
 
-			// Extract claims
-			if claims, ok := token.Claims.(*UserClaims); ok && token.Valid {
-				// Add user information to request context
-				// This makes auth data available to all downstream handlers
-				// Much cleaner than passing around user objects - virjilakrum
-				ctx := context.WithValue(r.Context(), UserIDContextKey, claims.UserID)
-				ctx = context.WithValue(ctx, UsernameContextKey, claims.Username)
-				ctx = context.WithValue(ctx, UserRoleContextKey, claims.Role)
-
-				// Pass control to the next handler with the enhanced context
-				next.ServeHTTP(w, r.WithContext(ctx))
-			} else {
-				http.Error(w, "Unauthorized: invalid token claims", http.StatusUnauthorized)
-			}
+			// Pass control to the next handler with the enhanced context
+			next.ServeHTTP(w, r.WithContext(ContextWithClaims(r.Context(), claims)))
 		})
 	}
 }
 
+// ParseToken parses and validates a JWT token string and returns its claims
+// Shared by the middleware and handlers that can't use the Authorization header
+// (browsers can't set headers on WebSocket connections)
+func ParseToken(tokenString string, jwtSecret string) (*UserClaims, error) {
+	// Parse and validate token
+	// Using HMAC-SHA256 for symmetric key signing
+	// Considered RSA for asymmetric but the key management was too complex - virjilakrum
+	token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, func(token *jwt.Token) (interface{}, error) {
+		// Make sure the signing method is what we expect
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(jwtSecret), nil
+	})
+
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrExpiredToken
+		}
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*UserClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// ContextWithClaims adds the user information from the claims to the context
+// This makes auth data available to all downstream handlers
+// Much cleaner than passing around user objects - virjilakrum
+func ContextWithClaims(ctx context.Context, claims *UserClaims) context.Context {
+	ctx = context.WithValue(ctx, UserIDContextKey, claims.UserID)
+	ctx = context.WithValue(ctx, UsernameContextKey, claims.Username)
+	ctx = context.WithValue(ctx, UserRoleContextKey, claims.Role)
+	ctx = context.WithValue(ctx, UserProjectsContextKey, claims.Projects)
+	return ctx
+}
+
 // RequireRole returns a middleware that checks if the user has the required role
 // Simple RBAC implementation - admin role has access to everything
 // We'll add more granular permissions later if needed - virjilakrum
@@ -130,15 +155,56 @@ func RequireRole(requiredRole string) func(next http.Handler) http.Handler {
 	}
 }
 
+// Permissions granted on top of the owner/project checks
+// jobs:*:any lets a role read and act on any user's jobs
+const (
+	PermissionJobsAny      = "jobs:*:any"
+	PermissionTemplatesAny = "templates:*:any"
+	PermissionDatasetsAny  = "datasets:*:any"
+	PermissionJobsPreempt  = "jobs:preempt" // Jobs submitted with it may preempt others
+)
+
+// rolePermissions maps roles to the permissions they're granted
+var rolePermissions = map[string][]string{
+	"admin": {PermissionJobsAny, PermissionTemplatesAny, PermissionDatasetsAny, PermissionJobsPreempt},
+}
+
+// HasPermission reports whether the authenticated user's role grants a permission
+func HasPermission(ctx context.Context, permission string) bool {
+	role, _ := ctx.Value(UserRoleContextKey).(string)
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// IsProjectMember reports whether the authenticated user belongs to the project
+// Admins are treated as members of every project
+func IsProjectMember(ctx context.Context, project string) bool {
+	if role, _ := ctx.Value(UserRoleContextKey).(string); role == "admin" {
+		return true
+	}
+	projects, _ := ctx.Value(UserProjectsContextKey).([]string)
+	for _, p := range projects {
+		if p == project {
+			return true
+		}
+	}
+	return false
+}
+
 // GenerateToken generates a new JWT token for a user
 // Setting expiration on tokens is critical for security
 // We use 60 min default but can be configured per-environment - virjilakrum
-func GenerateToken(userID, username, role string, secret string, expirationMinutes int) (string, error) {
+func GenerateToken(userID, username, role string, projects []string, secret string, expirationMinutes int) (string, error) {
 	// Create claims with user information
 	claims := UserClaims{
 		UserID:   userID,
 		Username: username,
 		Role:     role,
+		Projects: projects,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expirationMinutes) * time.Minute)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Timeout returns a middleware that cuts off requests running past timeout
+// with a 504. Applied per route group, long-lived streams (Server-Sent Events,
+// WebSockets) and large transfers are registered outside those groups
+func Timeout(timeout time.Duration) func(next http.Handler) http.Handler {
+	return middleware.Timeout(timeout)
+}
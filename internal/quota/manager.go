@@ -0,0 +1,452 @@
+package quota
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/storage"
+)
+
+// Quota dimensions reported in errors and usage
+const (
+	LimitConcurrentGPUs = "max_concurrent_gpus"
+	LimitQueuedJobs     = "max_queued_jobs"
+	LimitGPUHours       = "gpu_hours"
+)
+
+// Quota scopes
+const (
+	ScopeUser    = "user"
+	ScopeProject = "project"
+)
+
+// Defaults for the GPU-hours window
+// 30 days matches our monthly team budgets
+const (
+	DefaultPeriod   = 30 * 24 * time.Hour
+	allGPUTypesKey  = "*"
+	maxLedgerWindow = 366 * 24 * time.Hour
+)
+
+// Error explains why a job was not admitted
+// Permanent errors (the request can never fit) map to 403,
+// the rest clear up once running jobs finish and map to 429
+type Error struct {
+	Scope     string  `json:"scope"`
+	Subject   string  `json:"subject"`
+	Limit     string  `json:"limit"`
+	GPUType   string  `json:"gpu_type,omitempty"`
+	Max       float64 `json:"max"`
+	Current   float64 `json:"current"`
+	Requested float64 `json:"requested"`
+	Permanent bool    `json:"permanent"`
+}
+
+// Error implements the error interface with a human readable explanation
+func (e *Error) Error() string {
+	subject := e.Scope
+	if e.Scope == ScopeProject {
+		subject = fmt.Sprintf("project %q", e.Subject)
+	}
+
+	switch e.Limit {
+	case LimitConcurrentGPUs:
+		gpuType := e.GPUType
+		if gpuType == allGPUTypesKey {
+			gpuType = "all"
+		}
+		if e.Permanent {
+			return fmt.Sprintf("%s limit for %s GPUs is %.0f, request for %.0f can never be admitted",
+				subject, gpuType, e.Max, e.Requested)
+		}
+		return fmt.Sprintf("%s limit for %s GPUs is %.0f, %.0f already in use or queued, %.0f requested",
+			subject, gpuType, e.Max, e.Current, e.Requested)
+	case LimitQueuedJobs:
+		return fmt.Sprintf("%s limit of %.0f queued jobs reached", subject, e.Max)
+	case LimitGPUHours:
+		return fmt.Sprintf("%s has used %.1f of %.1f GPU-hours in the current period", subject, e.Current, e.Max)
+	}
+	return fmt.Sprintf("%s quota %s exceeded", subject, e.Limit)
+}
+
+// StatusCode returns the HTTP status code for the error
+func (e *Error) StatusCode() int {
+	if e.Permanent {
+		return http.StatusForbidden
+	}
+	return http.StatusTooManyRequests
+}
+
+// Usage is the current consumption of a user or project
+type Usage struct {
+	ActiveGPUs  map[string]int `json:"active_gpus"` // Queued + running GPUs per GPU type
+	TotalGPUs   int            `json:"total_gpus"`
+	QueuedJobs  int            `json:"queued_jobs"`
+	RunningJobs int            `json:"running_jobs"`
+	GPUHours    float64        `json:"gpu_hours"` // Consumed in the current period
+	Period      string         `json:"period"`
+	PeriodStart time.Time      `json:"period_start"`
+}
+
+// Report pairs the limits that apply with the current usage
+type Report struct {
+	Limits internal.QuotaLimits `json:"limits"`
+	Usage  Usage                `json:"usage"`
+}
+
+// activeJob is a job that still holds (or is waiting for) GPUs
+type activeJob struct {
+	userID    string
+	project   string
+	gpuType   string
+	gpuCount  int
+	startedAt time.Time // Zero while queued
+}
+
+// usageEntry is a finished run's GPU consumption
+// We keep the run's interval so runs straddling the window start are prorated
+type usageEntry struct {
+	start    time.Time
+	end      time.Time
+	gpuCount int
+}
+
+// gpuSecondsSince returns the GPU-seconds of the run that fall after windowStart
+func (e usageEntry) gpuSecondsSince(windowStart time.Time) float64 {
+	start := e.start
+	if start.Before(windowStart) {
+		start = windowStart
+	}
+	if !e.end.After(start) {
+		return 0
+	}
+	return e.end.Sub(start).Seconds() * float64(e.gpuCount)
+}
+
+// Manager enforces GPU quotas and tracks consumption
+// Consumption is driven by job store changes, which follow the jobs.status
+// transitions, so we don't need a separate subscription
+type Manager struct {
+	config internal.QuotaConfig
+	mutex  sync.Mutex
+	active map[string]*activeJob
+	ledger map[string][]usageEntry // "user:<id>" or "project:<name>" -> finished runs
+	logger internal.LoggerInterface
+}
+
+// NewManager creates a new quota manager
+func NewManager(config internal.QuotaConfig) *Manager {
+	return &Manager{
+		config: config,
+		active: make(map[string]*activeJob),
+		ledger: make(map[string][]usageEntry),
+		logger: internal.Logger,
+	}
+}
+
+// Enabled reports whether quotas are enforced
+func (m *Manager) Enabled() bool {
+	return m.config.Enabled
+}
+
+// UserLimits returns the limits for a user - user entry, then role, then defaults
+func (m *Manager) UserLimits(userID, role string) internal.QuotaLimits {
+	if limits, ok := m.config.Users[userID]; ok {
+		return limits
+	}
+	if limits, ok := m.config.Roles[role]; ok {
+		return limits
+	}
+	return m.config.Defaults
+}
+
+// ProjectLimits returns the limits for a project, if any are configured
+func (m *Manager) ProjectLimits(project string) (internal.QuotaLimits, bool) {
+	limits, ok := m.config.Projects[project]
+	return limits, ok
+}
+
+// Admit checks a new job against the user's and project's quotas and,
+// if it fits, records it as queued so concurrent submissions see it
+// Returns a *Error when the job is rejected
+func (m *Manager) Admit(job storage.JobInfo, role string) error {
+	return m.AdmitBatch([]storage.JobInfo{job}, role)
+}
+
+// AdmitBatch admits a set of jobs all or nothing
+// Each job is checked with the earlier ones of the batch already counted,
+// and everything admitted so far is released if one of them doesn't fit
+func (m *Manager) AdmitBatch(jobs []storage.JobInfo, role string) error {
+	if !m.config.Enabled {
+		return nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now().UTC()
+
+	for i, job := range jobs {
+		if err := m.admitLocked(job, role, now); err != nil {
+			for _, admitted := range jobs[:i] {
+				delete(m.active, admitted.JobID)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// admitLocked checks and tracks a single job
+func (m *Manager) admitLocked(job storage.JobInfo, role string, now time.Time) error {
+	userLimits := m.UserLimits(job.UserID, role)
+	if err := m.checkLocked(ScopeUser, job.UserID, userLimits, job, now); err != nil {
+		return err
+	}
+
+	if job.Project != "" {
+		if projectLimits, ok := m.ProjectLimits(job.Project); ok {
+			if err := m.checkLocked(ScopeProject, job.Project, projectLimits, job, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	m.trackLocked(nil, &job, now)
+	return nil
+}
+
+// AdmitChange checks a queued job whose GPU request changed against the quotas
+// The job's old request is left out of the usage while checking, and kept if
+// the new one doesn't fit. Call it from a store update callback so the change
+// and the store write happen together
+func (m *Manager) AdmitChange(old, job storage.JobInfo, role string) error {
+	if !m.config.Enabled {
+		return nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	previous, ok := m.active[old.JobID]
+	delete(m.active, old.JobID)
+
+	if err := m.admitLocked(job, role, time.Now().UTC()); err != nil {
+		if ok {
+			m.active[old.JobID] = previous
+		}
+		return err
+	}
+	return nil
+}
+
+// checkLocked checks one set of limits for a user or project
+func (m *Manager) checkLocked(scope, subject string, limits internal.QuotaLimits, job storage.JobInfo, now time.Time) error {
+	usage := m.usageLocked(scope, subject, limits, now)
+
+	for _, gpuType := range []string{job.GPUType, allGPUTypesKey} {
+		max, ok := limits.MaxConcurrentGPUs[gpuType]
+		if !ok || max <= 0 {
+			continue
+		}
+		current := usage.ActiveGPUs[gpuType]
+		if gpuType == allGPUTypesKey {
+			current = usage.TotalGPUs
+		}
+		if job.GPUCount > max || current+job.GPUCount > max {
+			return &Error{
+				Scope:     scope,
+				Subject:   subject,
+				Limit:     LimitConcurrentGPUs,
+				GPUType:   gpuType,
+				Max:       float64(max),
+				Current:   float64(current),
+				Requested: float64(job.GPUCount),
+				Permanent: job.GPUCount > max,
+			}
+		}
+	}
+
+	if limits.MaxQueuedJobs > 0 && usage.QueuedJobs+1 > limits.MaxQueuedJobs {
+		return &Error{
+			Scope:     scope,
+			Subject:   subject,
+			Limit:     LimitQueuedJobs,
+			Max:       float64(limits.MaxQueuedJobs),
+			Current:   float64(usage.QueuedJobs),
+			Requested: 1,
+		}
+	}
+
+	if limits.GPUHours > 0 && usage.GPUHours >= limits.GPUHours {
+		return &Error{
+			Scope:   scope,
+			Subject: subject,
+			Limit:   LimitGPUHours,
+			Max:     limits.GPUHours,
+			Current: usage.GPUHours,
+		}
+	}
+
+	return nil
+}
+
+// Observe is a storage.ChangeHandler that keeps consumption in sync with the job store
+func (m *Manager) Observe(old *storage.JobInfo, job *storage.JobInfo) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.trackLocked(old, job, time.Now().UTC())
+}
+
+// trackLocked applies a job change to the active set and the ledger
+func (m *Manager) trackLocked(old *storage.JobInfo, job *storage.JobInfo, now time.Time) {
+	if job == nil {
+		// Job evicted from the store - close out its consumption
+		if old != nil {
+			m.finishLocked(old.JobID, time.Time{}, now)
+		}
+		return
+	}
+
+	// Preempted jobs hold no GPUs until they're requeued as a new attempt
+	if job.Status.EndsAttempt() {
+		m.finishLocked(job.JobID, job.CompletedAt, now)
+		return
+	}
+
+	active, ok := m.active[job.JobID]
+	if !ok {
+		active = &activeJob{
+			userID:   job.UserID,
+			project:  job.Project,
+			gpuType:  job.GPUType,
+			gpuCount: job.GPUCount,
+		}
+		m.active[job.JobID] = active
+	}
+	active.gpuType = job.GPUType // Queued jobs can be modified
+	active.gpuCount = job.GPUCount
+	active.startedAt = job.StartedAt
+}
+
+// finishLocked removes a job from the active set and books its GPU time
+func (m *Manager) finishLocked(jobID string, endedAt, now time.Time) {
+	active, ok := m.active[jobID]
+	if !ok {
+		return
+	}
+	delete(m.active, jobID)
+
+	if active.startedAt.IsZero() {
+		return // Never ran, nothing consumed
+	}
+	if endedAt.IsZero() {
+		endedAt = now
+	}
+	if !endedAt.After(active.startedAt) || active.gpuCount <= 0 {
+		return
+	}
+
+	entry := usageEntry{start: active.startedAt, end: endedAt, gpuCount: active.gpuCount}
+	m.appendLedgerLocked(ledgerKey(ScopeUser, active.userID), entry, now)
+	if active.project != "" {
+		m.appendLedgerLocked(ledgerKey(ScopeProject, active.project), entry, now)
+	}
+}
+
+// appendLedgerLocked adds an entry and drops entries older than any window we support
+func (m *Manager) appendLedgerLocked(key string, entry usageEntry, now time.Time) {
+	entries := m.ledger[key]
+	cutoff := now.Add(-maxLedgerWindow)
+	i := 0
+	for i < len(entries) && entries[i].end.Before(cutoff) {
+		i++
+	}
+	m.ledger[key] = append(entries[i:], entry)
+}
+
+// UserReport returns the limits and usage for a user
+func (m *Manager) UserReport(userID, role string) Report {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	limits := m.UserLimits(userID, role)
+	return Report{
+		Limits: limits,
+		Usage:  m.usageLocked(ScopeUser, userID, limits, time.Now().UTC()),
+	}
+}
+
+// ProjectReport returns the limits and usage for a project
+func (m *Manager) ProjectReport(project string) Report {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	limits, _ := m.ProjectLimits(project)
+	return Report{
+		Limits: limits,
+		Usage:  m.usageLocked(ScopeProject, project, limits, time.Now().UTC()),
+	}
+}
+
+// usageLocked computes current consumption for a user or project
+// GPU-hours include the part of running jobs that falls inside the window
+func (m *Manager) usageLocked(scope, subject string, limits internal.QuotaLimits, now time.Time) Usage {
+	period := DefaultPeriod
+	if limits.Period != "" {
+		if d, err := time.ParseDuration(limits.Period); err == nil && d > 0 {
+			period = d
+		} else {
+			m.logger.Warnf("Invalid quota period %q for %s %s, using default", limits.Period, scope, subject)
+		}
+	}
+	windowStart := now.Add(-period)
+
+	usage := Usage{
+		ActiveGPUs:  make(map[string]int),
+		Period:      period.String(),
+		PeriodStart: windowStart,
+	}
+
+	var gpuSeconds float64
+	for _, active := range m.active {
+		if scope == ScopeUser && active.userID != subject {
+			continue
+		}
+		if scope == ScopeProject && active.project != subject {
+			continue
+		}
+
+		usage.ActiveGPUs[active.gpuType] += active.gpuCount
+		usage.TotalGPUs += active.gpuCount
+		if active.startedAt.IsZero() {
+			usage.QueuedJobs++
+			continue
+		}
+
+		usage.RunningJobs++
+		start := active.startedAt
+		if start.Before(windowStart) {
+			start = windowStart
+		}
+		if now.After(start) {
+			gpuSeconds += now.Sub(start).Seconds() * float64(active.gpuCount)
+		}
+	}
+
+	for _, entry := range m.ledger[ledgerKey(scope, subject)] {
+		gpuSeconds += entry.gpuSecondsSince(windowStart)
+	}
+	usage.GPUHours = gpuSeconds / 3600
+
+	return usage
+}
+
+// ledgerKey returns the ledger key for a user or project
+func ledgerKey(scope, subject string) string {
+	return scope + ":" + subject
+}
@@ -0,0 +1,219 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"siger-api-gateway/internal/storage"
+)
+
+// KeySize is the length of the encryption key, AES-256
+const KeySize = 32
+
+// MaxValueSize caps a secret value, enough for a service account JSON key
+const MaxValueSize = 64 << 10
+
+// Common errors
+var (
+	ErrNotFound     = errors.New("secret not found")
+	ErrInvalidName  = errors.New("secret names must start with a letter or underscore and contain only letters, digits and underscores, up to 128 characters")
+	ErrValueTooLong = fmt.Errorf("secret values are limited to %d bytes", MaxValueSize)
+)
+
+// namePattern keeps names usable as environment variables on the worker
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,127}$`)
+
+// Secret is what the API shows of a secret - never its value
+type Secret struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// entry is a stored secret, the value sealed with AES-GCM
+type entry struct {
+	Secret
+	sealed []byte
+}
+
+// Store keeps per-user secrets encrypted at rest
+// Values are only decrypted to hand them to a worker that redeems a job's
+// secrets token, so nothing else in the gateway ever sees them
+type Store struct {
+	mutex   sync.RWMutex
+	aead    cipher.AEAD
+	secrets map[string]map[string]entry // Owner -> name -> secret
+	tokens  *storage.JobTokens[Grant]
+}
+
+// NewStore creates an empty secret store encrypting with the given AES-256 key
+func NewStore(key []byte) (*Store, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("secrets encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		aead:    aead,
+		secrets: make(map[string]map[string]entry),
+		tokens:  storage.NewJobTokens[Grant](DefaultTokenTTL),
+	}, nil
+}
+
+// ValidName reports whether name can be used for a secret
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// Put creates or replaces a secret, created is false when it replaced one
+func (s *Store) Put(ownerID, name, value string) (secret Secret, created bool, err error) {
+	if !ValidName(name) {
+		return Secret{}, false, ErrInvalidName
+	}
+	if len(value) > MaxValueSize {
+		return Secret{}, false, ErrValueTooLong
+	}
+
+	sealed, err := s.seal(ownerID, name, value)
+	if err != nil {
+		return Secret{}, false, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now().UTC()
+	owned := s.secrets[ownerID]
+	if owned == nil {
+		owned = make(map[string]entry)
+		s.secrets[ownerID] = owned
+	}
+
+	e, exists := owned[name]
+	if !exists {
+		e.Name = name
+		e.CreatedAt = now
+	}
+	e.UpdatedAt = now
+	e.sealed = sealed
+	owned[name] = e
+	return e.Secret, !exists, nil
+}
+
+// List returns a user's secrets sorted by name
+func (s *Store) List(ownerID string) []Secret {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	list := make([]Secret, 0, len(s.secrets[ownerID]))
+	for _, e := range s.secrets[ownerID] {
+		list = append(list, e.Secret)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Delete removes a secret
+// Jobs already holding a token for it fail to redeem the token afterwards
+func (s *Store) Delete(ownerID, name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.secrets[ownerID][name]; !ok {
+		return ErrNotFound
+	}
+	delete(s.secrets[ownerID], name)
+	if len(s.secrets[ownerID]) == 0 {
+		delete(s.secrets, ownerID)
+	}
+	return nil
+}
+
+// Check returns a *MissingError for the first name the user has no secret for
+func (s *Store) Check(ownerID string, names []string) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, name := range names {
+		if _, ok := s.secrets[ownerID][name]; !ok {
+			return &MissingError{Name: name}
+		}
+	}
+	return nil
+}
+
+// reveal decrypts the named secrets of a user
+func (s *Store) reveal(ownerID string, names []string) (map[string]string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	values := make(map[string]string, len(names))
+	for _, name := range names {
+		e, ok := s.secrets[ownerID][name]
+		if !ok {
+			return nil, &MissingError{Name: name}
+		}
+		value, err := s.open(ownerID, name, e.sealed)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// seal encrypts a value, binding it to its owner and name so a sealed
+// value copied to another secret fails to decrypt
+func (s *Store) seal(ownerID, name, value string) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, []byte(value), additionalData(ownerID, name)), nil
+}
+
+// open decrypts a value sealed by seal
+func (s *Store) open(ownerID, name string, sealed []byte) (string, error) {
+	nonceSize := s.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", errors.New("sealed secret is truncated")
+	}
+	value, err := s.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], additionalData(ownerID, name))
+	if err != nil {
+		return "", fmt.Errorf("decrypting secret %q: %w", name, err)
+	}
+	return string(value), nil
+}
+
+func additionalData(ownerID, name string) []byte {
+	return []byte(ownerID + "\x00" + name)
+}
+
+// MissingError reports a secret a job referenced that doesn't exist
+type MissingError struct {
+	Name string
+}
+
+// Error implements the error interface
+func (e *MissingError) Error() string {
+	return fmt.Sprintf("secret %q not found", e.Name)
+}
+
+// Is makes errors.Is(err, ErrNotFound) match
+func (e *MissingError) Is(target error) bool {
+	return target == ErrNotFound
+}
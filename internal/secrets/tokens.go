@@ -0,0 +1,67 @@
+package secrets
+
+import (
+	"errors"
+	"time"
+
+	"siger-api-gateway/internal/storage"
+)
+
+// DefaultTokenTTL bounds how long a secrets token stays valid if its job never starts
+// Tokens are revoked as soon as the attempt ends, this only catches leftovers
+const DefaultTokenTTL = 7 * 24 * time.Hour
+
+// ErrInvalidToken is returned for unknown, used, expired or revoked tokens
+// Deliberately one error so a caller can't probe which case it hit
+var ErrInvalidToken = errors.New("invalid or already used secrets token")
+
+// ErrAttemptInactive is returned when a token's job attempt isn't running
+var ErrAttemptInactive = errors.New("job attempt is no longer active")
+
+// Grant is what a secrets token allows: one read of the named secrets for one job attempt
+type Grant struct {
+	JobID   string
+	Attempt int
+	OwnerID string
+	Names   []string
+}
+
+// IssueToken creates a one-time token for a job attempt's secrets
+func (s *Store) IssueToken(jobID string, attempt int, ownerID string, names []string) (string, error) {
+	return s.tokens.Issue(jobID, Grant{
+		JobID:   jobID,
+		Attempt: attempt,
+		OwnerID: ownerID,
+		Names:   append([]string(nil), names...),
+	})
+}
+
+// Redeem exchanges a token for the decrypted secrets of its job attempt
+// active is asked before the token is used up, so a rejected attempt leaves
+// it in place. The token is used up even if decrypting fails, workers get a
+// new one on retry
+func (s *Store) Redeem(jobID, token string, active func(Grant) bool) (Grant, map[string]string, error) {
+	grant, ok := s.tokens.Lookup(jobID, token)
+	if !ok {
+		return Grant{}, nil, ErrInvalidToken
+	}
+	if !active(grant) {
+		return Grant{}, nil, ErrAttemptInactive
+	}
+	if _, ok := s.tokens.Take(jobID, token); !ok {
+		return Grant{}, nil, ErrInvalidToken // Redeemed concurrently
+	}
+
+	values, err := s.reveal(grant.OwnerID, grant.Names)
+	if err != nil {
+		return Grant{}, nil, err
+	}
+	return grant, values, nil
+}
+
+// Observe revokes a job's tokens once its attempt ends
+// Registered as a job store change handler, so a message replayed from
+// JetStream later can't be used to read the secrets
+func (s *Store) Observe(old *storage.JobInfo, job *storage.JobInfo) {
+	s.tokens.Observe(old, job)
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DatasetStatus represents the upload state of a dataset
+type DatasetStatus string
+
+const (
+	// DatasetStatusUploading indicates chunks are still expected
+	DatasetStatusUploading DatasetStatus = "uploading"
+
+	// DatasetStatusReady indicates the upload finished and the checksum matched
+	DatasetStatusReady DatasetStatus = "ready"
+)
+
+// ErrDatasetNotFound is returned when a dataset doesn't exist
+var ErrDatasetNotFound = errors.New("dataset not found")
+
+// Dataset is an uploaded input for jobs
+// Uploads are resumable: Offset is how many bytes the gateway has, and
+// HashState carries the running SHA-256 between chunks so the checksum
+// is ready the moment the last chunk lands
+type Dataset struct {
+	DatasetID        string        `json:"dataset_id"`
+	Name             string        `json:"name"`
+	Description      string        `json:"description,omitempty"`
+	OwnerID          string        `json:"owner_id"`
+	Project          string        `json:"project,omitempty"` // Share with the project's members
+	Status           DatasetStatus `json:"status"`
+	Size             int64         `json:"size"`   // Declared total length
+	Offset           int64         `json:"offset"` // Bytes received so far
+	ContentType      string        `json:"content_type,omitempty"`
+	Checksum         string        `json:"checksum,omitempty"`          // "sha256:<hex>" once ready
+	ExpectedChecksum string        `json:"expected_checksum,omitempty"` // Optional, verified on completion
+	URI              string        `json:"uri"`
+	CreatedAt        time.Time     `json:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at"`
+	CompletedAt      time.Time     `json:"completed_at,omitempty"`
+
+	HashState []byte `json:"-"`
+}
+
+// DatasetStore keeps dataset metadata, the bytes live in a blob backend
+type DatasetStore struct {
+	mutex    sync.RWMutex
+	datasets map[string]Dataset
+	tokens   *JobTokens[DatasetGrant]
+}
+
+// NewDatasetStore creates an empty dataset store
+func NewDatasetStore() *DatasetStore {
+	return &DatasetStore{
+		datasets: make(map[string]Dataset),
+		tokens:   NewJobTokens[DatasetGrant](DatasetTokenTTL),
+	}
+}
+
+// CreateDataset stores a new dataset waiting for its upload
+func (s *DatasetStore) CreateDataset(dataset Dataset) Dataset {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now().UTC()
+	dataset.Status = DatasetStatusUploading
+	dataset.Offset = 0
+	dataset.CreatedAt = now
+	dataset.UpdatedAt = now
+
+	s.datasets[dataset.DatasetID] = dataset
+	return dataset
+}
+
+// GetDataset returns a dataset by ID
+func (s *DatasetStore) GetDataset(datasetID string) (Dataset, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	dataset, ok := s.datasets[datasetID]
+	if !ok {
+		return Dataset{}, ErrDatasetNotFound
+	}
+	return dataset, nil
+}
+
+// UpdateDataset applies a change to a dataset under the store lock
+// update may reject the change by returning an error, which is passed through
+func (s *DatasetStore) UpdateDataset(datasetID string, update func(dataset *Dataset) error) (Dataset, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	dataset, ok := s.datasets[datasetID]
+	if !ok {
+		return Dataset{}, ErrDatasetNotFound
+	}
+	if err := update(&dataset); err != nil {
+		return s.datasets[datasetID], err
+	}
+
+	dataset.UpdatedAt = time.Now().UTC()
+	s.datasets[datasetID] = dataset
+	return dataset, nil
+}
+
+// DeleteDataset removes a dataset's metadata
+func (s *DatasetStore) DeleteDataset(datasetID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.datasets[datasetID]; !ok {
+		return ErrDatasetNotFound
+	}
+	delete(s.datasets, datasetID)
+	return nil
+}
+
+// ListDatasets returns the datasets accepted by the filter, newest first
+func (s *DatasetStore) ListDatasets(filter func(Dataset) bool) []Dataset {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	datasets := make([]Dataset, 0)
+	for _, dataset := range s.datasets {
+		if filter == nil || filter(dataset) {
+			datasets = append(datasets, dataset)
+		}
+	}
+
+	sort.Slice(datasets, func(i, j int) bool {
+		if !datasets[i].CreatedAt.Equal(datasets[j].CreatedAt) {
+			return datasets[i].CreatedAt.After(datasets[j].CreatedAt)
+		}
+		return datasets[i].DatasetID < datasets[j].DatasetID
+	})
+	return datasets
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+// DatasetTokenTTL bounds how long a dataset token stays valid if its job never starts
+// Tokens are revoked as soon as the attempt ends, this only catches leftovers
+const DatasetTokenTTL = 7 * 24 * time.Hour
+
+// ErrInvalidDatasetToken is returned for unknown, expired or revoked dataset tokens
+var ErrInvalidDatasetToken = errors.New("invalid dataset token")
+
+// DatasetGrant is what a dataset token allows: reading one dataset for one job attempt
+type DatasetGrant struct {
+	DatasetID string
+	JobID     string
+	Attempt   int
+}
+
+// IssueToken creates a token a job attempt's worker downloads its dataset with
+// Unlike secrets tokens it can be used again, so interrupted downloads resume
+func (s *DatasetStore) IssueToken(datasetID, jobID string, attempt int) (string, error) {
+	return s.tokens.Issue(jobID, DatasetGrant{
+		DatasetID: datasetID,
+		JobID:     jobID,
+		Attempt:   attempt,
+	})
+}
+
+// LookupToken returns the grant of a job's dataset token
+func (s *DatasetStore) LookupToken(jobID, token string) (DatasetGrant, error) {
+	grant, ok := s.tokens.Lookup(jobID, token)
+	if !ok {
+		return DatasetGrant{}, ErrInvalidDatasetToken
+	}
+	return grant, nil
+}
+
+// Observe revokes a job's dataset tokens once its attempt ends
+// Registered as a job store change handler, like the secrets store's
+func (s *DatasetStore) Observe(old *JobInfo, job *JobInfo) {
+	s.tokens.Observe(old, job)
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"sort"
+	"time"
+)
+
+// MaxJobArtifacts caps how many artifacts are kept per job
+// Workers should report bundles, not every file of a checkpoint directory
+const MaxJobArtifacts = 1000
+
+// JobArtifact describes an output a worker uploaded for a job
+// The gateway only keeps the descriptor, the bytes stay in blob storage
+type JobArtifact struct {
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	Checksum    string    `json:"checksum,omitempty"` // e.g. "sha256:9f86d0..."
+	URI         string    `json:"uri"`
+	ContentType string    `json:"content_type,omitempty"`
+	Attempt     int       `json:"attempt"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AddJobArtifacts records artifacts reported for a job
+// An artifact with the same name as an existing one replaces it, so a retried
+// attempt overwrites the outputs of the failed one
+// Outputs of an attempt that was already retried are rejected
+func (s *JobStore) AddJobArtifacts(jobID string, attempt int, artifacts []JobArtifact) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job, err := s.loadLocked(jobID)
+	if err != nil {
+		return err
+	}
+	if err := checkAttempt(job, attempt); err != nil {
+		return err
+	}
+	old := job
+
+	byName := make(map[string]JobArtifact, len(job.Artifacts)+len(artifacts))
+	for _, artifact := range job.Artifacts {
+		byName[artifact.Name] = artifact
+	}
+	now := time.Now().UTC()
+	for _, artifact := range artifacts {
+		if artifact.Name == "" || artifact.URI == "" {
+			continue
+		}
+		if artifact.CreatedAt.IsZero() {
+			artifact.CreatedAt = now
+		}
+		artifact.Attempt = job.Attempt
+		byName[artifact.Name] = artifact
+	}
+
+	// Copy so readers holding the old JobInfo never see it change
+	merged := make([]JobArtifact, 0, len(byName))
+	for _, artifact := range byName {
+		merged = append(merged, artifact)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name < merged[j].Name })
+	if len(merged) > MaxJobArtifacts {
+		merged = merged[:MaxJobArtifacts]
+	}
+	job.Artifacts = merged
+
+	s.saveLocked(&job, &old)
+	return nil
+}
+
+// Artifact returns the artifact of a job with the given name
+func (job JobInfo) Artifact(name string) (JobArtifact, bool) {
+	for _, artifact := range job.Artifacts {
+		if artifact.Name == name {
+			return artifact, true
+		}
+	}
+	return JobArtifact{}, false
+}
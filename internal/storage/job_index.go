@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"sort"
+	"time"
+)
+
+// jobKey orders jobs by submission time, with the job ID as a tie breaker
+// so the order is total and usable as a pagination cursor
+type jobKey struct {
+	SubmittedAt time.Time
+	JobID       string
+}
+
+// less reports whether k sorts before other
+func (k jobKey) less(other jobKey) bool {
+	if !k.SubmittedAt.Equal(other.SubmittedAt) {
+		return k.SubmittedAt.Before(other.SubmittedAt)
+	}
+	return k.JobID < other.JobID
+}
+
+// equal reports whether k and other refer to the same position
+// time.Time values must be compared with Equal, not ==
+func (k jobKey) equal(other jobKey) bool {
+	return k.JobID == other.JobID && k.SubmittedAt.Equal(other.SubmittedAt)
+}
+
+// keyOf returns the index key for a job
+func keyOf(job JobInfo) jobKey {
+	return jobKey{SubmittedAt: job.SubmittedAt, JobID: job.JobID}
+}
+
+// jobIndex keeps secondary indexes over the jobs in the store
+// Listing used to range over the whole sync.Map for every request,
+// which got slow once a few users had thousands of jobs each
+// All methods must be called with the store mutex held
+type jobIndex struct {
+	order     []jobKey                        // All jobs, oldest first
+	byUser    map[string][]jobKey             // Jobs per user, oldest first
+	byProject map[string][]jobKey             // Jobs per project, oldest first
+	byStatus  map[JobStatus]map[string]jobKey // Jobs per status
+	byArray   map[string][]jobKey             // Children of each job array, oldest first
+}
+
+// newJobIndex creates an empty job index
+func newJobIndex() *jobIndex {
+	return &jobIndex{
+		byUser:    make(map[string][]jobKey),
+		byProject: make(map[string][]jobKey),
+		byStatus:  make(map[JobStatus]map[string]jobKey),
+		byArray:   make(map[string][]jobKey),
+	}
+}
+
+// add indexes a job that isn't indexed yet
+func (idx *jobIndex) add(job JobInfo) {
+	key := keyOf(job)
+	idx.order = insertKey(idx.order, key)
+	idx.byUser[job.UserID] = insertKey(idx.byUser[job.UserID], key)
+	if job.Project != "" {
+		idx.byProject[job.Project] = insertKey(idx.byProject[job.Project], key)
+	}
+
+	statusSet, ok := idx.byStatus[job.Status]
+	if !ok {
+		statusSet = make(map[string]jobKey)
+		idx.byStatus[job.Status] = statusSet
+	}
+	statusSet[job.JobID] = key
+
+	if job.ArrayID != "" {
+		idx.byArray[job.ArrayID] = insertKey(idx.byArray[job.ArrayID], key)
+	}
+}
+
+// remove drops a job from every index
+func (idx *jobIndex) remove(job JobInfo) {
+	key := keyOf(job)
+	idx.order = removeKey(idx.order, key)
+
+	userKeys := removeKey(idx.byUser[job.UserID], key)
+	if len(userKeys) == 0 {
+		delete(idx.byUser, job.UserID)
+	} else {
+		idx.byUser[job.UserID] = userKeys
+	}
+
+	if job.Project != "" {
+		projectKeys := removeKey(idx.byProject[job.Project], key)
+		if len(projectKeys) == 0 {
+			delete(idx.byProject, job.Project)
+		} else {
+			idx.byProject[job.Project] = projectKeys
+		}
+	}
+
+	if statusSet, ok := idx.byStatus[job.Status]; ok {
+		delete(statusSet, job.JobID)
+		if len(statusSet) == 0 {
+			delete(idx.byStatus, job.Status)
+		}
+	}
+
+	if job.ArrayID != "" {
+		arrayKeys := removeKey(idx.byArray[job.ArrayID], key)
+		if len(arrayKeys) == 0 {
+			delete(idx.byArray, job.ArrayID)
+		} else {
+			idx.byArray[job.ArrayID] = arrayKeys
+		}
+	}
+}
+
+// update re-indexes a job after it changed
+// Only status changes are common, so that path avoids touching the ordered slices
+func (idx *jobIndex) update(old, job JobInfo) {
+	if old.UserID != job.UserID || old.Project != job.Project || old.ArrayID != job.ArrayID || !keyOf(old).equal(keyOf(job)) {
+		idx.remove(old)
+		idx.add(job)
+		return
+	}
+	if old.Status == job.Status {
+		return
+	}
+
+	if statusSet, ok := idx.byStatus[old.Status]; ok {
+		delete(statusSet, old.JobID)
+		if len(statusSet) == 0 {
+			delete(idx.byStatus, old.Status)
+		}
+	}
+	statusSet, ok := idx.byStatus[job.Status]
+	if !ok {
+		statusSet = make(map[string]jobKey)
+		idx.byStatus[job.Status] = statusSet
+	}
+	statusSet[job.JobID] = keyOf(job)
+}
+
+// countStatus returns how many jobs have the given status
+func (idx *jobIndex) countStatus(status JobStatus) int {
+	return len(idx.byStatus[status])
+}
+
+// insertKey inserts key into the sorted slice keys
+// Jobs almost always arrive in submission order so this is usually an append
+func insertKey(keys []jobKey, key jobKey) []jobKey {
+	if n := len(keys); n == 0 || keys[n-1].less(key) {
+		return append(keys, key)
+	}
+
+	i := sort.Search(len(keys), func(i int) bool { return !keys[i].less(key) })
+	if i < len(keys) && keys[i].equal(key) {
+		return keys
+	}
+	keys = append(keys, jobKey{})
+	copy(keys[i+1:], keys[i:])
+	keys[i] = key
+	return keys
+}
+
+// removeKey removes key from the sorted slice keys if present
+func removeKey(keys []jobKey, key jobKey) []jobKey {
+	i := sort.Search(len(keys), func(i int) bool { return !keys[i].less(key) })
+	if i >= len(keys) || !keys[i].equal(key) {
+		return keys
+	}
+	return append(keys[:i], keys[i+1:]...)
+}
+
+// mergeKeys merges sorted key slices into one, dropping duplicates
+// A user's own project jobs are in both their user and project index
+func mergeKeys(lists ...[]jobKey) []jobKey {
+	var merged []jobKey
+	for _, keys := range lists {
+		out := make([]jobKey, 0, len(merged)+len(keys))
+		i, j := 0, 0
+		for i < len(merged) && j < len(keys) {
+			switch {
+			case merged[i].equal(keys[j]):
+				out = append(out, merged[i])
+				i++
+				j++
+			case merged[i].less(keys[j]):
+				out = append(out, merged[i])
+				i++
+			default:
+				out = append(out, keys[j])
+				j++
+			}
+		}
+		out = append(out, merged[i:]...)
+		merged = append(out, keys[j:]...)
+	}
+	return merged
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxMessage is a job message waiting to be published to JetStream
+// Submissions store it together with their jobs under one lock, so a job is
+// never queued without the message that starts it. The relay publishes it
+// and removes it, retrying while NATS is unreachable
+type OutboxMessage struct {
+	ID            string          `json:"id"`
+	JobID         string          `json:"job_id"`
+	Attempt       int             `json:"attempt"` // Job attempt the message starts
+	Subject       string          `json:"subject"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Attempts      int             `json:"attempts"` // Failed publish attempts so far
+	LastError     string          `json:"last_error,omitempty"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+}
+
+// OutboxStats summarizes the messages still waiting to be published
+type OutboxStats struct {
+	Pending   int           // Messages not yet published
+	Stuck     int           // Messages older than the stuck threshold
+	Failing   int           // Messages that failed at least one publish
+	OldestAge time.Duration // Age of the oldest message, 0 when empty
+}
+
+// AddJobsWithMessages adds jobs and their outbox messages under a single lock
+// Jobs without a message (held ones) are simply stored
+func (s *JobStore) AddJobsWithMessages(jobs []JobInfo, messages []OutboxMessage) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, jobInfo := range jobs {
+		s.addLocked(jobInfo)
+	}
+	s.outbox = append(s.outbox, messages...)
+}
+
+// EnqueueMessage adds a message for a job that's already stored, e.g. a retry
+func (s *JobStore) EnqueueMessage(message OutboxMessage) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.outbox = append(s.outbox, message)
+}
+
+// DueMessages returns the messages ready to be published, oldest first
+func (s *JobStore) DueMessages(now time.Time) []OutboxMessage {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var due []OutboxMessage
+	for _, message := range s.outbox {
+		if !message.NextAttemptAt.After(now) {
+			due = append(due, message)
+		}
+	}
+	return due
+}
+
+// RemoveMessage drops a message once it's published or no longer needed
+func (s *JobStore) RemoveMessage(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, message := range s.outbox {
+		if message.ID == id {
+			s.outbox = append(s.outbox[:i], s.outbox[i+1:]...)
+			return
+		}
+	}
+}
+
+// MarkMessageFailed records a failed publish and when to try again
+func (s *JobStore) MarkMessageFailed(id string, lastError string, nextAttemptAt time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i := range s.outbox {
+		if s.outbox[i].ID == id {
+			s.outbox[i].Attempts++
+			s.outbox[i].LastError = lastError
+			s.outbox[i].NextAttemptAt = nextAttemptAt
+			return
+		}
+	}
+}
+
+// OutboxStats counts the waiting messages, stuck ones are older than stuckAfter
+func (s *JobStore) OutboxStats(now time.Time, stuckAfter time.Duration) OutboxStats {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	stats := OutboxStats{Pending: len(s.outbox)}
+	for _, message := range s.outbox {
+		age := now.Sub(message.CreatedAt)
+		if age > stats.OldestAge {
+			stats.OldestAge = age
+		}
+		if age >= stuckAfter {
+			stats.Stuck++
+		}
+		if message.Attempts > 0 {
+			stats.Failing++
+		}
+	}
+	return stats
+}
@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query limits
+// 50 is plenty for a dashboard page, 500 keeps a single response bounded
+const (
+	DefaultQueryLimit = 50
+	MaxQueryLimit     = 500
+)
+
+// ErrInvalidCursor is returned when a pagination cursor can't be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// JobQuery describes a filtered, sorted and paginated job listing
+// Zero values mean "no filter" so callers only set what they need
+// Results are ordered by submission time, newest first unless Ascending
+type JobQuery struct {
+	UserID          string   // Only jobs of this user, empty for all users
+	Projects        []string // With UserID, jobs in these projects match as well
+	Project         string
+	ArrayID         string
+	Statuses        []JobStatus // Any of these statuses
+	Type            string
+	GPUType         string
+	Tag             string
+	NamePrefix      string    // Case-insensitive
+	SubmittedFrom   time.Time // Inclusive
+	SubmittedBefore time.Time // Exclusive
+	Ascending       bool
+	Limit           int
+	Cursor          string // NextCursor from the previous page
+}
+
+// JobPage is a single page of query results
+type JobPage struct {
+	Jobs       []JobInfo
+	Total      int    // Number of jobs matching the filters across all pages
+	NextCursor string // Empty on the last page
+}
+
+// QueryJobs returns a page of jobs matching the query
+// Candidates come from the per-user or per-status index and the submission
+// time range is narrowed with binary search, so we never walk the whole store
+func (s *JobStore) QueryJobs(q JobQuery) (JobPage, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultQueryLimit
+	}
+	if limit > MaxQueryLimit {
+		limit = MaxQueryLimit
+	}
+
+	var cursor *jobKey
+	if q.Cursor != "" {
+		key, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return JobPage{}, err
+		}
+		cursor = &key
+	}
+
+	statuses := make(map[JobStatus]bool, len(q.Statuses))
+	for _, status := range q.Statuses {
+		statuses[status] = true
+	}
+	namePrefix := strings.ToLower(q.NamePrefix)
+	projects := make(map[string]bool, len(q.Projects))
+	for _, project := range q.Projects {
+		projects[project] = true
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	keys := s.candidateKeysLocked(q.UserID, q.Projects, q.Project, q.ArrayID, q.Statuses)
+
+	// Narrow down to the submission time range
+	lo, hi := 0, len(keys)
+	if !q.SubmittedFrom.IsZero() {
+		lo = sort.Search(len(keys), func(i int) bool { return !keys[i].SubmittedAt.Before(q.SubmittedFrom) })
+	}
+	if !q.SubmittedBefore.IsZero() {
+		hi = sort.Search(len(keys), func(i int) bool { return !keys[i].SubmittedAt.Before(q.SubmittedBefore) })
+	}
+	if lo > hi {
+		lo = hi
+	}
+	keys = keys[lo:hi]
+
+	page := JobPage{Jobs: make([]JobInfo, 0, limit)}
+	var lastKey jobKey
+	hasMore := false
+
+	for i := range keys {
+		key := keys[i]
+		if !q.Ascending {
+			key = keys[len(keys)-1-i]
+		}
+
+		job, err := s.loadLocked(key.JobID)
+		if err != nil {
+			continue
+		}
+		if q.UserID != "" && job.UserID != q.UserID && !projects[job.Project] {
+			continue
+		}
+		if len(statuses) > 0 && !statuses[job.Status] {
+			continue
+		}
+		if q.Project != "" && job.Project != q.Project {
+			continue
+		}
+		if q.ArrayID != "" && job.ArrayID != q.ArrayID {
+			continue
+		}
+		if q.Type != "" && job.Type != q.Type {
+			continue
+		}
+		if q.GPUType != "" && !strings.EqualFold(job.GPUType, q.GPUType) {
+			continue
+		}
+		if q.Tag != "" && !hasTag(job.Tags, q.Tag) {
+			continue
+		}
+		if namePrefix != "" && !strings.HasPrefix(strings.ToLower(job.Name), namePrefix) {
+			continue
+		}
+
+		page.Total++
+
+		// Skip everything up to and including the cursor position
+		if cursor != nil {
+			if q.Ascending && !cursor.less(key) {
+				continue
+			}
+			if !q.Ascending && !key.less(*cursor) {
+				continue
+			}
+		}
+
+		if len(page.Jobs) < limit {
+			page.Jobs = append(page.Jobs, job)
+			lastKey = key
+		} else {
+			hasMore = true
+		}
+	}
+
+	if hasMore {
+		page.NextCursor = encodeCursor(lastKey)
+	}
+
+	return page, nil
+}
+
+// candidateKeysLocked picks the smallest ordered key set that can answer the query
+// Project jobs of other users aren't in the caller's user index, so those of
+// the caller's projects are merged in
+func (s *JobStore) candidateKeysLocked(userID string, projects []string, project, arrayID string, statuses []JobStatus) []jobKey {
+	if arrayID != "" {
+		return s.index.byArray[arrayID]
+	}
+	if project != "" {
+		return s.index.byProject[project]
+	}
+	if userID != "" {
+		if len(projects) == 0 {
+			return s.index.byUser[userID]
+		}
+		lists := [][]jobKey{s.index.byUser[userID]}
+		for _, p := range projects {
+			lists = append(lists, s.index.byProject[p])
+		}
+		return mergeKeys(lists...)
+	}
+
+	if len(statuses) > 0 {
+		size := 0
+		for _, status := range statuses {
+			size += s.index.countStatus(status)
+		}
+		if size < len(s.index.order) {
+			keys := make([]jobKey, 0, size)
+			seen := make(map[JobStatus]bool, len(statuses))
+			for _, status := range statuses {
+				if seen[status] {
+					continue
+				}
+				seen[status] = true
+				for _, key := range s.index.byStatus[status] {
+					keys = append(keys, key)
+				}
+			}
+			sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+			return keys
+		}
+	}
+
+	return s.index.order
+}
+
+// hasTag reports whether tags contains tag (case-insensitive)
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeCursor turns a key into an opaque cursor string
+func encodeCursor(key jobKey) string {
+	raw := strconv.FormatInt(key.SubmittedAt.UnixNano(), 10) + ":" + key.JobID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a cursor produced by encodeCursor
+func decodeCursor(cursor string) (jobKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return jobKey{}, ErrInvalidCursor
+	}
+
+	nanos, jobID, ok := strings.Cut(string(raw), ":")
+	if !ok || jobID == "" {
+		return jobKey{}, ErrInvalidCursor
+	}
+
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return jobKey{}, ErrInvalidCursor
+	}
+
+	return jobKey{SubmittedAt: time.Unix(0, n).UTC(), JobID: jobID}, nil
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidTransition is returned when a status change isn't allowed by the job lifecycle
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// MaxJobHistory caps the lifecycle history kept per job
+// A job normally has a handful of transitions, this only guards against flapping workers
+const MaxJobHistory = 100
+
+// jobTransitions lists the statuses a job may move to from each status
+// Forward skips are allowed because jobs.status updates are handled concurrently
+// and a fast job's "completed" can overtake its "processing"
+// Terminal statuses have no way out, so late updates can't resurrect a job
+var jobTransitions = map[JobStatus][]JobStatus{
+	JobStatusQueued: {
+		JobStatusScheduled, JobStatusProcessing, JobStatusPreempted, JobStatusPaused,
+		JobStatusCompleted, JobStatusFailed, JobStatusCancelling, JobStatusCancelled, JobStatusExpired,
+	},
+	JobStatusScheduled: {
+		JobStatusQueued, JobStatusProcessing, JobStatusPreempted, JobStatusPaused,
+		JobStatusCompleted, JobStatusFailed, JobStatusCancelling, JobStatusCancelled, JobStatusExpired,
+	},
+	JobStatusProcessing: {
+		JobStatusPreempted, JobStatusPaused, JobStatusCompleted, JobStatusFailed, JobStatusCancelling, JobStatusCancelled,
+	},
+	JobStatusPreempted: {
+		JobStatusQueued, JobStatusFailed, JobStatusCancelled,
+	},
+	JobStatusPaused: {
+		JobStatusQueued, JobStatusFailed, JobStatusCancelled,
+	},
+	JobStatusCancelling: {
+		JobStatusCancelled, JobStatusCompleted, JobStatusFailed,
+	},
+}
+
+// requeueableStatuses are the statuses a job can be requeued from for another attempt
+var requeueableStatuses = map[JobStatus]bool{
+	JobStatusFailed:    true,
+	JobStatusPreempted: true,
+}
+
+// JobTransition is a single entry in a job's lifecycle history
+type JobTransition struct {
+	From      JobStatus `json:"from,omitempty"` // Empty for the submission
+	To        JobStatus `json:"to"`
+	Message   string    `json:"message,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Attempt   int       `json:"attempt"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ParseJobStatus converts a status string from the wire into a JobStatus
+func ParseJobStatus(status string) (JobStatus, bool) {
+	s := JobStatus(status)
+	switch s {
+	case JobStatusQueued, JobStatusScheduled, JobStatusProcessing, JobStatusPreempted, JobStatusPaused,
+		JobStatusCancelling, JobStatusCompleted, JobStatusFailed, JobStatusCancelled, JobStatusExpired:
+		return s, true
+	}
+	return "", false
+}
+
+// EndsAttempt reports whether the current attempt is over in this status
+// Preempted and paused jobs gave their GPUs back even though the job isn't finished
+func (s JobStatus) EndsAttempt() bool {
+	return s.IsTerminal() || s == JobStatusPreempted || s == JobStatusPaused
+}
+
+// CanTransition reports whether a job may move from one status to another
+// Repeating the current status is always allowed so workers can refresh
+// the message or progress without changing state
+func CanTransition(from, to JobStatus) bool {
+	if from == to {
+		return true
+	}
+	for _, next := range jobTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionError wraps ErrInvalidTransition with the statuses involved
+func transitionError(from, to JobStatus) error {
+	return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+}
+
+// appendHistory returns the job's history with a new transition appended
+// The slice is copied so readers holding an older JobInfo never see it change
+func appendHistory(job JobInfo, entry JobTransition) []JobTransition {
+	history := job.History
+	if len(history) >= MaxJobHistory {
+		history = history[len(history)-MaxJobHistory+1:]
+	}
+
+	updated := make([]JobTransition, len(history), len(history)+1)
+	copy(updated, history)
+	return append(updated, entry)
+}
+
+// checkAttempt rejects worker updates about an attempt that was already retried
+// A late "failed" from the previous attempt must not end the new one
+func checkAttempt(job JobInfo, attempt int) error {
+	if attempt != 0 && attempt != job.Attempt {
+		return fmt.Errorf("%w: got %d, job is on %d", ErrStaleAttempt, attempt, job.Attempt)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package storage
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from JobStatus
+		to   JobStatus
+		want bool
+	}{
+		{JobStatusQueued, JobStatusScheduled, true},
+		{JobStatusQueued, JobStatusCompleted, true}, // A fast job's completed can overtake processing
+		{JobStatusQueued, JobStatusExpired, true},
+		{JobStatusScheduled, JobStatusQueued, true},
+		{JobStatusProcessing, JobStatusQueued, false},
+		{JobStatusProcessing, JobStatusExpired, false},
+		{JobStatusProcessing, JobStatusPreempted, true},
+		{JobStatusPreempted, JobStatusQueued, true},
+		{JobStatusPreempted, JobStatusProcessing, false},
+		{JobStatusPaused, JobStatusQueued, true},
+		{JobStatusPaused, JobStatusProcessing, false},
+		{JobStatusCancelling, JobStatusCancelled, true},
+		{JobStatusCancelling, JobStatusCompleted, true},
+		{JobStatusCancelling, JobStatusQueued, false},
+		{JobStatusProcessing, JobStatusProcessing, true}, // Progress refresh
+		{JobStatusCompleted, JobStatusCompleted, true},
+		{JobStatusCompleted, JobStatusProcessing, false},
+		{JobStatusFailed, JobStatusQueued, false}, // Retries go through RequeueJob
+		{JobStatusCancelled, JobStatusQueued, false},
+		{JobStatusExpired, JobStatusQueued, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			if got := CanTransition(tt.from, tt.to); got != tt.want {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
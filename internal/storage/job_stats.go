@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Queue statistics defaults
+// 50 recent starts within a day is enough signal without chasing stale history
+const (
+	DefaultQueueSampleSize = 50
+	DefaultQueueLookback   = 24 * time.Hour
+)
+
+// QueueStats describes the queue for a GPU type
+type QueueStats struct {
+	QueuedJobs int
+	QueuedGPUs int
+
+	// Queue-to-start times of the most recently submitted jobs that started, newest first
+	RecentQueueTimes []time.Duration
+}
+
+// MedianQueueTime returns the median of the recent queue times, zero without samples
+func (q QueueStats) MedianQueueTime() time.Duration {
+	if len(q.RecentQueueTimes) == 0 {
+		return 0
+	}
+
+	sorted := append([]time.Duration(nil), q.RecentQueueTimes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// QueueStats returns the current queue depth and recent queue times for a GPU type
+// An empty gpuType or "any" covers all GPU types
+// Queued jobs come from the status index, recent starts from walking the
+// submission order backwards until the lookback window is exhausted
+func (s *JobStore) QueueStats(gpuType string, sampleSize int, lookback time.Duration) QueueStats {
+	if sampleSize <= 0 {
+		sampleSize = DefaultQueueSampleSize
+	}
+	if lookback <= 0 {
+		lookback = DefaultQueueLookback
+	}
+	matches := func(job JobInfo) bool {
+		return gpuType == "" || strings.EqualFold(gpuType, "any") || strings.EqualFold(job.GPUType, gpuType)
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var stats QueueStats
+	for jobID := range s.index.byStatus[JobStatusQueued] {
+		job, err := s.loadLocked(jobID)
+		if err != nil || !matches(job) {
+			continue
+		}
+		stats.QueuedJobs++
+		stats.QueuedGPUs += job.GPUCount
+	}
+
+	cutoff := time.Now().UTC().Add(-lookback)
+	for i := len(s.index.order) - 1; i >= 0 && len(stats.RecentQueueTimes) < sampleSize; i-- {
+		key := s.index.order[i]
+		if key.SubmittedAt.Before(cutoff) {
+			break
+		}
+
+		job, err := s.loadLocked(key.JobID)
+		if err != nil || job.StartedAt.IsZero() || !matches(job) {
+			continue
+		}
+		if queueTime := job.StartedAt.Sub(job.SubmittedAt); queueTime >= 0 {
+			stats.RecentQueueTimes = append(stats.RecentQueueTimes, queueTime)
+		}
+	}
+
+	return stats
+}
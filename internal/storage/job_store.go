@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"encoding/json"
 	"errors"
+	"sort"
 	"sync"
 	"time"
 )
@@ -13,9 +15,21 @@ const (
 	// JobStatusQueued indicates the job is queued for processing
 	JobStatusQueued JobStatus = "queued"
 
+	// JobStatusScheduled indicates the job was assigned to a worker but hasn't started yet
+	JobStatusScheduled JobStatus = "scheduled"
+
 	// JobStatusProcessing indicates the job is currently processing
 	JobStatusProcessing JobStatus = "processing"
 
+	// JobStatusPreempted indicates the job lost its GPUs and waits to be requeued
+	JobStatusPreempted JobStatus = "preempted"
+
+	// JobStatusPaused indicates the job was stopped on request and waits to be resumed
+	JobStatusPaused JobStatus = "paused"
+
+	// JobStatusCancelling indicates cancellation was requested and the worker hasn't confirmed it
+	JobStatusCancelling JobStatus = "cancelling"
+
 	// JobStatusCompleted indicates the job has completed successfully
 	JobStatusCompleted JobStatus = "completed"
 
@@ -24,11 +38,33 @@ const (
 
 	// JobStatusCancelled indicates the job was cancelled
 	JobStatusCancelled JobStatus = "cancelled"
+
+	// JobStatusExpired indicates the job missed its deadline before it ever started
+	JobStatusExpired JobStatus = "expired"
 )
 
+// IsTerminal reports whether a job in this status will not change anymore
+func (s JobStatus) IsTerminal() bool {
+	switch s {
+	case JobStatusCompleted, JobStatusFailed, JobStatusCancelled, JobStatusExpired:
+		return true
+	}
+	return false
+}
+
+// ChangeHandler is called whenever a job is added, updated or removed
+// old is nil for new jobs and job is nil for deleted ones
+// Handlers run under the store lock - they must be fast and must not call back into the store
+type ChangeHandler func(old *JobInfo, job *JobInfo)
+
 // Common errors
 var (
 	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job already finished")
+
+	ErrJobNotQueued     = errors.New("job is no longer queued")
+	ErrRevisionMismatch = errors.New("job was modified concurrently")
+	ErrStaleAttempt     = errors.New("update is for an earlier attempt")
 )
 
 // JobInfo represents a job's information and status
@@ -37,6 +73,7 @@ var (
 type JobInfo struct {
 	JobID       string    `json:"job_id"`
 	UserID      string    `json:"user_id"`
+	Project     string    `json:"project,omitempty"`
 	Type        string    `json:"type"`
 	Name        string    `json:"name"`
 	Status      JobStatus `json:"status"`
@@ -44,6 +81,76 @@ type JobInfo struct {
 	StartedAt   time.Time `json:"started_at,omitempty"`
 	CompletedAt time.Time `json:"completed_at,omitempty"`
 	Message     string    `json:"message,omitempty"`
+
+	// Full submitted request - kept so job details don't need the original message
+	Description string   `json:"description,omitempty"`
+	GPUType     string   `json:"gpu_type,omitempty"`
+	GPUCount    int      `json:"gpu_count,omitempty"`
+	Priority    int      `json:"priority,omitempty"`
+	Params      any      `json:"params,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+
+	// Latest progress reported by the worker (0-100 percent)
+	Progress          float64   `json:"progress,omitempty"`
+	ProgressUpdatedAt time.Time `json:"progress_updated_at,omitempty"`
+
+	// Retry bookkeeping - Attempt starts at 1 and Attempts holds the
+	// finished attempts that were retried. Payload is the original message
+	// published to workers so we can republish it on retry
+	RetryPolicy *RetryPolicy    `json:"retry_policy,omitempty"`
+	Attempt     int             `json:"attempt"`
+	Attempts    []JobAttempt    `json:"attempts,omitempty"`
+	Payload     json.RawMessage `json:"-"`
+
+	// Job arrays - children of a parameter sweep share the array ID
+	// and ArrayIndex is their position in the expanded grid
+	ArrayID    string `json:"array_id,omitempty"`
+	ArrayIndex int    `json:"array_index,omitempty"`
+
+	// Template the job was created from, with the version used
+	TemplateID      string `json:"template_id,omitempty"`
+	TemplateVersion int    `json:"template_version,omitempty"`
+
+	// Optional limits enforced by the gateway watchdog - MaxRuntime is a
+	// duration like "12h" counted from StartedAt, Deadline is wall clock
+	MaxRuntime string    `json:"max_runtime,omitempty"`
+	Deadline   time.Time `json:"deadline,omitempty"`
+
+	// Uploaded dataset the job reads
+	DatasetID string `json:"dataset_id,omitempty"`
+
+	// Names of the owner's secrets handed to the job, the values stay in the secret store
+	Secrets []string `json:"secrets,omitempty"`
+
+	// Job this one was resubmitted from, if any
+	ResubmittedFrom string `json:"resubmitted_from,omitempty"`
+
+	// Lifecycle history, oldest first
+	History []JobTransition `json:"history,omitempty"`
+
+	// Latest checkpoint reported by the worker, e.g. an object store URI
+	// Resumed attempts are told to start from it
+	Checkpoint   string    `json:"checkpoint,omitempty"`
+	CheckpointAt time.Time `json:"checkpoint_at,omitempty"`
+
+	// Outputs reported by workers, by name
+	Artifacts []JobArtifact `json:"artifacts,omitempty"`
+
+	// Bumped on every change, used for optimistic concurrency on updates
+	Revision int `json:"revision"`
+
+	// Held by the gateway instead of being published while the cluster is
+	// saturated, cleared when the job is released or leaves the queue
+	Held bool `json:"held,omitempty"`
+
+	// Preemptible jobs may be stopped for higher priority work. PreemptedBy
+	// is the job that displaced this one, until this one is requeued.
+	// PreemptionConfirmed is set once the worker reported it stopped.
+	// MayPreempt records whether the submitter was allowed to preempt others
+	Preemptible         bool   `json:"preemptible,omitempty"`
+	PreemptedBy         string `json:"preempted_by,omitempty"`
+	PreemptionConfirmed bool   `json:"preemption_confirmed,omitempty"`
+	MayPreempt          bool   `json:"may_preempt,omitempty"`
 }
 
 // JobStore provides storage functionality for job information
@@ -52,7 +159,11 @@ type JobInfo struct {
 type JobStore struct {
 	jobs    sync.Map
 	mutex   sync.RWMutex
-	maxJobs int // Maximum number of jobs to keep in memory
+	index   *jobIndex       // Secondary indexes for listing, guarded by mutex
+	maxJobs int             // Maximum number of jobs to keep in memory
+	outbox  []OutboxMessage // Job messages not yet published, oldest first, guarded by mutex
+
+	changeHandlers []ChangeHandler
 }
 
 // NewJobStore creates a new job store
@@ -62,6 +173,7 @@ func NewJobStore(maxJobs int) *JobStore {
 	}
 
 	store := &JobStore{
+		index:   newJobIndex(),
 		maxJobs: maxJobs,
 	}
 
@@ -73,8 +185,28 @@ func NewJobStore(maxJobs int) *JobStore {
 	return store
 }
 
+// OnChange registers a handler called for every job change
+// Register handlers at startup, before jobs are added
+func (s *JobStore) OnChange(handler ChangeHandler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.changeHandlers = append(s.changeHandlers, handler)
+}
+
 // AddJob adds a new job to the store
 func (s *JobStore) AddJob(jobInfo JobInfo) {
+	s.AddJobs([]JobInfo{jobInfo})
+}
+
+// AddJobs adds several jobs under a single lock
+// Readers see either none or all of them, which batch submissions rely on
+func (s *JobStore) AddJobs(jobs []JobInfo) {
+	s.AddJobsWithMessages(jobs, nil)
+}
+
+// addLocked stores a single job, filling in defaults
+func (s *JobStore) addLocked(jobInfo JobInfo) {
 	// Ensure the required fields are set
 	if jobInfo.JobID == "" {
 		return
@@ -88,8 +220,25 @@ func (s *JobStore) AddJob(jobInfo JobInfo) {
 		jobInfo.SubmittedAt = time.Now().UTC()
 	}
 
-	// Store the job
-	s.jobs.Store(jobInfo.JobID, jobInfo)
+	if jobInfo.Attempt == 0 {
+		jobInfo.Attempt = 1
+	}
+
+	// Store the job - re-adding an existing job replaces it
+	old, err := s.loadLocked(jobInfo.JobID)
+	if err != nil {
+		if len(jobInfo.History) == 0 {
+			jobInfo.History = appendHistory(jobInfo, JobTransition{
+				To:        jobInfo.Status,
+				Message:   jobInfo.Message,
+				Attempt:   jobInfo.Attempt,
+				Timestamp: jobInfo.SubmittedAt,
+			})
+		}
+		s.saveLocked(&jobInfo, nil)
+		return
+	}
+	s.saveLocked(&jobInfo, &old)
 }
 
 // GetJob retrieves a job from the store
@@ -108,20 +257,55 @@ func (s *JobStore) GetJob(jobID string) (JobInfo, error) {
 }
 
 // UpdateJobStatus updates the status of a job
-// Using fine-grained locking only for specific fields
-// This is much more efficient than locking the whole map - virjilakrum
+// Illegal transitions are rejected with ErrInvalidTransition
 func (s *JobStore) UpdateJobStatus(jobID string, status JobStatus, message string) error {
+	_, err := s.TransitionJob(jobID, status, message, "")
+	return err
+}
+
+// TransitionJob moves a job to a new status if the lifecycle allows it
+// and records the change in the job's history
+func (s *JobStore) TransitionJob(jobID string, status JobStatus, message string, reason string) (JobInfo, error) {
+	return s.ReportStatus(jobID, StatusReport{Status: status, Message: message, Reason: reason})
+}
+
+// StatusReport is a status change as a worker reports it
+// Zero times leave the gateway's own receive time in place. Attempt 0
+// means the reporter didn't say and is taken as the current attempt
+type StatusReport struct {
+	Status    JobStatus
+	Message   string
+	Reason    string
+	Attempt   int
+	StartedAt time.Time
+	EndedAt   time.Time
+}
+
+// ReportStatus is TransitionJob with the worker's start and end times
+// The times are set in the same change as the status, so change handlers
+// like the meter book the attempt with the worker's clock, not ours
+func (s *JobStore) ReportStatus(jobID string, report StatusReport) (JobInfo, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	value, ok := s.jobs.Load(jobID)
-	if !ok {
-		return ErrJobNotFound
+	job, err := s.loadLocked(jobID)
+	if err != nil {
+		return JobInfo{}, err
+	}
+	if err := checkAttempt(job, report.Attempt); err != nil {
+		return job, err
+	}
+	status, message, reason := report.Status, report.Message, report.Reason
+	if !CanTransition(job.Status, status) {
+		return job, transitionError(job.Status, status)
 	}
+	old := job
 
-	job, ok := value.(JobInfo)
-	if !ok {
-		return errors.New("invalid job data")
+	// A preempted or paused job going back to the queue starts a new attempt
+	if job.Status.EndsAttempt() && status == JobStatusQueued {
+		job = s.requeueLocked(job, reason, message)
+		s.saveLocked(&job, &old)
+		return job, nil
 	}
 
 	// Update status and timestamps based on the new status
@@ -133,60 +317,448 @@ func (s *JobStore) UpdateJobStatus(jobID string, status JobStatus, message strin
 		if job.StartedAt.IsZero() {
 			job.StartedAt = time.Now().UTC()
 		}
-	case JobStatusCompleted, JobStatusFailed, JobStatusCancelled:
+	case JobStatusCompleted, JobStatusFailed, JobStatusCancelled, JobStatusExpired, JobStatusPreempted, JobStatusPaused:
 		job.CompletedAt = time.Now().UTC()
 	}
 
+	// Worker-reported timestamps are more accurate than our receive time
+	if !report.StartedAt.IsZero() {
+		job.StartedAt = report.StartedAt.UTC()
+	}
+	if !report.EndedAt.IsZero() && job.Status.EndsAttempt() {
+		job.CompletedAt = report.EndedAt.UTC()
+	}
+
+	if old.Status != status {
+		job.History = appendHistory(job, JobTransition{
+			From:      old.Status,
+			To:        status,
+			Message:   message,
+			Reason:    reason,
+			Attempt:   job.Attempt,
+			Timestamp: time.Now().UTC(),
+		})
+	}
+
 	// Save the updated job
-	s.jobs.Store(jobID, job)
-	return nil
+	s.saveLocked(&job, &old)
+	return job, nil
 }
 
-// ListJobsByUser lists all jobs for a specific user
-// Using a memory-efficient approach that doesn't require copying the whole map
-// Especially important when we have thousands of jobs - virjilakrum
-func (s *JobStore) ListJobsByUser(userID string) []JobInfo {
-	var userJobs []JobInfo
+// PreemptJob marks a preemptible job preempted to make room for another job
+// Fails with ErrInvalidTransition unless the job is scheduled or running -
+// a victim that finished in the meantime is simply left alone
+func (s *JobStore) PreemptJob(jobID string, preemptorID string, message string) (JobInfo, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	s.jobs.Range(func(key, value interface{}) bool {
-		job, ok := value.(JobInfo)
-		if ok && job.UserID == userID {
-			userJobs = append(userJobs, job)
-		}
-		return true
+	job, err := s.loadLocked(jobID)
+	if err != nil {
+		return JobInfo{}, err
+	}
+	if !job.Preemptible || (job.Status != JobStatusScheduled && job.Status != JobStatusProcessing) {
+		return job, transitionError(job.Status, JobStatusPreempted)
+	}
+	old := job
+
+	now := time.Now().UTC()
+	job.Status = JobStatusPreempted
+	job.Message = message
+	job.PreemptedBy = preemptorID
+	job.CompletedAt = now
+	job.History = appendHistory(job, JobTransition{
+		From:      old.Status,
+		To:        JobStatusPreempted,
+		Message:   message,
+		Reason:    FailureReasonPreempted,
+		Attempt:   job.Attempt,
+		Timestamp: now,
 	})
 
-	return userJobs
+	s.saveLocked(&job, &old)
+	return job, nil
+}
+
+// ConfirmPreemption records that the worker of a preempted job stopped it
+// Only applies to jobs the gateway preempted, on the attempt it preempted
+func (s *JobStore) ConfirmPreemption(jobID string, attempt int) (JobInfo, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job, err := s.loadLocked(jobID)
+	if err != nil {
+		return JobInfo{}, err
+	}
+	if err := checkAttempt(job, attempt); err != nil {
+		return job, err
+	}
+	if job.Status != JobStatusPreempted || job.PreemptedBy == "" || job.PreemptionConfirmed {
+		return job, nil
+	}
+	old := job
+
+	job.PreemptionConfirmed = true
+	s.saveLocked(&job, &old)
+	return job, nil
 }
 
-// ListJobsByStatus lists all jobs with a specific status
-func (s *JobStore) ListJobsByStatus(status JobStatus) []JobInfo {
-	var statusJobs []JobInfo
+// CancelJob requests cancellation of a job unless it has already finished
+// Jobs that never reached a worker are cancelled right away, the rest go to
+// cancelling until the worker confirms with a cancelled status update
+// Check and update happen under one lock so a job completing at the same
+// moment can't be flipped back to cancelled
+// The reason is recorded in the history, e.g. "timeout" for the watchdog
+func (s *JobStore) CancelJob(jobID string, message string, reason string) (JobInfo, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	s.jobs.Range(func(key, value interface{}) bool {
-		job, ok := value.(JobInfo)
-		if ok && job.Status == status {
-			statusJobs = append(statusJobs, job)
-		}
-		return true
+	job, err := s.loadLocked(jobID)
+	if err != nil {
+		return JobInfo{}, err
+	}
+	if job.Status.IsTerminal() {
+		return job, ErrJobFinished
+	}
+	old := job
+
+	status := JobStatusCancelling
+	switch job.Status {
+	case JobStatusQueued, JobStatusPreempted, JobStatusPaused:
+		status = JobStatusCancelled
+		job.CompletedAt = time.Now().UTC()
+	}
+
+	job.Status = status
+	job.Message = message
+	if old.Status != status {
+		job.History = appendHistory(job, JobTransition{
+			From:      old.Status,
+			To:        status,
+			Message:   message,
+			Reason:    reason,
+			Attempt:   job.Attempt,
+			Timestamp: time.Now().UTC(),
+		})
+	}
+
+	s.saveLocked(&job, &old)
+	return job, nil
+}
+
+// UpdateQueuedJob applies a change to a job that is still queued
+// The status and revision are checked under the same lock as the change, so it
+// can't race a worker picking the job up. A zero revision skips the revision check
+// update may reject the change by returning an error, which is passed through
+func (s *JobStore) UpdateQueuedJob(jobID string, revision int, update func(job *JobInfo) error) (JobInfo, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job, err := s.loadLocked(jobID)
+	if err != nil {
+		return JobInfo{}, err
+	}
+	if revision != 0 && job.Revision != revision {
+		return job, ErrRevisionMismatch
+	}
+	if job.Status != JobStatusQueued {
+		return job, ErrJobNotQueued
+	}
+	old := job
+
+	if err := update(&job); err != nil {
+		return old, err
+	}
+
+	s.saveLocked(&job, &old)
+	return job, nil
+}
+
+// UpdateJobCheckpoint records the latest checkpoint reported for a job
+// Attempt 0 skips the check that the checkpoint is from the current attempt
+func (s *JobStore) UpdateJobCheckpoint(jobID string, attempt int, checkpoint string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job, err := s.loadLocked(jobID)
+	if err != nil {
+		return err
+	}
+	if err := checkAttempt(job, attempt); err != nil {
+		return err
+	}
+	old := job
+
+	job.Checkpoint = checkpoint
+	job.CheckpointAt = time.Now().UTC()
+
+	s.saveLocked(&job, &old)
+	return nil
+}
+
+// UpdateJobProgress records the latest progress reported for a job
+// Progress is clamped to 0-100 since workers occasionally report fractions as 0-1 or overshoot
+func (s *JobStore) UpdateJobProgress(jobID string, attempt int, progress float64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job, err := s.loadLocked(jobID)
+	if err != nil {
+		return err
+	}
+	if err := checkAttempt(job, attempt); err != nil {
+		return err
+	}
+	old := job
+
+	if progress < 0 {
+		progress = 0
+	} else if progress > 100 {
+		progress = 100
+	}
+
+	job.Progress = progress
+	job.ProgressUpdatedAt = time.Now().UTC()
+
+	s.saveLocked(&job, &old)
+	return nil
+}
+
+// RequeueJob records the current attempt in the job's history and puts the job
+// back into the queued state for the next attempt
+// Only failed or preempted jobs can be requeued
+// Done under the store lock so a racing status update can't interleave
+func (s *JobStore) RequeueJob(jobID string, reason string, message string) (JobInfo, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job, err := s.loadLocked(jobID)
+	if err != nil {
+		return JobInfo{}, err
+	}
+	if !requeueableStatuses[job.Status] {
+		return job, transitionError(job.Status, JobStatusQueued)
+	}
+	old := job
+
+	job = s.requeueLocked(job, reason, message)
+	s.saveLocked(&job, &old)
+	return job, nil
+}
+
+// requeueLocked closes the job's current attempt and starts the next one
+func (s *JobStore) requeueLocked(job JobInfo, reason string, message string) JobInfo {
+	now := time.Now().UTC()
+	completedAt := job.CompletedAt
+	if completedAt.IsZero() {
+		completedAt = now
+	}
+
+	// Copy the history so readers holding the old JobInfo never see it change
+	attempts := make([]JobAttempt, len(job.Attempts), len(job.Attempts)+1)
+	copy(attempts, job.Attempts)
+	job.Attempts = append(attempts, JobAttempt{
+		Attempt:     job.Attempt,
+		Status:      job.Status,
+		Reason:      reason,
+		Message:     job.Message,
+		StartedAt:   job.StartedAt,
+		CompletedAt: completedAt,
+	})
+
+	from := job.Status
+	job.Attempt++
+	job.Status = JobStatusQueued
+	job.Message = message
+	job.PreemptedBy = ""
+	job.PreemptionConfirmed = false
+	job.StartedAt = time.Time{}
+	job.CompletedAt = time.Time{}
+	job.Progress = 0
+	job.ProgressUpdatedAt = time.Time{}
+	job.History = appendHistory(job, JobTransition{
+		From:      from,
+		To:        JobStatusQueued,
+		Message:   message,
+		Reason:    reason,
+		Attempt:   job.Attempt,
+		Timestamp: now,
 	})
 
-	return statusJobs
+	return job
 }
 
 // DeleteJob removes a job from the store
 func (s *JobStore) DeleteJob(jobID string) {
-	s.jobs.Delete(jobID)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.deleteLocked(jobID)
+}
+
+// ArrayJobs returns the children of a job array ordered by array index
+func (s *JobStore) ArrayJobs(arrayID string) []JobInfo {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	keys := s.index.byArray[arrayID]
+	jobs := make([]JobInfo, 0, len(keys))
+	for _, key := range keys {
+		if job, err := s.loadLocked(key.JobID); err == nil {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ArrayIndex < jobs[j].ArrayIndex })
+	return jobs
+}
+
+// JobsByStatus returns the jobs currently in any of the given statuses
+func (s *JobStore) JobsByStatus(statuses ...JobStatus) []JobInfo {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var jobs []JobInfo
+	for _, status := range statuses {
+		for jobID := range s.index.byStatus[status] {
+			if job, err := s.loadLocked(jobID); err == nil {
+				jobs = append(jobs, job)
+			}
+		}
+	}
+	return jobs
+}
+
+// HeldJobs returns the jobs the gateway is holding back, highest priority
+// first and oldest first within a priority
+func (s *JobStore) HeldJobs() []JobInfo {
+	var held []JobInfo
+	for _, job := range s.JobsByStatus(JobStatusQueued) {
+		if job.Held {
+			held = append(held, job)
+		}
+	}
+	sort.Slice(held, func(i, j int) bool {
+		if held[i].Priority != held[j].Priority {
+			return held[i].Priority > held[j].Priority
+		}
+		return held[i].SubmittedAt.Before(held[j].SubmittedAt)
+	})
+	return held
+}
+
+// HoldJob holds a queued job again, e.g. a retry waiting for admission
+// Fails if the job left the queue or moved on to another attempt
+func (s *JobStore) HoldJob(jobID string, attempt int, message string) (JobInfo, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job, err := s.loadLocked(jobID)
+	if err != nil {
+		return JobInfo{}, err
+	}
+	if job.Status != JobStatusQueued {
+		return job, ErrJobNotQueued
+	}
+	if err := checkAttempt(job, attempt); err != nil {
+		return job, err
+	}
+	old := job
+
+	job.Held = true
+	job.Message = message
+	s.saveLocked(&job, &old)
+	return job, nil
+}
+
+// ReleaseHeldJob clears a job's hold so it can be published
+// Fails with ErrJobNotQueued if the job left the queue or was already released
+func (s *JobStore) ReleaseHeldJob(jobID string, message string) (JobInfo, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job, err := s.loadLocked(jobID)
+	if err != nil {
+		return JobInfo{}, err
+	}
+	if job.Status != JobStatusQueued || !job.Held {
+		return job, ErrJobNotQueued
+	}
+	old := job
+
+	job.Held = false
+	job.Message = message
+	s.saveLocked(&job, &old)
+	return job, nil
 }
 
 // Count returns the total number of jobs in the store
 func (s *JobStore) Count() int {
-	count := 0
-	s.jobs.Range(func(key, value interface{}) bool {
-		count++
-		return true
-	})
-	return count
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return len(s.index.order)
+}
+
+// CountByStatus returns the number of jobs with the given status
+func (s *JobStore) CountByStatus(status JobStatus) int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.index.countStatus(status)
+}
+
+// loadLocked returns the stored job with the given ID
+func (s *JobStore) loadLocked(jobID string) (JobInfo, error) {
+	value, ok := s.jobs.Load(jobID)
+	if !ok {
+		return JobInfo{}, ErrJobNotFound
+	}
+
+	job, ok := value.(JobInfo)
+	if !ok {
+		return JobInfo{}, errors.New("invalid job data")
+	}
+
+	return job, nil
+}
+
+// saveLocked stores a job and keeps the indexes in sync
+// old is the previously stored version, or nil for a new job
+// The job's revision is bumped in place so callers return the stored version
+func (s *JobStore) saveLocked(job *JobInfo, old *JobInfo) {
+	// Only queued jobs can be held, e.g. a held job that's cancelled is done with it
+	if job.Status != JobStatusQueued {
+		job.Held = false
+	}
+
+	job.Revision = 1
+	if old != nil {
+		job.Revision = old.Revision + 1
+	}
+
+	s.jobs.Store(job.JobID, *job)
+	if old == nil {
+		s.index.add(*job)
+	} else {
+		s.index.update(*old, *job)
+	}
+
+	for _, handler := range s.changeHandlers {
+		handler(old, job)
+	}
+}
+
+// deleteLocked removes a job and its index entries
+func (s *JobStore) deleteLocked(jobID string) {
+	job, err := s.loadLocked(jobID)
+	if err != nil {
+		return
+	}
+	s.jobs.Delete(jobID)
+	s.index.remove(job)
+
+	for _, handler := range s.changeHandlers {
+		handler(&job, nil)
+	}
 }
 
 // periodicCleanup removes old completed jobs to prevent memory bloat
@@ -203,77 +775,43 @@ func (s *JobStore) periodicCleanup() {
 
 // cleanupOldJobs removes old completed jobs
 func (s *JobStore) cleanupOldJobs() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	var jobsToDelete []string
 	cutoffTime := time.Now().UTC().Add(-24 * time.Hour)
 
 	// First pass: collect jobs to delete
-	s.jobs.Range(func(key, value interface{}) bool {
-		jobID, ok := key.(string)
-		if !ok {
-			return true
-		}
-
-		job, ok := value.(JobInfo)
-		if !ok {
-			return true
-		}
+	// Only finished jobs qualify so we just walk the status indexes
+	for _, status := range []JobStatus{JobStatusCompleted, JobStatusFailed, JobStatusCancelled, JobStatusExpired} {
+		for jobID := range s.index.byStatus[status] {
+			job, err := s.loadLocked(jobID)
+			if err != nil {
+				continue
+			}
 
-		// Remove completed, failed, or cancelled jobs older than the cutoff
-		if (job.Status == JobStatusCompleted || job.Status == JobStatusFailed || job.Status == JobStatusCancelled) &&
-			!job.CompletedAt.IsZero() && job.CompletedAt.Before(cutoffTime) {
-			jobsToDelete = append(jobsToDelete, jobID)
+			// Remove completed, failed, or cancelled jobs older than the cutoff
+			if !job.CompletedAt.IsZero() && job.CompletedAt.Before(cutoffTime) {
+				jobsToDelete = append(jobsToDelete, jobID)
+			}
 		}
-
-		return true
-	})
+	}
 
 	// Second pass: delete the collected jobs
 	for _, jobID := range jobsToDelete {
-		s.jobs.Delete(jobID)
+		s.deleteLocked(jobID)
 	}
 
 	// If we still have too many jobs, delete the oldest ones regardless of status
-	// This prevents uncontrolled memory growth in high-load situations - virjilakrum
-	if s.Count() > s.maxJobs {
-		type jobWithTime struct {
-			ID   string
-			Time time.Time
-		}
-
-		var allJobs []jobWithTime
-
-		s.jobs.Range(func(key, value interface{}) bool {
-			jobID, ok := key.(string)
-			if !ok {
-				return true
-			}
-
-			job, ok := value.(JobInfo)
-			if !ok {
-				return true
-			}
-
-			allJobs = append(allJobs, jobWithTime{
-				ID:   jobID,
-				Time: job.SubmittedAt,
-			})
-
-			return true
-		})
-
-		// Sort jobs by submission time (oldest first)
-		// Could use a heap for better performance but this is simpler - virjilakrum
-		for i := 0; i < len(allJobs); i++ {
-			for j := i + 1; j < len(allJobs); j++ {
-				if allJobs[i].Time.After(allJobs[j].Time) {
-					allJobs[i], allJobs[j] = allJobs[j], allJobs[i]
-				}
-			}
+	// This prevents uncontrolled memory growth in high-load situations
+	// The ordered index is oldest first, so no sorting needed anymore
+	if excess := len(s.index.order) - s.maxJobs; excess > 0 {
+		oldest := make([]string, 0, excess)
+		for _, key := range s.index.order[:excess] {
+			oldest = append(oldest, key.JobID)
 		}
-
-		// Delete oldest jobs to get down to maxJobs
-		for i := 0; i < len(allJobs)-s.maxJobs; i++ {
-			s.jobs.Delete(allJobs[i].ID)
+		for _, jobID := range oldest {
+			s.deleteLocked(jobID)
 		}
 	}
 }
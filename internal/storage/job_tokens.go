@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// JobTokens keeps the tokens job attempts' workers fetch their inputs with
+// Shared by the secrets and dataset stores. Only a hash of each token is kept,
+// the token itself goes to the worker; tokens are indexed by job so revoking
+// them on every job change doesn't scan all of them
+type JobTokens[G any] struct {
+	mutex  sync.Mutex
+	ttl    time.Duration
+	tokens map[string]jobToken[G] // Token hash -> grant
+	byJob  map[string][]string    // Job ID -> token hashes
+}
+
+// jobToken is a stored token's grant together with its job and expiry
+type jobToken[G any] struct {
+	jobID     string
+	grant     G
+	expiresAt time.Time
+}
+
+// NewJobTokens creates an empty token set whose tokens expire after ttl
+func NewJobTokens[G any](ttl time.Duration) *JobTokens[G] {
+	return &JobTokens[G]{
+		ttl:    ttl,
+		tokens: make(map[string]jobToken[G]),
+		byJob:  make(map[string][]string),
+	}
+}
+
+// Issue creates a token granting grant to one of the job's attempts
+func (t *JobTokens[G]) Issue(jobID string, grant G) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now().UTC()
+	for key, stored := range t.tokens {
+		if now.After(stored.expiresAt) {
+			t.removeLocked(key)
+		}
+	}
+
+	key := tokenHash(token)
+	t.tokens[key] = jobToken[G]{jobID: jobID, grant: grant, expiresAt: now.Add(t.ttl)}
+	t.byJob[jobID] = append(t.byJob[jobID], key)
+	return token, nil
+}
+
+// Lookup returns the grant of a job's token, leaving the token in place
+func (t *JobTokens[G]) Lookup(jobID, token string) (G, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	stored, ok := t.tokens[tokenHash(token)]
+	if !ok || stored.jobID != jobID || time.Now().After(stored.expiresAt) {
+		var none G
+		return none, false
+	}
+	return stored.grant, true
+}
+
+// Take returns the grant of a job's token and uses the token up
+// A token presented for the wrong job is used up as well
+func (t *JobTokens[G]) Take(jobID, token string) (G, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	key := tokenHash(token)
+	stored, ok := t.tokens[key]
+	if ok {
+		t.removeLocked(key)
+	}
+	if !ok || stored.jobID != jobID || time.Now().After(stored.expiresAt) {
+		var none G
+		return none, false
+	}
+	return stored.grant, true
+}
+
+// Observe revokes a job's tokens once its attempt ends
+// Registered as a job store change handler through the stores using it
+func (t *JobTokens[G]) Observe(old *JobInfo, job *JobInfo) {
+	var jobID string
+	switch {
+	case job == nil && old != nil:
+		jobID = old.JobID
+	case job != nil && job.Status.EndsAttempt():
+		jobID = job.JobID
+	default:
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, key := range t.byJob[jobID] {
+		delete(t.tokens, key)
+	}
+	delete(t.byJob, jobID)
+}
+
+// removeLocked drops a token from the set and its job's index entry
+func (t *JobTokens[G]) removeLocked(key string) {
+	stored, ok := t.tokens[key]
+	if !ok {
+		return
+	}
+	delete(t.tokens, key)
+
+	keys := t.byJob[stored.jobID]
+	for i, k := range keys {
+		if k == key {
+			keys = append(keys[:i], keys[i+1:]...)
+			break
+		}
+	}
+	if len(keys) == 0 {
+		delete(t.byJob, stored.jobID)
+	} else {
+		t.byJob[stored.jobID] = keys
+	}
+}
+
+// tokenHash returns the key a token is stored under
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Well-known failure reasons reported by workers on jobs.status
+// Workers are free to send other codes, these are just the ones we see most
+// Keeping them as plain strings so new codes don't need a gateway release
+const (
+	FailureReasonPreempted   = "preempted"
+	FailureReasonOOM         = "oom"
+	FailureReasonNodeFailure = "node_failure"
+	FailureReasonTimeout     = "timeout"
+	FailureReasonDeadline    = "deadline_exceeded"
+)
+
+// Retry policy defaults
+// 30s initial backoff is long enough for a bad node to get drained
+// Capping at 10 minutes so retries don't drift into the next day
+const (
+	DefaultRetryBackoff    = 30 * time.Second
+	DefaultRetryMaxBackoff = 10 * time.Minute
+	MaxRetryAttempts       = 10
+)
+
+// RetryPolicy describes how the gateway retries a failed job
+// Backoff doubles after every attempt up to MaxBackoff
+// An empty RetryOn list means every failure reason is retried
+type RetryPolicy struct {
+	MaxAttempts int      `json:"max_attempts"`
+	Backoff     string   `json:"backoff,omitempty"`     // e.g. "30s", initial delay between attempts
+	MaxBackoff  string   `json:"max_backoff,omitempty"` // e.g. "10m", upper bound for the delay
+	RetryOn     []string `json:"retry_on,omitempty"`    // failure reasons to retry, e.g. ["preempted", "oom"]
+}
+
+// JobAttempt records the outcome of a single attempt of a job
+// We keep one entry per finished attempt so users can see why a job was retried
+type JobAttempt struct {
+	Attempt     int       `json:"attempt"`
+	Status      JobStatus `json:"status"`
+	Reason      string    `json:"reason,omitempty"`
+	Message     string    `json:"message,omitempty"`
+	StartedAt   time.Time `json:"started_at,omitempty"`
+	CompletedAt time.Time `json:"completed_at,omitempty"`
+}
+
+// Validate checks that the retry policy is usable
+// Called at submission time so bad policies are rejected with a 400
+// rather than silently never retrying
+func (p *RetryPolicy) Validate() error {
+	if p.MaxAttempts < 1 {
+		return errors.New("max_attempts must be at least 1")
+	}
+	if p.MaxAttempts > MaxRetryAttempts {
+		return fmt.Errorf("max_attempts must not exceed %d", MaxRetryAttempts)
+	}
+	if p.Backoff != "" {
+		d, err := time.ParseDuration(p.Backoff)
+		if err != nil {
+			return fmt.Errorf("invalid backoff duration: %w", err)
+		}
+		if d < 0 {
+			return errors.New("backoff must not be negative")
+		}
+	}
+	if p.MaxBackoff != "" {
+		d, err := time.ParseDuration(p.MaxBackoff)
+		if err != nil {
+			return fmt.Errorf("invalid max_backoff duration: %w", err)
+		}
+		if d < 0 {
+			return errors.New("max_backoff must not be negative")
+		}
+	}
+	return nil
+}
+
+// ShouldRetry reports whether a failure with the given reason is retryable
+// Reason matching is case-insensitive since workers aren't consistent about it
+func (p *RetryPolicy) ShouldRetry(reason string) bool {
+	if len(p.RetryOn) == 0 {
+		return true
+	}
+	for _, r := range p.RetryOn {
+		if strings.EqualFold(r, reason) {
+			return true
+		}
+	}
+	return false
+}
+
+// BackoffFor returns the delay before starting the given attempt number
+// Attempt 2 waits Backoff, attempt 3 waits 2*Backoff and so on
+func (p *RetryPolicy) BackoffFor(attempt int) time.Duration {
+	backoff := DefaultRetryBackoff
+	if p.Backoff != "" {
+		if d, err := time.ParseDuration(p.Backoff); err == nil {
+			backoff = d
+		}
+	}
+	maxBackoff := DefaultRetryMaxBackoff
+	if p.MaxBackoff != "" {
+		if d, err := time.ParseDuration(p.MaxBackoff); err == nil {
+			maxBackoff = d
+		}
+	}
+
+	for i := 2; i < attempt; i++ {
+		backoff *= 2
+		if backoff >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	if backoff > maxBackoff {
+		return maxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrTemplateNotFound is returned when a template or template version doesn't exist
+var ErrTemplateNotFound = errors.New("template not found")
+
+// TemplateVariable declares a placeholder that can be used in a template's job
+type TemplateVariable struct {
+	Description string `json:"description,omitempty"`
+	Default     any    `json:"default,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// JobTemplate is a stored, versioned job definition
+// Job holds the job request JSON with {{variable}} placeholders; it's kept raw
+// so the store doesn't need to know the request format
+// Templates with a project are shared with its members, the rest are private
+type JobTemplate struct {
+	TemplateID  string                      `json:"template_id"`
+	Name        string                      `json:"name"`
+	Description string                      `json:"description,omitempty"`
+	OwnerID     string                      `json:"owner_id"`
+	Project     string                      `json:"project,omitempty"`
+	Version     int                         `json:"version"`
+	Job         json.RawMessage             `json:"job"`
+	Variables   map[string]TemplateVariable `json:"variables,omitempty"`
+	CreatedAt   time.Time                   `json:"created_at"`
+	UpdatedAt   time.Time                   `json:"updated_at"`
+}
+
+// TemplateStore keeps job templates with their full version history
+// Jobs record the version they were created from, so old versions stay readable
+type TemplateStore struct {
+	mutex     sync.RWMutex
+	templates map[string][]JobTemplate // Template ID -> versions, oldest first
+}
+
+// NewTemplateStore creates an empty template store
+func NewTemplateStore() *TemplateStore {
+	return &TemplateStore{
+		templates: make(map[string][]JobTemplate),
+	}
+}
+
+// CreateTemplate stores a new template as version 1
+func (s *TemplateStore) CreateTemplate(template JobTemplate) JobTemplate {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now().UTC()
+	template.Version = 1
+	template.CreatedAt = now
+	template.UpdatedAt = now
+
+	s.templates[template.TemplateID] = []JobTemplate{template}
+	return template
+}
+
+// GetTemplate returns a template version, or the latest one if version is 0
+func (s *TemplateStore) GetTemplate(templateID string, version int) (JobTemplate, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	versions := s.templates[templateID]
+	if len(versions) == 0 {
+		return JobTemplate{}, ErrTemplateNotFound
+	}
+	if version == 0 {
+		return versions[len(versions)-1], nil
+	}
+	if version < 1 || version > len(versions) {
+		return JobTemplate{}, ErrTemplateNotFound
+	}
+	return versions[version-1], nil
+}
+
+// UpdateTemplate stores a new version of an existing template
+// Identity fields (ID, owner, creation time) are kept from the original
+func (s *TemplateStore) UpdateTemplate(template JobTemplate) (JobTemplate, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	versions := s.templates[template.TemplateID]
+	if len(versions) == 0 {
+		return JobTemplate{}, ErrTemplateNotFound
+	}
+	latest := versions[len(versions)-1]
+
+	template.OwnerID = latest.OwnerID
+	template.CreatedAt = latest.CreatedAt
+	template.Version = latest.Version + 1
+	template.UpdatedAt = time.Now().UTC()
+
+	s.templates[template.TemplateID] = append(versions, template)
+	return template, nil
+}
+
+// DeleteTemplate removes a template and all its versions
+func (s *TemplateStore) DeleteTemplate(templateID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.templates[templateID]; !ok {
+		return ErrTemplateNotFound
+	}
+	delete(s.templates, templateID)
+	return nil
+}
+
+// ListTemplates returns the latest version of every template accepted by the filter, by name
+func (s *TemplateStore) ListTemplates(filter func(JobTemplate) bool) []JobTemplate {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	templates := make([]JobTemplate, 0)
+	for _, versions := range s.templates {
+		latest := versions[len(versions)-1]
+		if filter == nil || filter(latest) {
+			templates = append(templates, latest)
+		}
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].Name != templates[j].Name {
+			return templates[i].Name < templates[j].Name
+		}
+		return templates[i].TemplateID < templates[j].TemplateID
+	})
+	return templates
+}
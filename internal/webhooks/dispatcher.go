@@ -0,0 +1,401 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/storage"
+)
+
+// Delivery settings
+// Backoff doubles from 5s, so six attempts cover a receiver that's down for
+// about two and a half minutes, e.g. a CI server restarting
+const (
+	DefaultWorkers     = 4
+	DefaultMaxAttempts = 6
+	InitialBackoff     = 5 * time.Second
+	MaxBackoff         = 5 * time.Minute
+	requestTimeout     = 10 * time.Second
+	queueSize          = 1024
+	maxTrackedJobs     = 10000
+	maxResponseBody    = 64 << 10
+)
+
+// Event names
+const (
+	EventJobStatus = "job.status"
+	EventPing      = "ping"
+)
+
+// Headers sent with every delivery
+// The signature is HMAC-SHA256 over "<timestamp>.<body>" with the webhook's
+// secret, receivers should also reject old timestamps to stop replays
+const (
+	HeaderSignature = "X-Siger-Signature"
+	HeaderTimestamp = "X-Siger-Timestamp"
+	HeaderEvent     = "X-Siger-Event"
+	HeaderDelivery  = "X-Siger-Delivery"
+)
+
+// Payload is the JSON body POSTed to webhooks
+type Payload struct {
+	Event      string      `json:"event"`
+	DeliveryID string      `json:"delivery_id"`
+	WebhookID  string      `json:"webhook_id"`
+	Timestamp  time.Time   `json:"timestamp"`
+	Text       string      `json:"text"` // Human readable summary, also makes Slack incoming webhooks work as is
+	Job        *JobSummary `json:"job,omitempty"`
+}
+
+// JobSummary is the part of a job sent to webhooks
+type JobSummary struct {
+	JobID       string     `json:"job_id"`
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	Project     string     `json:"project,omitempty"`
+	Status      string     `json:"status"`
+	Message     string     `json:"message,omitempty"`
+	Reason      string     `json:"reason,omitempty"`
+	Attempt     int        `json:"attempt"`
+	SubmittedAt time.Time  `json:"submitted_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// Dispatcher sends webhook deliveries in the background
+// Failed attempts are retried with exponential backoff, deliveries that run
+// out of attempts go to the webhook's dead-letter list
+type Dispatcher struct {
+	store       *Store
+	targets     *TargetPolicy
+	client      *http.Client
+	queue       chan Delivery
+	done        chan struct{}
+	maxAttempts int
+
+	// Last status notified per job, workers repeat a status to report progress
+	mutex      sync.Mutex
+	lastStatus map[string]string
+	tracked    []string
+
+	logger internal.LoggerInterface
+}
+
+// NewDispatcher creates a dispatcher for the webhooks in store
+// Deliveries only connect to the targets the policy allows
+func NewDispatcher(store *Store, targets *TargetPolicy) *Dispatcher {
+	return &Dispatcher{
+		store:   store,
+		targets: targets,
+		client: &http.Client{
+			Timeout: requestTimeout,
+			// No proxy, the IP check has to see the address actually dialed
+			Transport: &http.Transport{
+				DialContext:         targets.dialContext(requestTimeout),
+				TLSHandshakeTimeout: requestTimeout,
+				MaxIdleConnsPerHost: 2,
+			},
+			// A redirect is a misconfigured URL, don't follow it with the payload
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		queue:       make(chan Delivery, queueSize),
+		done:        make(chan struct{}),
+		maxAttempts: DefaultMaxAttempts,
+		lastStatus:  make(map[string]string),
+		logger:      internal.Logger,
+	}
+}
+
+// Run delivers queued events with the given number of workers until ctx is done
+func (d *Dispatcher) Run(ctx context.Context, workers int) {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case delivery := <-d.queue:
+					d.attempt(ctx, delivery)
+				}
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	close(d.done)
+	wg.Wait()
+}
+
+// Notify queues deliveries for the webhooks matching a job's new status
+// Repeats of the status the job was last notified for are skipped
+func (d *Dispatcher) Notify(job storage.JobInfo, reason string) {
+	if !d.statusChanged(job) {
+		return
+	}
+
+	webhooks := d.store.MatchingWebhooks(job)
+	if len(webhooks) == 0 {
+		return
+	}
+
+	summary := newJobSummary(job, reason)
+	text := fmt.Sprintf("Job %s (%s) is %s", job.Name, job.JobID, job.Status)
+	if job.Message != "" {
+		text += ": " + job.Message
+	}
+
+	for _, webhook := range webhooks {
+		delivery, err := d.newDelivery(webhook, EventJobStatus, summary, text)
+		if err != nil {
+			d.logger.Errorf("Failed to create webhook delivery: webhook=%s job=%s error=%v", webhook.WebhookID, job.JobID, err)
+			continue
+		}
+		d.enqueue(delivery)
+	}
+}
+
+// CheckTarget rejects a webhook URL the dispatcher would refuse to deliver to
+func (d *Dispatcher) CheckTarget(target *url.URL) error {
+	return d.targets.CheckURL(target)
+}
+
+// Ping queues a test event for a webhook
+func (d *Dispatcher) Ping(webhook Webhook) (Delivery, error) {
+	delivery, err := d.newDelivery(webhook, EventPing, nil, "Test event from the Siger API Gateway")
+	if err != nil {
+		return Delivery{}, err
+	}
+	d.enqueue(delivery)
+	return delivery, nil
+}
+
+// Redeliver takes a delivery off the dead-letter list and sends it again
+// with a fresh set of attempts
+func (d *Dispatcher) Redeliver(webhookID, deliveryID string) (Delivery, error) {
+	delivery, err := d.store.TakeDeadLetter(webhookID, deliveryID)
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	delivery.Status = DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Time{}
+	if err := d.store.SaveDelivery(delivery); err != nil {
+		return Delivery{}, err
+	}
+	d.enqueue(delivery)
+	return delivery, nil
+}
+
+// newDelivery builds a delivery and records it as pending
+func (d *Dispatcher) newDelivery(webhook Webhook, event string, job *JobSummary, text string) (Delivery, error) {
+	now := time.Now().UTC()
+	deliveryID := uuid.New().String()
+
+	payload, err := json.Marshal(Payload{
+		Event:      event,
+		DeliveryID: deliveryID,
+		WebhookID:  webhook.WebhookID,
+		Timestamp:  now,
+		Text:       text,
+		Job:        job,
+	})
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	delivery := Delivery{
+		DeliveryID: deliveryID,
+		WebhookID:  webhook.WebhookID,
+		Event:      event,
+		Status:     DeliveryPending,
+		CreatedAt:  now,
+		Payload:    payload,
+	}
+	if job != nil {
+		delivery.JobID = job.JobID
+	}
+	if err := d.store.SaveDelivery(delivery); err != nil {
+		return Delivery{}, err
+	}
+	return delivery, nil
+}
+
+// enqueue hands a delivery to the workers without blocking the caller
+func (d *Dispatcher) enqueue(delivery Delivery) {
+	select {
+	case d.queue <- delivery:
+	default:
+		// Queue is full, wait for room off the caller's goroutine
+		go func() {
+			select {
+			case d.queue <- delivery:
+			case <-d.done:
+			}
+		}()
+	}
+}
+
+// attempt sends a delivery once and schedules the next attempt if it failed
+func (d *Dispatcher) attempt(ctx context.Context, delivery Delivery) {
+	webhook, err := d.store.GetWebhook(delivery.WebhookID)
+	if err != nil {
+		return // Deleted since the delivery was queued
+	}
+
+	delivery.Attempts++
+	statusCode, err := d.post(ctx, webhook, delivery)
+	delivery.LastStatusCode = statusCode
+
+	switch {
+	case err == nil:
+		delivery.Status = DeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = time.Now().UTC()
+		delivery.NextAttemptAt = time.Time{}
+		d.logger.Debugf("Webhook delivered: webhook=%s delivery=%s attempts=%d", webhook.WebhookID, delivery.DeliveryID, delivery.Attempts)
+	case delivery.Attempts >= d.maxAttempts:
+		delivery.Status = DeliveryDead
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = time.Time{}
+		d.logger.Warnf("Webhook delivery moved to dead letters: webhook=%s delivery=%s attempts=%d error=%v",
+			webhook.WebhookID, delivery.DeliveryID, delivery.Attempts, err)
+	default:
+		wait := backoff(delivery.Attempts)
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = time.Now().UTC().Add(wait)
+		d.logger.Infof("Webhook delivery failed, retrying in %s: webhook=%s delivery=%s attempt=%d error=%v",
+			wait, webhook.WebhookID, delivery.DeliveryID, delivery.Attempts, err)
+
+		retry := delivery
+		time.AfterFunc(wait, func() { d.enqueue(retry) })
+	}
+
+	if err := d.store.SaveDelivery(delivery); err != nil {
+		d.logger.Debugf("Webhook delivery not recorded: delivery=%s error=%v", delivery.DeliveryID, err)
+	}
+}
+
+// post sends the signed payload and returns the receiver's status code
+func (d *Dispatcher) post(ctx context.Context, webhook Webhook, delivery Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "siger-webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.DeliveryID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// statusChanged reports whether a job's status differs from the last one notified
+func (d *Dispatcher) statusChanged(job storage.JobInfo) bool {
+	key := string(job.Status) + "/" + strconv.Itoa(job.Attempt)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	last, tracked := d.lastStatus[job.JobID]
+	if last == key {
+		return false
+	}
+	d.lastStatus[job.JobID] = key
+
+	if !tracked {
+		d.tracked = append(d.tracked, job.JobID)
+		if len(d.tracked) > maxTrackedJobs {
+			// Forget the oldest jobs, at worst one of them notifies a repeat
+			evict := len(d.tracked) - maxTrackedJobs
+			for _, jobID := range d.tracked[:evict] {
+				delete(d.lastStatus, jobID)
+			}
+			d.tracked = append([]string(nil), d.tracked[evict:]...)
+		}
+	}
+	return true
+}
+
+// Sign computes the signature header value for a payload
+// Exported so receivers written in Go can verify deliveries the same way
+func Sign(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff returns how long to wait after the given number of failed attempts
+func backoff(attempts int) time.Duration {
+	wait := InitialBackoff
+	for i := 1; i < attempts && wait < MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > MaxBackoff {
+		wait = MaxBackoff
+	}
+	return wait
+}
+
+// newJobSummary converts a job to its webhook representation
+func newJobSummary(job storage.JobInfo, reason string) *JobSummary {
+	summary := &JobSummary{
+		JobID:       job.JobID,
+		Name:        job.Name,
+		Type:        job.Type,
+		Project:     job.Project,
+		Status:      string(job.Status),
+		Message:     job.Message,
+		Reason:      reason,
+		Attempt:     job.Attempt,
+		SubmittedAt: job.SubmittedAt,
+	}
+	if !job.StartedAt.IsZero() {
+		startedAt := job.StartedAt
+		summary.StartedAt = &startedAt
+	}
+	if !job.CompletedAt.IsZero() {
+		completedAt := job.CompletedAt
+		summary.CompletedAt = &completedAt
+	}
+	return summary
+}
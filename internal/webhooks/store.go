@@ -0,0 +1,255 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"siger-api-gateway/internal/storage"
+)
+
+// Store limits
+// The delivery log is for debugging a receiver, not an audit trail
+const (
+	MaxDeliveryLog  = 100
+	MaxDeadLetters  = 100
+	MaxWebhooksUser = 50
+)
+
+// Common errors
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("delivery not found")
+	ErrTooManyWebhooks  = errors.New("webhook limit reached")
+)
+
+// DeliveryStatus is where a delivery stands
+type DeliveryStatus string
+
+const (
+	// DeliveryPending is waiting for its first or next attempt
+	DeliveryPending DeliveryStatus = "pending"
+
+	// DeliverySucceeded got a 2xx response
+	DeliverySucceeded DeliveryStatus = "succeeded"
+
+	// DeliveryDead ran out of attempts and sits in the dead-letter list
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// Webhook is a user-registered callback for job status changes
+// With a JobID it fires for that job only, otherwise for all of the owner's jobs
+// An empty Statuses list means the terminal statuses
+type Webhook struct {
+	WebhookID string              `json:"webhook_id"`
+	OwnerID   string              `json:"owner_id"`
+	URL       string              `json:"url"`
+	JobID     string              `json:"job_id,omitempty"`
+	Statuses  []storage.JobStatus `json:"statuses"`
+	Secret    string              `json:"-"` // HMAC key, only shown when the webhook is created
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+// Matches reports whether the webhook fires for a job entering its current status
+func (w Webhook) Matches(job storage.JobInfo) bool {
+	if w.JobID != "" {
+		if w.JobID != job.JobID {
+			return false
+		}
+	} else if w.OwnerID != job.UserID {
+		return false
+	}
+
+	for _, status := range w.Statuses {
+		if status == job.Status {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is one event sent to a webhook, with every attempt at sending it
+type Delivery struct {
+	DeliveryID     string          `json:"delivery_id"`
+	WebhookID      string          `json:"webhook_id"`
+	Event          string          `json:"event"`
+	JobID          string          `json:"job_id,omitempty"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at,omitempty"`
+	DeliveredAt    time.Time       `json:"delivered_at,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+// Store keeps webhooks, their recent deliveries and dead letters in memory
+type Store struct {
+	mutex       sync.RWMutex
+	webhooks    map[string]Webhook
+	deliveries  map[string][]Delivery // Webhook ID -> delivery log, oldest first
+	deadLetters map[string][]Delivery // Webhook ID -> dead deliveries, oldest first
+}
+
+// NewStore creates an empty webhook store
+func NewStore() *Store {
+	return &Store{
+		webhooks:    make(map[string]Webhook),
+		deliveries:  make(map[string][]Delivery),
+		deadLetters: make(map[string][]Delivery),
+	}
+}
+
+// CreateWebhook stores a new webhook
+func (s *Store) CreateWebhook(webhook Webhook) (Webhook, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	owned := 0
+	for _, w := range s.webhooks {
+		if w.OwnerID == webhook.OwnerID {
+			owned++
+		}
+	}
+	if owned >= MaxWebhooksUser {
+		return Webhook{}, ErrTooManyWebhooks
+	}
+
+	webhook.CreatedAt = time.Now().UTC()
+	s.webhooks[webhook.WebhookID] = webhook
+	return webhook, nil
+}
+
+// GetWebhook returns a webhook by ID
+func (s *Store) GetWebhook(webhookID string) (Webhook, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	webhook, ok := s.webhooks[webhookID]
+	if !ok {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook removes a webhook with its delivery log and dead letters
+// Deliveries still being retried give up at their next attempt
+func (s *Store) DeleteWebhook(webhookID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.webhooks[webhookID]; !ok {
+		return ErrWebhookNotFound
+	}
+	delete(s.webhooks, webhookID)
+	delete(s.deliveries, webhookID)
+	delete(s.deadLetters, webhookID)
+	return nil
+}
+
+// ListWebhooks returns a user's webhooks, oldest first
+func (s *Store) ListWebhooks(ownerID string) []Webhook {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var webhooks []Webhook
+	for _, w := range s.webhooks {
+		if w.OwnerID == ownerID {
+			webhooks = append(webhooks, w)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+	return webhooks
+}
+
+// MatchingWebhooks returns the webhooks that fire for a job's current status
+func (s *Store) MatchingWebhooks(job storage.JobInfo) []Webhook {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var matches []Webhook
+	for _, w := range s.webhooks {
+		if w.Matches(job) {
+			matches = append(matches, w)
+		}
+	}
+	return matches
+}
+
+// SaveDelivery records a delivery, adding it to the log or updating it in place
+// Dead deliveries are moved to the dead-letter list as well
+func (s *Store) SaveDelivery(delivery Delivery) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.webhooks[delivery.WebhookID]; !ok {
+		return ErrWebhookNotFound
+	}
+
+	s.deliveries[delivery.WebhookID] = upsertDelivery(s.deliveries[delivery.WebhookID], delivery, MaxDeliveryLog)
+	if delivery.Status == DeliveryDead {
+		s.deadLetters[delivery.WebhookID] = upsertDelivery(s.deadLetters[delivery.WebhookID], delivery, MaxDeadLetters)
+	}
+	return nil
+}
+
+// Deliveries returns a webhook's delivery log, newest first
+func (s *Store) Deliveries(webhookID string) []Delivery {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return newestFirst(s.deliveries[webhookID])
+}
+
+// DeadLetters returns a webhook's dead deliveries, newest first
+func (s *Store) DeadLetters(webhookID string) []Delivery {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return newestFirst(s.deadLetters[webhookID])
+}
+
+// TakeDeadLetter removes a dead delivery so it can be sent again
+func (s *Store) TakeDeadLetter(webhookID, deliveryID string) (Delivery, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	dead := s.deadLetters[webhookID]
+	for i, d := range dead {
+		if d.DeliveryID == deliveryID {
+			s.deadLetters[webhookID] = append(dead[:i:i], dead[i+1:]...)
+			return d, nil
+		}
+	}
+	return Delivery{}, ErrDeliveryNotFound
+}
+
+// upsertDelivery replaces a delivery with the same ID or appends it, keeping the newest max
+func upsertDelivery(deliveries []Delivery, delivery Delivery, max int) []Delivery {
+	for i := range deliveries {
+		if deliveries[i].DeliveryID == delivery.DeliveryID {
+			deliveries[i] = delivery
+			return deliveries
+		}
+	}
+
+	deliveries = append(deliveries, delivery)
+	if len(deliveries) > max {
+		deliveries = append([]Delivery(nil), deliveries[len(deliveries)-max:]...)
+	}
+	return deliveries
+}
+
+// newestFirst returns a reversed copy of deliveries
+func newestFirst(deliveries []Delivery) []Delivery {
+	reversed := make([]Delivery, len(deliveries))
+	for i, d := range deliveries {
+		reversed[len(deliveries)-1-i] = d
+	}
+	return reversed
+}
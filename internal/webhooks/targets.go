@@ -0,0 +1,116 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrTargetNotAllowed is returned for webhook URLs pointing at internal hosts
+var ErrTargetNotAllowed = errors.New("webhook target not allowed")
+
+// TargetPolicy keeps webhook deliveries off the gateway's own network
+// Users pick the URLs, so without it the delivery log would tell them what
+// answers on loopback, private ranges or the cloud metadata address. The
+// IP check runs at connect time, so DNS rebinding can't get around it
+type TargetPolicy struct {
+	hosts    map[string]bool // Allowlisted hostnames, dialed without the IP check
+	prefixes []netip.Prefix  // Allowlisted IPs and ranges
+}
+
+// NewTargetPolicy creates a policy allowing the given hosts, IPs and CIDRs
+// even though they're internal, e.g. a CI server on the private network
+func NewTargetPolicy(allowed []string) (*TargetPolicy, error) {
+	p := &TargetPolicy{hosts: make(map[string]bool)}
+	for _, entry := range allowed {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			p.prefixes = append(p.prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			p.prefixes = append(p.prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		if strings.ContainsAny(entry, "/:") {
+			return nil, fmt.Errorf("invalid webhook allowed host %q", entry)
+		}
+		p.hosts[entry] = true
+	}
+	return p, nil
+}
+
+// CheckURL rejects webhook URLs naming an IP or localhost that isn't allowlisted
+// Hostnames resolving to internal IPs are caught when the delivery connects
+func (p *TargetPolicy) CheckURL(target *url.URL) error {
+	host := strings.ToLower(target.Hostname())
+	if p.hosts[host] {
+		return nil
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if p.allowedAddr(addr) {
+			return nil
+		}
+		return fmt.Errorf("%w: IP address targets must be allowlisted by an admin", ErrTargetNotAllowed)
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: localhost", ErrTargetNotAllowed)
+	}
+	return nil
+}
+
+// allowedAddr reports whether addr is covered by the allowlist
+func (p *TargetPolicy) allowedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// internalAddr reports whether addr is on a range deliveries may not reach
+func internalAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() || addr.IsUnspecified()
+}
+
+// control refuses connections to internal IPs that aren't allowlisted
+// Runs after DNS resolution, on the address actually dialed
+func (p *TargetPolicy) control(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrTargetNotAllowed, address)
+	}
+	if internalAddr(addrPort.Addr()) && !p.allowedAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s is an internal address", ErrTargetNotAllowed, addrPort.Addr())
+	}
+	return nil
+}
+
+// dialContext dials webhook targets, checking every IP it connects to
+// Allowlisted hostnames skip the check, they're trusted wherever they resolve
+func (p *TargetPolicy) dialContext(timeout time.Duration) func(ctx context.Context, network, address string) (net.Conn, error) {
+	checked := &net.Dialer{Timeout: timeout, Control: p.control}
+	trusted := &net.Dialer{Timeout: timeout}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err == nil && p.hosts[strings.ToLower(host)] {
+			return trusted.DialContext(ctx, network, address)
+		}
+		return checked.DialContext(ctx, network, address)
+	}
+}
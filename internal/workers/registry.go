@@ -0,0 +1,242 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"siger-api-gateway/internal"
+	"siger-api-gateway/internal/messaging"
+)
+
+// Heartbeat timing
+// Workers beat every 10s; three missed beats and a worker is dead. Dead
+// workers are remembered for a day so a rebooting node doesn't make its GPU
+// type disappear from the submit path
+const (
+	DefaultHeartbeatInterval = 10 * time.Second
+	DefaultDeadAfter         = 3 * DefaultHeartbeatInterval
+	DefaultForgetAfter       = 24 * time.Hour
+)
+
+// GPUTypeAny matches every GPU type, same as the job request value
+const GPUTypeAny = "any"
+
+// ErrWorkerNotFound is returned for unknown worker IDs
+var ErrWorkerNotFound = errors.New("worker not found")
+
+// WorkerStatus is whether a worker is still sending heartbeats
+type WorkerStatus string
+
+const (
+	// WorkerAlive sent a heartbeat recently
+	WorkerAlive WorkerStatus = "alive"
+
+	// WorkerDead missed its heartbeats, its GPUs don't count as capacity
+	WorkerDead WorkerStatus = "dead"
+)
+
+// Worker is the registry's view of a GPU worker
+type Worker struct {
+	WorkerID        string            `json:"worker_id"`
+	Hostname        string            `json:"hostname,omitempty"`
+	Status          WorkerStatus      `json:"status"`
+	GPUs            map[string]int    `json:"gpus"`
+	FreeGPUs        map[string]int    `json:"free_gpus"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	FirstSeenAt     time.Time         `json:"first_seen_at"`
+	LastHeartbeatAt time.Time         `json:"last_heartbeat_at"`
+}
+
+// GPUCapacity is the hardware of one GPU type on live workers
+type GPUCapacity struct {
+	Total   int `json:"total"`
+	Free    int `json:"free"`
+	Workers int `json:"workers"`
+	Largest int `json:"largest"` // Most GPUs of this type on a single worker
+}
+
+// Capacity summarizes the hardware live workers report
+type Capacity struct {
+	GPUTypes     map[string]GPUCapacity `json:"gpu_types"`
+	AliveWorkers int                    `json:"alive_workers"`
+	DeadWorkers  int                    `json:"dead_workers"`
+	Timestamp    time.Time              `json:"timestamp"`
+}
+
+// Registry tracks GPU workers from their heartbeats
+// Heartbeats are the only input, workers never have to register
+type Registry struct {
+	mutex       sync.RWMutex
+	workers     map[string]Worker
+	deadAfter   time.Duration
+	forgetAfter time.Duration
+	startedAt   time.Time
+	logger      internal.LoggerInterface
+}
+
+// NewRegistry creates an empty worker registry
+func NewRegistry() *Registry {
+	return &Registry{
+		workers:     make(map[string]Worker),
+		deadAfter:   DefaultDeadAfter,
+		forgetAfter: DefaultForgetAfter,
+		startedAt:   time.Now().UTC(),
+		logger:      internal.Logger,
+	}
+}
+
+// Heartbeat records a worker heartbeat
+// Worker clocks can't be trusted, so the receive time is what counts
+func (r *Registry) Heartbeat(heartbeat messaging.WorkerHeartbeat) {
+	now := time.Now().UTC()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	worker, known := r.workers[heartbeat.WorkerID]
+	if !known {
+		worker.WorkerID = heartbeat.WorkerID
+		worker.FirstSeenAt = now
+		r.logger.Infof("Worker registered: id=%s gpus=%v labels=%v", heartbeat.WorkerID, heartbeat.GPUs, heartbeat.Labels)
+	} else if worker.Status == WorkerDead {
+		r.logger.Infof("Worker is back: id=%s", heartbeat.WorkerID)
+	}
+
+	worker.Hostname = heartbeat.Hostname
+	worker.Status = WorkerAlive
+	worker.GPUs = heartbeat.GPUs
+	worker.FreeGPUs = heartbeat.FreeGPUs
+	worker.Labels = heartbeat.Labels
+	worker.LastHeartbeatAt = now
+	r.workers[worker.WorkerID] = worker
+}
+
+// Run marks workers dead once they miss their heartbeats until ctx is done
+func (r *Registry) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultHeartbeatInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.sweep(time.Now().UTC())
+		}
+	}
+}
+
+// sweep marks silent workers dead and forgets long dead ones
+func (r *Registry) sweep(now time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, worker := range r.workers {
+		silence := now.Sub(worker.LastHeartbeatAt)
+		switch {
+		case silence >= r.forgetAfter:
+			delete(r.workers, id)
+			r.logger.Infof("Worker forgotten: id=%s last_heartbeat=%s", id, worker.LastHeartbeatAt.Format(time.RFC3339))
+		case silence >= r.deadAfter && worker.Status == WorkerAlive:
+			worker.Status = WorkerDead
+			r.workers[id] = worker
+			r.logger.Warnf("Worker missed its heartbeats, marked dead: id=%s last_heartbeat=%s", id, worker.LastHeartbeatAt.Format(time.RFC3339))
+		}
+	}
+}
+
+// Workers returns all known workers sorted by ID
+func (r *Registry) Workers() []Worker {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	list := make([]Worker, 0, len(r.workers))
+	for _, worker := range r.workers {
+		list = append(list, worker)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].WorkerID < list[j].WorkerID })
+	return list
+}
+
+// Worker returns a worker by ID
+func (r *Registry) Worker(workerID string) (Worker, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	worker, ok := r.workers[workerID]
+	if !ok {
+		return Worker{}, ErrWorkerNotFound
+	}
+	return worker, nil
+}
+
+// Capacity sums up the GPUs of live workers by type
+func (r *Registry) Capacity() Capacity {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	capacity := Capacity{
+		GPUTypes:  make(map[string]GPUCapacity),
+		Timestamp: time.Now().UTC(),
+	}
+	for _, worker := range r.workers {
+		if worker.Status != WorkerAlive {
+			capacity.DeadWorkers++
+			continue
+		}
+		capacity.AliveWorkers++
+
+		for gpuType, count := range worker.GPUs {
+			gpu := capacity.GPUTypes[gpuType]
+			gpu.Total += count
+			gpu.Free += worker.FreeGPUs[gpuType]
+			gpu.Workers++
+			if count > gpu.Largest {
+				gpu.Largest = count
+			}
+			capacity.GPUTypes[gpuType] = gpu
+		}
+	}
+	return capacity
+}
+
+// Ready reports whether the registry has heard from the cluster
+// Until every worker had a chance to send a heartbeat, or while none has,
+// an empty registry says nothing about the hardware that exists
+func (r *Registry) Ready() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return len(r.workers) > 0 && time.Since(r.startedAt) >= r.deadAfter
+}
+
+// LargestNode returns the most GPUs of a type any known worker has, alive
+// or recently dead, 0 if none has the type. GPUTypeAny matches every type
+func (r *Registry) LargestNode(gpuType string) int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	largest := 0
+	for _, worker := range r.workers {
+		for t, count := range worker.GPUs {
+			if count > largest && MatchesGPUType(t, gpuType) {
+				largest = count
+			}
+		}
+	}
+	return largest
+}
+
+// MatchesGPUType reports whether a worker's GPU type satisfies a requested one
+// Case doesn't matter, workers report whatever their driver calls the card
+func MatchesGPUType(workerType, requested string) bool {
+	return requested == GPUTypeAny || strings.EqualFold(workerType, requested)
+}